@@ -27,6 +27,23 @@ const routes = [
     component: Results,
     props: true
   },
+  {
+    path: '/groups/new',
+    name: 'GroupCreate',
+    component: () => import('@/views/GroupCreate.vue')
+  },
+  {
+    path: '/groups/:token/join',
+    name: 'GroupJoin',
+    component: () => import('@/views/GroupJoin.vue'),
+    props: true
+  },
+  {
+    path: '/groups/:token/results',
+    name: 'GroupResults',
+    component: () => import('@/views/GroupResults.vue'),
+    props: true
+  },
   {
     path: '/view-results',
     name: 'ViewResults',
@@ -2,26 +2,163 @@
 const API_BASE_URL = '/api';
 
 /**
- * Load questions from the backend API.
- * @returns {Promise<Array>} A promise that resolves to an array of questions.
+ * Load the questionnaires that can be taken.
+ * @returns {Promise<Array>} A promise that resolves to an array of questionnaires
+ * with their `slug`, `title`, `description`, `language`, `theme` and latest `version`.
  */
-export async function loadQuestions() {
-  const response = await fetch(`${API_BASE_URL}/questions`);
-  
+export async function loadQuestionnaires() {
+  const response = await fetch(`${API_BASE_URL}/questionnaires`);
+
   if (!response.ok) {
-    throw new Error('Failed to load questions');
+    throw await apiError(response, 'Failed to load questionnaires');
   }
-  
+
   return await response.json();
 }
 
+/**
+ * Load the published question set from the backend API.
+ * @param {Object} [options]
+ * @param {string} [options.token] - An invitation or results token, to load the questions that session is answered against
+ * instead of the latest ones.
+ * @param {string} [options.questionnaire] - The slug of the questionnaire to load, instead of the default one.
+ * @returns {Promise<Object>} A promise that resolves to the question set's `version`
+ * (null if none was published) and its `questions`.
+ */
+export async function loadQuestionSet({ token, questionnaire } = {}) {
+  const params = new URLSearchParams();
+  if (token) {
+    params.set('token', token);
+  } else if (questionnaire) {
+    params.set('questionnaire', questionnaire);
+  }
+  const query = params.toString() ? `?${params}` : '';
+  const response = await fetch(`${API_BASE_URL}/questions${query}`);
+
+  if (!response.ok) {
+    throw await apiError(response, 'Failed to load questions');
+  }
+
+  const version = response.headers.get('X-Question-Set-Version');
+  return {
+    version: version ? Number(version) : null,
+    questions: (await response.json()) || [],
+  };
+}
+
+/**
+ * Load questions from the backend API.
+ * @param {string} [token] - A session token, to load the questions that session is answered against.
+ * @returns {Promise<Array>} A promise that resolves to an array of questions.
+ */
+export async function loadQuestions(token) {
+  const { questions } = await loadQuestionSet({ token });
+  return questions;
+}
+
+/**
+ * Find the first required question that has not been answered.
+ * @param {Array} questions - The questions being answered.
+ * @param {Object} answers - The answers, keyed by question text.
+ * @returns {Object|undefined} The unanswered question, if any.
+ */
+export function missingRequired(questions, answers) {
+  return questions.find(question => {
+    const answer = answers[question.question];
+    const empty = answer === undefined || answer === null ||
+      (typeof answer === 'string' && answer.trim() === '') ||
+      (Array.isArray(answer) && answer.length === 0);
+    return question.required && empty;
+  });
+}
+
+/**
+ * Render an answer for display. Multi-select and ranking answers are lists.
+ * @param {string|number|Array} answer - The answer.
+ * @returns {string} The answer as text.
+ */
+export function formatAnswer(answer) {
+  return Array.isArray(answer) ? answer.join('、') : String(answer);
+}
+
+/**
+ * An error returned by the backend. `code` is the machine-readable error code
+ * (see the error code catalogue in the README), `status` the HTTP status and
+ * `requestId` the ID to quote when reporting the problem. Validation errors
+ * carry the invalid `fields`.
+ */
+export class ApiError extends Error {
+  constructor(message, { status, code, details, requestId } = {}) {
+    super(message);
+    this.name = 'ApiError';
+    this.status = status;
+    this.code = code || 'internal_error';
+    this.details = details || null;
+    this.requestId = requestId || null;
+    if (this.code === 'validation_failed' && this.details) {
+      this.fields = this.details.fields || [];
+    }
+  }
+}
+
+/**
+ * Build the error thrown when a request fails, from the backend's error response.
+ * @param {Response} response - The failed response.
+ * @param {string} message - The error message, used if the response has none.
+ * @returns {Promise<ApiError>} The error.
+ */
+async function apiError(response, message) {
+  let body = {};
+  if ((response.headers.get('Content-Type') || '').includes('application/json')) {
+    body = (await response.json().catch(() => ({}))).error || {};
+  }
+  return new ApiError(body.message || message, {
+    status: response.status,
+    code: body.code,
+    details: body.details,
+    requestId: body.requestId || response.headers.get('X-Request-ID'),
+  });
+}
+
+/**
+ * Describe the invalid fields of a rejected submission for the user.
+ * @param {Array} fields - The `fields` of the backend's validation error.
+ * @returns {string} A message listing every problem.
+ */
+export function describeFieldErrors(fields) {
+  return fields.map(({ field, code }) => {
+    if (field === 'name') {
+      return '名称过长';
+    }
+    if (field === 'size') {
+      return '团队人数无效';
+    }
+    if (field === 'answers') {
+      return '请至少回答一个问题';
+    }
+    const question = field.replace(/^answers\./, '');
+    switch (code) {
+      case 'required':
+        return `「${question}」为必答题`;
+      case 'too_long':
+        return `「${question}」的回答过长`;
+      case 'unknown_question':
+        return `「${question}」不是当前问卷中的问题`;
+      default:
+        return `「${question}」的回答无效`;
+    }
+  }).join('；');
+}
+
 /**
  * Submit User A's answers to the backend.
  * @param {Object} answers - The answers from User A.
  * @param {boolean} shareAnswers - Whether User A wants to share their answers.
- * @returns {Promise<string>} A promise that resolves to the invitation token.
+ * @param {number|null} questionSetVersion - The question set version the answers were given against.
+ * @param {string} [questionnaire] - The slug of the questionnaire, empty for the default one.
+ * @returns {Promise<Object>} A promise that resolves to the `inviteToken` for User B and User A's `resultsToken`.
  */
-export async function submitUserA(answers, shareAnswers) {
+export async function submitUserA(answers, shareAnswers, questionSetVersion, questionnaire) {
   const response = await fetch(`${API_BASE_URL}/submit-user-a`, {
     method: 'POST',
     headers: {
@@ -30,23 +167,24 @@ export async function submitUserA(answers, shareAnswers) {
     body: JSON.stringify({
       answers,
       shareAnswers,
+      questionnaire: questionnaire || '',
+      questionSetVersion: questionSetVersion || 0,
     }),
   });
 
   if (!response.ok) {
-    throw new Error('Failed to submit User A answers');
+    throw await apiError(response, 'Failed to submit User A answers');
   }
 
-  const data = await response.json();
-  return data.token;
+  return await response.json();
 }
 
 /**
  * Submit User B's answers to the backend.
- * @param {string} token - The invitation token.
+ * @param {string} token - The invitation token, or User B's results token to revise the answers.
  * @param {Object} answers - The answers from User B.
  * @param {boolean} shareAnswers - Whether User B wants to share their answers.
- * @returns {Promise<boolean>} A promise that resolves to true if successful.
+ * @returns {Promise<Object>} A promise that resolves to the analysis `status` and, for the first submission, User B's `resultsToken`.
  */
 export async function submitUserB(token, answers, shareAnswers) {
   const response = await fetch(`${API_BASE_URL}/submit-user-b`, {
@@ -62,24 +200,209 @@ export async function submitUserB(token, answers, shareAnswers) {
   });
 
   if (!response.ok) {
-    throw new Error('Failed to submit User B answers');
+    throw await apiError(response, 'Failed to submit User B answers');
   }
 
-  const data = await response.json();
-  return data.success;
+  return await response.json();
 }
 
 /**
  * Get results from the backend.
- * @param {string} token - The invitation token.
+ * While the analysis is still running the backend answers with 202 and the
+ * returned object only carries a `status` of "pending" or "running".
+ * @param {string} token - The results token.
  * @returns {Promise<Object>} A promise that resolves to the results.
  */
 export async function getResults(token) {
   const response = await fetch(`${API_BASE_URL}/results/${token}`);
 
   if (!response.ok) {
-    throw new Error('Failed to fetch results');
+    throw await apiError(response, 'Failed to fetch results');
   }
 
   return await response.json();
-}
\ No newline at end of file
+}
+
+/**
+ * Stream the analysis summary as it is generated.
+ * @param {string} token - The results token.
+ * @param {Object} handlers - Callbacks for the stream.
+ * @param {Function} handlers.onDelta - Called with each new piece of summary text.
+ * @param {Function} handlers.onReplace - Called with text replacing the summary so far, e.g. when the local scorer takes over.
+ * @param {Function} handlers.onResult - Called once with the final results.
+ * @param {Function} handlers.onError - Called if the stream fails.
+ * @returns {EventSource} The underlying event source, close it to stop streaming.
+ */
+export function streamResults(token, { onDelta, onReplace, onResult, onError }) {
+  const source = new EventSource(`${API_BASE_URL}/results/${token}/stream`);
+
+  source.addEventListener('delta', (event) => {
+    onDelta(JSON.parse(event.data).content);
+  });
+  source.addEventListener('replace', (event) => {
+    onReplace(JSON.parse(event.data).content);
+  });
+  source.addEventListener('result', (event) => {
+    source.close();
+    onResult(JSON.parse(event.data));
+  });
+  source.onerror = () => {
+    source.close();
+    onError();
+  };
+
+  return source;
+}
+
+/**
+ * Create a group session with the initiator's answers.
+ * @param {string} name - The initiator's display name.
+ * @param {number} size - The number of members to invite.
+ * @param {Object} answers - The initiator's answers.
+ * @param {boolean} shareAnswers - Whether the initiator wants to share their answers.
+ * @param {number|null} questionSetVersion - The question set version the answers were given against.
+ * @param {string} [questionnaire] - The slug of the questionnaire, empty for the default one.
+ * @returns {Promise<Object>} A promise that resolves to the `inviteToken` for members and the initiator's `resultsToken`.
+ */
+export async function createGroup(name, size, answers, shareAnswers, questionSetVersion, questionnaire) {
+  const response = await fetch(`${API_BASE_URL}/groups`, {
+    method: 'POST',
+    headers: {
+      'Content-Type': 'application/json',
+    },
+    body: JSON.stringify({
+      name,
+      size,
+      answers,
+      shareAnswers,
+      questionnaire: questionnaire || '',
+      questionSetVersion: questionSetVersion || 0,
+    }),
+  });
+
+  if (!response.ok) {
+    throw await apiError(response, 'Failed to create group');
+  }
+
+  return await response.json();
+}
+
+/**
+ * Join a group session with a member's answers.
+ * @param {string} token - The group's invitation token.
+ * @param {string} name - The member's display name.
+ * @param {Object} answers - The member's answers.
+ * @param {boolean} shareAnswers - Whether the member wants to share their answers.
+ * @returns {Promise<Object>} A promise that resolves to the group's `status`, `joined` and `size`, and the member's `resultsToken`.
+ */
+export async function joinGroup(token, name, answers, shareAnswers) {
+  const response = await fetch(`${API_BASE_URL}/groups/${token}/join`, {
+    method: 'POST',
+    headers: {
+      'Content-Type': 'application/json',
+    },
+    body: JSON.stringify({
+      name,
+      answers,
+      shareAnswers,
+    }),
+  });
+
+  if (!response.ok) {
+    throw await apiError(response, 'Failed to join group');
+  }
+
+  return await response.json();
+}
+
+/**
+ * Start the group analysis before every invited member has joined.
+ * @param {string} token - A results token of the group.
+ * @returns {Promise<Object>} A promise that resolves to the group's `status`, `joined` and `size`.
+ */
+export async function analyzeGroup(token) {
+  const response = await fetch(`${API_BASE_URL}/groups/${token}/analyze`, {
+    method: 'POST',
+  });
+
+  if (!response.ok) {
+    throw await apiError(response, 'Failed to start group analysis');
+  }
+
+  return await response.json();
+}
+
+/**
+ * Get group results from the backend.
+ * While members are still joining the `status` is "open"; while the analysis
+ * is running the backend answers with 202 and only a `status` of "pending" or "running".
+ * @param {string} token - A results token of the group.
+ * @returns {Promise<Object>} A promise that resolves to the group results.
+ */
+export async function getGroupResults(token) {
+  const response = await fetch(`${API_BASE_URL}/groups/${token}`);
+
+  if (!response.ok) {
+    throw await apiError(response, 'Failed to fetch group results');
+  }
+
+  return await response.json();
+}
+
+/**
+ * Stream the group summary as it is generated.
+ * @param {string} token - A results token of the group.
+ * @param {Object} handlers - Callbacks for the stream, as for streamResults.
+ * @returns {EventSource} The underlying event source, close it to stop streaming.
+ */
+export function streamGroupResults(token, { onDelta, onReplace, onResult, onError }) {
+  const source = new EventSource(`${API_BASE_URL}/groups/${token}/stream`);
+
+  source.addEventListener('delta', (event) => {
+    onDelta(JSON.parse(event.data).content);
+  });
+  source.addEventListener('replace', (event) => {
+    onReplace(JSON.parse(event.data).content);
+  });
+  source.addEventListener('result', (event) => {
+    source.close();
+    onResult(JSON.parse(event.data));
+  });
+  source.onerror = () => {
+    source.close();
+    onError();
+  };
+
+  return source;
+}
+
+/**
+ * Export everything stored about a participant: their answers, share flag and results.
+ * @param {string} token - The participant's own results token.
+ * @returns {Promise<Object>} A promise that resolves to the participant's data.
+ */
+export async function exportMyData(token) {
+  const response = await fetch(`${API_BASE_URL}/my-data/${token}`);
+
+  if (!response.ok) {
+    throw await apiError(response, 'Failed to export data');
+  }
+
+  return await response.json();
+}
+
+/**
+ * Permanently erase a participant's answers. The summary of the session is
+ * redacted and the session takes no more answers.
+ * @param {string} token - The participant's own results token.
+ * @returns {Promise<void>}
+ */
+export async function eraseMyData(token) {
+  const response = await fetch(`${API_BASE_URL}/my-data/${token}`, {
+    method: 'DELETE',
+  });
+
+  if (!response.ok) {
+    throw await apiError(response, 'Failed to erase data');
+  }
+}
@@ -5,8 +5,10 @@ go 1.23
 toolchain go1.23.3
 
 require (
+	github.com/BurntSushi/toml v1.6.0
 	github.com/gorilla/mux v1.8.1
-	github.com/satori/go.uuid v1.2.0
+	golang.org/x/crypto v0.17.0
+	gopkg.in/yaml.v3 v3.0.1
 	gorm.io/driver/mysql v1.5.7
 	gorm.io/driver/postgres v1.5.11
 	gorm.io/driver/sqlite v1.6.0
@@ -25,7 +27,6 @@ require (
 	github.com/kr/text v0.1.0 // indirect
 	github.com/mattn/go-sqlite3 v1.14.22 // indirect
 	github.com/rogpeppe/go-internal v1.14.1 // indirect
-	golang.org/x/crypto v0.17.0 // indirect
 	golang.org/x/sync v0.9.0 // indirect
 	golang.org/x/text v0.20.0 // indirect
 )
@@ -2,11 +2,32 @@
 package main
 
 import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"openai-api/pkg/cli"
+	"openai-api/pkg/config"
 	"openai-api/pkg/server"
 )
 
 func main() {
-	// Start the HTTP server
+	// Load the settings from the config file, the environment and the flags
+	cfg, args, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
 
-	server.Start()
+	// Run an administrative command, e.g. "migrate up"
+	if len(args) > 0 {
+		os.Exit(cli.Run(cfg, args))
+	}
+
+	// Start the HTTP server
+	server.Start(cfg)
 }
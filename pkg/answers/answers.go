@@ -0,0 +1,394 @@
+// Package answers defines how answers to each question type are represented
+// and validated. Choice and text answers are strings, scale and number
+// answers are numbers, and multi-select and ranking answers are lists of options.
+package answers
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"openai-api/pkg/models"
+)
+
+// Answers maps question text, or a question ID, to an answer. Values are
+// strings, float64 numbers or string lists.
+type Answers map[string]interface{}
+
+// UnmarshalJSON decodes answers, turning JSON lists into string lists and
+// dropping null answers. Answers stored before typed questions were
+// introduced are plain strings and decode unchanged.
+func (a *Answers) UnmarshalJSON(data []byte) error {
+	var raw map[string]interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	decoded := make(Answers, len(raw))
+	for key, value := range raw {
+		switch value := value.(type) {
+		case nil:
+		case string, float64:
+			decoded[key] = value
+		case []interface{}:
+			list := make([]string, 0, len(value))
+			for _, item := range value {
+				text, ok := item.(string)
+				if !ok {
+					return fmt.Errorf("answer to %q is not a list of strings", key)
+				}
+				list = append(list, text)
+			}
+			decoded[key] = list
+		default:
+			return fmt.Errorf("answer to %q is not a string, number or list", key)
+		}
+	}
+	*a = decoded
+	return nil
+}
+
+// Parse decodes answers stored on a participant.
+func Parse(stored string) (Answers, error) {
+	var parsed Answers
+	if err := json.Unmarshal([]byte(stored), &parsed); err != nil {
+		return nil, err
+	}
+	return parsed, nil
+}
+
+// Lookup returns the answer to a question, keyed by its text or its ID.
+func (a Answers) Lookup(question models.Question) (interface{}, bool) {
+	if value, ok := a[question.QuestionText]; ok && !IsEmpty(value) {
+		return value, true
+	}
+	value, ok := a[strconv.FormatUint(uint64(question.ID), 10)]
+	return value, ok && !IsEmpty(value)
+}
+
+// Error codes, telling clients why an answer was rejected.
+const (
+	// CodeRequired means a required question was not answered, or no question was.
+	CodeRequired = "required"
+
+	// CodeUnknownQuestion means the answer key matches no question.
+	CodeUnknownQuestion = "unknown_question"
+
+	// CodeDuplicate means a question was answered both by its text and by its ID.
+	CodeDuplicate = "duplicate"
+
+	// CodeInvalid means the answer does not fit the question type, options or range.
+	CodeInvalid = "invalid"
+
+	// CodeTooLong means a text answer is longer than the maximum length.
+	CodeTooLong = "too_long"
+)
+
+// Error describes an invalid answer.
+type Error struct {
+	// Key is the answer key, normally the question text. It is empty if the
+	// error concerns the answers as a whole.
+	Key string
+
+	// QuestionID is the ID of the question, or zero for unknown questions.
+	QuestionID uint
+
+	// Code is one of the Code* constants.
+	Code string
+
+	// Reason says what is wrong with the answer.
+	Reason string
+}
+
+func (e *Error) Error() string {
+	if e.Key == "" {
+		return e.Reason
+	}
+	return fmt.Sprintf("answer to %q %s", e.Key, e.Reason)
+}
+
+// Errors lists every invalid answer of a submission.
+type Errors []*Error
+
+func (e Errors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, err := range e {
+		messages = append(messages, err.Error())
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Check validates submitted answers against the questions they answer and
+// returns them in canonical form, leaving out empty answers. Every key must
+// name one of the questions, every required question must be answered and
+// at least one question must be. Text answers may be at most maxTextLength
+// characters long. If any answer is invalid, it returns Errors listing all
+// of them in question order.
+func Check(questions []models.Question, submitted Answers, maxTextLength int) (Answers, error) {
+	lookup := make(map[string]models.Question, len(questions)*2)
+	for _, question := range questions {
+		lookup[question.QuestionText] = question
+		lookup[strconv.FormatUint(uint64(question.ID), 10)] = question
+	}
+
+	checked := make(Answers, len(submitted))
+	invalid := make(map[uint]*Error)
+	var unknown Errors
+	for key, value := range submitted {
+		if IsEmpty(value) {
+			continue
+		}
+		question, known := lookup[key]
+		if !known {
+			unknown = append(unknown, &Error{Key: key, Code: CodeUnknownQuestion, Reason: "does not match any question"})
+			continue
+		}
+		canonical, err := Validate(question, value, maxTextLength)
+		if err != nil {
+			err.Key, err.QuestionID = key, question.ID
+			invalid[question.ID] = err
+			continue
+		}
+		checked[key] = canonical
+	}
+
+	var errs Errors
+	answered := 0
+	for _, question := range questions {
+		if err, ok := invalid[question.ID]; ok {
+			errs = append(errs, err)
+			continue
+		}
+		_, byText := checked[question.QuestionText]
+		_, byID := checked[strconv.FormatUint(uint64(question.ID), 10)]
+		switch {
+		case byText && byID:
+			errs = append(errs, &Error{Key: question.QuestionText, QuestionID: question.ID, Code: CodeDuplicate, Reason: "is given twice, by question text and by ID"})
+		case byText || byID:
+			answered++
+		case question.Required:
+			errs = append(errs, &Error{Key: question.QuestionText, QuestionID: question.ID, Code: CodeRequired, Reason: "is required"})
+		}
+	}
+	sort.Slice(unknown, func(i, j int) bool { return unknown[i].Key < unknown[j].Key })
+	errs = append(errs, unknown...)
+	if len(errs) == 0 && answered == 0 {
+		errs = append(errs, &Error{Code: CodeRequired, Reason: "at least one question must be answered"})
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return checked, nil
+}
+
+// Validate checks an answer against its question and returns it in canonical
+// form: a trimmed string, a number or a list of trimmed options. Text
+// answers may be at most maxTextLength characters long.
+func Validate(question models.Question, value interface{}, maxTextLength int) (interface{}, *Error) {
+	options := Options(question)
+	switch Type(question) {
+	case models.QuestionTypeSingleChoice:
+		text, ok := value.(string)
+		text = strings.TrimSpace(text)
+		if !ok || (len(options) > 0 && !contains(options, text)) {
+			return nil, invalidf("must be one of the options")
+		}
+		return text, nil
+
+	case models.QuestionTypeMultiSelect:
+		list, ok := value.([]string)
+		if !ok {
+			return nil, invalidf("must be a list of options")
+		}
+		selected, err := distinctOptions(options, list)
+		if err != nil {
+			return nil, err
+		}
+		if question.Min != nil && float64(len(selected)) < *question.Min {
+			return nil, invalidf("must select at least %s options", formatNumber(*question.Min))
+		}
+		if question.Max != nil && float64(len(selected)) > *question.Max {
+			return nil, invalidf("must select at most %s options", formatNumber(*question.Max))
+		}
+		return selected, nil
+
+	case models.QuestionTypeScale:
+		number, ok := Number(value)
+		low, high := ScaleRange(question)
+		if !ok || number != math.Trunc(number) || number < low || number > high {
+			return nil, invalidf("must be a whole number from %s to %s", formatNumber(low), formatNumber(high))
+		}
+		return number, nil
+
+	case models.QuestionTypeNumber:
+		number, ok := Number(value)
+		if !ok {
+			return nil, invalidf("must be a number")
+		}
+		if question.Min != nil && number < *question.Min {
+			return nil, invalidf("must be at least %s", formatNumber(*question.Min))
+		}
+		if question.Max != nil && number > *question.Max {
+			return nil, invalidf("must be at most %s", formatNumber(*question.Max))
+		}
+		return number, nil
+
+	case models.QuestionTypeRanking:
+		list, ok := value.([]string)
+		if !ok {
+			return nil, invalidf("must be a list of options")
+		}
+		ranked, err := distinctOptions(options, list)
+		if err != nil {
+			return nil, err
+		}
+		if len(ranked) != len(options) {
+			return nil, invalidf("must rank every option")
+		}
+		return ranked, nil
+
+	default:
+		text, ok := value.(string)
+		if !ok {
+			return nil, invalidf("must be text")
+		}
+		text = strings.TrimSpace(text)
+		if utf8.RuneCountInString(text) > maxTextLength {
+			return nil, &Error{Code: CodeTooLong, Reason: fmt.Sprintf("must be at most %d characters", maxTextLength)}
+		}
+		return text, nil
+	}
+}
+
+// Type returns the type of a question. Questions without one, such as
+// those uploaded by older clients, are single choice if IsMultipleChoice is
+// set and have options, and free text otherwise.
+func Type(question models.Question) string {
+	if question.Type != "" {
+		return question.Type
+	}
+	if question.IsMultipleChoice && len(Options(question)) > 0 {
+		return models.QuestionTypeSingleChoice
+	}
+	return models.QuestionTypeText
+}
+
+// Options decodes a question's JSON options, returning nil if they are invalid.
+func Options(question models.Question) []string {
+	var options []string
+	if err := json.Unmarshal([]byte(question.Options), &options); err != nil {
+		return nil
+	}
+	return options
+}
+
+// ScaleRange returns the lowest and highest point of a scale question. A
+// scale with labels has a point per label starting at 1, otherwise it runs
+// from Min to Max, 1 to 5 by default.
+func ScaleRange(question models.Question) (low, high float64) {
+	if labels := Options(question); len(labels) > 0 {
+		return 1, float64(len(labels))
+	}
+	low, high = 1, 5
+	if question.Min != nil {
+		low = *question.Min
+	}
+	if question.Max != nil {
+		high = *question.Max
+	}
+	return low, high
+}
+
+// IsEmpty reports whether an answer is missing: nil, blank text or an empty list.
+func IsEmpty(value interface{}) bool {
+	switch value := value.(type) {
+	case nil:
+		return true
+	case string:
+		return strings.TrimSpace(value) == ""
+	case []string:
+		return len(value) == 0
+	}
+	return false
+}
+
+// Number returns an answer as a number. Numeric strings are accepted since
+// answers stored before typed questions were introduced are all strings.
+func Number(value interface{}) (float64, bool) {
+	switch value := value.(type) {
+	case float64:
+		return value, true
+	case string:
+		number, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		return number, err == nil && !math.IsInf(number, 0) && !math.IsNaN(number)
+	}
+	return 0, false
+}
+
+// List returns an answer as a list. A single string is a list of one.
+func List(value interface{}) []string {
+	switch value := value.(type) {
+	case []string:
+		return value
+	case string:
+		if text := strings.TrimSpace(value); text != "" {
+			return []string{text}
+		}
+	}
+	return nil
+}
+
+// Text renders an answer as text, joining lists with "、".
+func Text(value interface{}) string {
+	switch value := value.(type) {
+	case string:
+		return value
+	case float64:
+		return formatNumber(value)
+	case []string:
+		return strings.Join(value, "、")
+	}
+	return ""
+}
+
+// distinctOptions trims a list of selected options and checks that each is
+// one of the question's options and appears only once.
+func distinctOptions(options, list []string) ([]string, *Error) {
+	seen := make(map[string]bool, len(list))
+	selected := make([]string, 0, len(list))
+	for _, item := range list {
+		item = strings.TrimSpace(item)
+		if !contains(options, item) {
+			return nil, invalidf("must only contain the options")
+		}
+		if seen[item] {
+			return nil, invalidf("must not repeat an option")
+		}
+		seen[item] = true
+		selected = append(selected, item)
+	}
+	return selected, nil
+}
+
+// invalidf returns a CodeInvalid error with a formatted reason.
+func invalidf(format string, args ...interface{}) *Error {
+	return &Error{Code: CodeInvalid, Reason: fmt.Sprintf(format, args...)}
+}
+
+// contains reports whether list contains value.
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+// formatNumber formats a number without trailing zeros.
+func formatNumber(number float64) string {
+	return strconv.FormatFloat(number, 'f', -1, 64)
+}
@@ -0,0 +1,200 @@
+package answers
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"openai-api/pkg/models"
+)
+
+func number(n float64) *float64 { return &n }
+
+// testQuestions covers every question type.
+var testQuestions = []models.Question{
+	{ID: 1, QuestionText: "颜色", Type: models.QuestionTypeSingleChoice, Required: true, Options: `["红","蓝"]`},
+	{ID: 2, QuestionText: "爱好", Type: models.QuestionTypeMultiSelect, Options: `["读书","跑步","音乐"]`, Min: number(1), Max: number(2)},
+	{ID: 3, QuestionText: "心情", Type: models.QuestionTypeScale, Min: number(1), Max: number(5)},
+	{ID: 4, QuestionText: "年龄", Type: models.QuestionTypeNumber, Min: number(0), Max: number(150)},
+	{ID: 5, QuestionText: "排序", Type: models.QuestionTypeRanking, Options: `["甲","乙","丙"]`},
+	{ID: 6, QuestionText: "留言", Type: models.QuestionTypeText},
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name      string
+		submitted string
+		want      Answers
+		codes     []string // Codes of the errors, in order
+	}{
+		{
+			name:      "valid",
+			submitted: `{"颜色":" 红 ","2":["读书"],"心情":3,"年龄":"30","排序":["丙","甲","乙"],"留言":"  你好 ","6":""}`,
+			want:      Answers{"颜色": "红", "2": []string{"读书"}, "心情": 3.0, "年龄": 30.0, "排序": []string{"丙", "甲", "乙"}, "留言": "你好"},
+		},
+		{
+			name:      "duplicate by text and ID",
+			submitted: `{"颜色":"红","1":"蓝"}`,
+			codes:     []string{CodeDuplicate},
+		},
+		{
+			name:      "unknown keys are sorted after the questions",
+			submitted: `{"颜色":"红","z":"x","99":"y","心情":9}`,
+			codes:     []string{CodeInvalid, CodeUnknownQuestion, CodeUnknownQuestion},
+		},
+		{
+			name:      "required",
+			submitted: `{"心情":3}`,
+			codes:     []string{CodeRequired},
+		},
+		{
+			name:      "blank required answer",
+			submitted: `{"颜色":"  ","心情":3}`,
+			codes:     []string{CodeRequired},
+		},
+		{
+			name:      "nothing answered",
+			submitted: `{}`,
+			codes:     []string{CodeRequired},
+		},
+		{
+			name:      "incomplete ranking",
+			submitted: `{"颜色":"红","排序":["甲","乙"]}`,
+			codes:     []string{CodeInvalid},
+		},
+		{
+			name:      "text too long",
+			submitted: `{"颜色":"红","留言":"一二三四五六七八九十一"}`,
+			codes:     []string{CodeTooLong},
+		},
+		{
+			name:      "errors in question order",
+			submitted: `{"留言":"一二三四五六七八九十一","年龄":-1,"颜色":"绿"}`,
+			codes:     []string{CodeInvalid, CodeInvalid, CodeTooLong},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var submitted Answers
+			if err := json.Unmarshal([]byte(test.submitted), &submitted); err != nil {
+				t.Fatal(err)
+			}
+			got, err := Check(testQuestions, submitted, 10)
+			if test.codes == nil {
+				if err != nil || !reflect.DeepEqual(got, test.want) {
+					t.Errorf("got %#v, %v, want %#v", got, err, test.want)
+				}
+				return
+			}
+			var errs Errors
+			if !errors.As(err, &errs) {
+				t.Fatalf("got %#v, %v, want errors %v", got, err, test.codes)
+			}
+			var codes []string
+			for _, e := range errs {
+				codes = append(codes, e.Code)
+			}
+			if !reflect.DeepEqual(codes, test.codes) {
+				t.Errorf("got errors %v, want %v", errs, test.codes)
+			}
+		})
+	}
+}
+
+func TestCheckNothingAnswered(t *testing.T) {
+	_, err := Check(testQuestions[1:], Answers{"留言": " "}, 10)
+	var errs Errors
+	if !errors.As(err, &errs) || len(errs) != 1 || errs[0].Code != CodeRequired || errs[0].Key != "" {
+		t.Errorf("got %v, want an error about the answers as a whole", err)
+	}
+}
+
+func TestCheckErrorKeys(t *testing.T) {
+	_, err := Check(testQuestions, Answers{"1": "绿", "x": "y"}, 10)
+	var errs Errors
+	if !errors.As(err, &errs) || len(errs) != 2 {
+		t.Fatalf("got %v", err)
+	}
+	if errs[0].Key != "1" || errs[0].QuestionID != 1 {
+		t.Errorf("invalid answer: got key %q, question %d", errs[0].Key, errs[0].QuestionID)
+	}
+	if errs[1].Key != "x" || errs[1].QuestionID != 0 {
+		t.Errorf("unknown question: got key %q, question %d", errs[1].Key, errs[1].QuestionID)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		question models.Question
+		value    interface{}
+		want     interface{}
+		code     string
+	}{
+		{"choice", testQuestions[0], "蓝", "蓝", ""},
+		{"choice not an option", testQuestions[0], "绿", nil, CodeInvalid},
+		{"choice not text", testQuestions[0], 1.0, nil, CodeInvalid},
+		{"legacy choice", models.Question{IsMultipleChoice: true, Options: `["是","否"]`}, "否", "否", ""},
+		{"legacy text", models.Question{IsMultipleChoice: true}, "随便", "随便", ""},
+
+		{"multi-select", testQuestions[1], []string{" 跑步", "音乐"}, []string{"跑步", "音乐"}, ""},
+		{"multi-select too few", testQuestions[1], []string{}, nil, CodeInvalid},
+		{"multi-select too many", testQuestions[1], []string{"读书", "跑步", "音乐"}, nil, CodeInvalid},
+		{"multi-select repeated", testQuestions[1], []string{"读书", "读书"}, nil, CodeInvalid},
+		{"multi-select not an option", testQuestions[1], []string{"游泳"}, nil, CodeInvalid},
+		{"multi-select not a list", testQuestions[1], "读书", nil, CodeInvalid},
+
+		{"scale low", testQuestions[2], 1.0, 1.0, ""},
+		{"scale high", testQuestions[2], "5", 5.0, ""},
+		{"scale below", testQuestions[2], 0.0, nil, CodeInvalid},
+		{"scale above", testQuestions[2], 6.0, nil, CodeInvalid},
+		{"scale fraction", testQuestions[2], 2.5, nil, CodeInvalid},
+		{"scale labels", models.Question{Type: models.QuestionTypeScale, Options: `["低","中","高"]`}, 3.0, 3.0, ""},
+		{"scale beyond labels", models.Question{Type: models.QuestionTypeScale, Options: `["低","中","高"]`}, 4.0, nil, CodeInvalid},
+		{"scale default range", models.Question{Type: models.QuestionTypeScale}, 6.0, nil, CodeInvalid},
+
+		{"number", testQuestions[3], 42.5, 42.5, ""},
+		{"number below", testQuestions[3], -1.0, nil, CodeInvalid},
+		{"number above", testQuestions[3], 151.0, nil, CodeInvalid},
+		{"number not numeric", testQuestions[3], "abc", nil, CodeInvalid},
+
+		{"ranking", testQuestions[4], []string{"乙", "丙", "甲"}, []string{"乙", "丙", "甲"}, ""},
+		{"ranking incomplete", testQuestions[4], []string{"乙", "丙"}, nil, CodeInvalid},
+		{"ranking repeated", testQuestions[4], []string{"乙", "乙", "甲"}, nil, CodeInvalid},
+
+		{"text", testQuestions[5], " 你好 ", "你好", ""},
+		{"text at limit", testQuestions[5], "一二三四五六七八九十", "一二三四五六七八九十", ""},
+		{"text too long", testQuestions[5], "一二三四五六七八九十一", nil, CodeTooLong},
+		{"text not text", testQuestions[5], 1.0, nil, CodeInvalid},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := Validate(test.question, test.value, 10)
+			switch {
+			case test.code == "" && err != nil:
+				t.Errorf("got error %v", err)
+			case test.code != "" && (err == nil || err.Code != test.code):
+				t.Errorf("got %#v, %v, want %s", got, err, test.code)
+			case test.code == "" && !reflect.DeepEqual(got, test.want):
+				t.Errorf("got %#v, want %#v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestUnmarshalJSON(t *testing.T) {
+	var got Answers
+	if err := json.Unmarshal([]byte(`{"a":"x","b":2,"c":["y","z"],"d":null}`), &got); err != nil {
+		t.Fatal(err)
+	}
+	want := Answers{"a": "x", "b": 2.0, "c": []string{"y", "z"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#v, want %#v", got, want)
+	}
+	for _, invalid := range []string{`{"a":[1]}`, `{"a":{}}`, `{"a":true}`} {
+		if err := json.Unmarshal([]byte(invalid), &got); err == nil {
+			t.Errorf("%s: no error", invalid)
+		}
+	}
+}
@@ -0,0 +1,265 @@
+// Package anthropic provides a client for the Anthropic Messages API.
+package anthropic
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"openai-api/pkg/llm"
+)
+
+// defaultMaxTokens is used when a request does not set MaxTokens, which the API requires.
+const defaultMaxTokens = 4096
+
+// Client is an Anthropic Messages API client.
+// It is safe for concurrent use by multiple goroutines.
+type Client struct {
+	// config holds the client's configuration.
+	config *Config
+
+	// httpClient is the HTTP client used to send requests.
+	httpClient *http.Client
+}
+
+// Client implements llm.Provider.
+var _ llm.Provider = (*Client)(nil)
+
+// NewClient creates a new Anthropic API client with the given config.
+func NewClient(config *Config) *Client {
+	return &Client{
+		config:     config,
+		httpClient: &http.Client{},
+	}
+}
+
+// Name returns "anthropic".
+func (c *Client) Name() string {
+	return "anthropic"
+}
+
+// Messages sends a request to the Messages API and returns the whole response.
+func (c *Client) Messages(ctx context.Context, request *MessagesRequest) (*MessagesResponse, error) {
+	request.Stream = false
+	resp, err := c.send(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var response MessagesResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+	return &response, nil
+}
+
+// Complete sends a provider-neutral request to the Messages API.
+func (c *Client) Complete(ctx context.Context, request *llm.Request) (*llm.Response, error) {
+	response, err := c.Messages(ctx, c.toMessagesRequest(request))
+	if err != nil {
+		return nil, err
+	}
+
+	var builder strings.Builder
+	for _, block := range response.Content {
+		if block.Type == "text" {
+			builder.WriteString(block.Text)
+		}
+	}
+	return &llm.Response{
+		Content:      builder.String(),
+		Model:        response.Model,
+		FinishReason: response.StopReason,
+	}, nil
+}
+
+// Stream sends a provider-neutral request to the Messages API and relays text deltas to onDelta.
+func (c *Client) Stream(ctx context.Context, request *llm.Request, onDelta func(string)) (*llm.Response, error) {
+	messagesRequest := c.toMessagesRequest(request)
+	messagesRequest.Stream = true
+	resp, err := c.send(ctx, messagesRequest)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var builder strings.Builder
+	response := &llm.Response{}
+	reader := bufio.NewReader(resp.Body)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil && (err != io.EOF || len(line) == 0) {
+			if err == io.EOF {
+				break
+			}
+			if llm.IsTimeout(err) {
+				return nil, fmt.Errorf("%w: %w", llm.ErrTimeout, err)
+			}
+			return nil, fmt.Errorf("failed to read Anthropic stream: %w", err)
+		}
+
+		// Every event type repeats its name in the JSON payload, so only data lines matter
+		line = bytes.TrimSpace(line)
+		if !bytes.HasPrefix(line, []byte("data:")) {
+			continue
+		}
+		var event StreamEvent
+		if err := json.Unmarshal(bytes.TrimSpace(bytes.TrimPrefix(line, []byte("data:"))), &event); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal stream event: %w", err)
+		}
+
+		switch event.Type {
+		case "message_start":
+			if event.Message != nil {
+				response.Model = event.Message.Model
+			}
+		case "content_block_delta":
+			if event.Delta != nil && event.Delta.Type == "text_delta" {
+				builder.WriteString(event.Delta.Text)
+				if onDelta != nil {
+					onDelta(event.Delta.Text)
+				}
+			}
+		case "message_delta":
+			if event.Delta != nil {
+				response.FinishReason = event.Delta.StopReason
+			}
+		case "error":
+			return nil, streamError(event.Error)
+		case "message_stop":
+			response.Content = builder.String()
+			return response, nil
+		}
+	}
+
+	response.Content = builder.String()
+	return response, nil
+}
+
+// send posts a Messages API request, retrying according to the retry policy.
+func (c *Client) send(ctx context.Context, request *MessagesRequest) (*http.Response, error) {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	// Determine the API endpoint
+	apiBase := c.config.APIBase
+	if apiBase == "" {
+		apiBase = "https://api.anthropic.com/v1"
+	}
+	url := fmt.Sprintf("%s/messages", apiBase)
+
+	version := c.config.Version
+	if version == "" {
+		version = DefaultVersion
+	}
+
+	return llm.Send(ctx, c.httpClient, c.config.RetryPolicy, func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+
+		// Set headers
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("x-api-key", c.config.APIKey)
+		req.Header.Set("anthropic-version", version)
+		return req, nil
+	}, newAPIError)
+}
+
+// toMessagesRequest converts a provider-neutral request to the Messages API format.
+func (c *Client) toMessagesRequest(request *llm.Request) *MessagesRequest {
+	model := request.Model
+	if model == "" {
+		model = c.config.Model
+	}
+	maxTokens := request.MaxTokens
+	if maxTokens <= 0 {
+		maxTokens = defaultMaxTokens
+	}
+
+	messages := make([]Message, 0, len(request.Messages))
+	for _, message := range request.Messages {
+		messages = append(messages, Message{Role: message.Role, Content: message.Content})
+	}
+
+	// The Messages API has no response format, so describe it in the system prompt
+	system := request.System
+	if format := request.ResponseFormat; format != nil {
+		instruction := "Respond with a single JSON object and nothing else."
+		if format.Schema != nil {
+			if schema, err := json.Marshal(format.Schema); err == nil {
+				instruction = fmt.Sprintf("Respond with a single JSON object matching this JSON schema and nothing else:\n%s", schema)
+			}
+		}
+		system = strings.TrimSpace(system + "\n\n" + instruction)
+	}
+
+	return &MessagesRequest{
+		Model:       model,
+		MaxTokens:   maxTokens,
+		System:      system,
+		Messages:    messages,
+		Temperature: request.Temperature,
+	}
+}
+
+// errorResponse is the error body returned by the Messages API.
+type errorResponse struct {
+	Type  string      `json:"type"`
+	Error ErrorDetail `json:"error"`
+}
+
+// newAPIError builds an llm.APIError from a non-200 response and its body.
+func newAPIError(resp *http.Response, body []byte) error {
+	apiErr := &llm.APIError{
+		Provider:   "anthropic",
+		StatusCode: resp.StatusCode,
+		RetryAfter: llm.ParseRetryAfter(resp.Header.Get("Retry-After")),
+	}
+
+	var parsed errorResponse
+	if err := json.Unmarshal(body, &parsed); err == nil && parsed.Error.Message != "" {
+		apiErr.Type = parsed.Error.Type
+		apiErr.Message = parsed.Error.Message
+	} else {
+		apiErr.Message = strings.TrimSpace(string(body))
+	}
+	return apiErr
+}
+
+// streamError converts an error event received mid-stream into an llm.APIError.
+// The HTTP status is already 200 at that point, so it is inferred from the error type.
+func streamError(detail *ErrorDetail) error {
+	if detail == nil {
+		return fmt.Errorf("anthropic stream failed with an unknown error")
+	}
+
+	status := http.StatusInternalServerError
+	switch detail.Type {
+	case "rate_limit_error":
+		status = http.StatusTooManyRequests
+	case "overloaded_error":
+		status = 529
+	case "invalid_request_error":
+		status = http.StatusBadRequest
+	case "authentication_error":
+		status = http.StatusUnauthorized
+	case "permission_error":
+		status = http.StatusForbidden
+	}
+	return &llm.APIError{
+		Provider:   "anthropic",
+		StatusCode: status,
+		Type:       detail.Type,
+		Message:    detail.Message,
+	}
+}
@@ -0,0 +1,71 @@
+// Package anthropic provides a client for the Anthropic Messages API.
+package anthropic
+
+import (
+	"openai-api/pkg/llm"
+)
+
+// DefaultVersion is the Anthropic API version sent when none is configured.
+const DefaultVersion = "2023-06-01"
+
+// Config holds the configuration for the Anthropic client.
+type Config struct {
+	// APIKey is the API key sent in the x-api-key header.
+	APIKey string
+
+	// APIBase is the base URL for the API.
+	// If not set, it defaults to "https://api.anthropic.com/v1".
+	APIBase string
+
+	// Version is the value of the anthropic-version header.
+	// If not set, it defaults to DefaultVersion.
+	Version string
+
+	// Model is the default model used when a request does not name one.
+	Model string
+
+	// RetryPolicy controls how failed requests are retried.
+	// The zero value disables retries.
+	RetryPolicy llm.RetryPolicy
+}
+
+// NewConfig creates a new Config with default values: no API key, the
+// default base URL and version, and llm.DefaultRetryPolicy.
+func NewConfig() *Config {
+	return &Config{
+		RetryPolicy: llm.DefaultRetryPolicy(),
+	}
+}
+
+// WithAPIKey sets the API key for the Config.
+func (c *Config) WithAPIKey(apiKey string) *Config {
+	c.APIKey = apiKey
+	return c
+}
+
+// WithAPIBase sets the API base URL for the Config.
+// If not set, it defaults to "https://api.anthropic.com/v1".
+func (c *Config) WithAPIBase(apiBase string) *Config {
+	c.APIBase = apiBase
+	return c
+}
+
+// WithVersion sets the anthropic-version header for the Config.
+// If not set, it defaults to DefaultVersion.
+func (c *Config) WithVersion(version string) *Config {
+	c.Version = version
+	return c
+}
+
+// WithModel sets the default model for the Config.
+func (c *Config) WithModel(model string) *Config {
+	c.Model = model
+	return c
+}
+
+// WithRetryPolicy sets the retry policy for the Config.
+// Pass llm.RetryPolicy{} to disable retries.
+func (c *Config) WithRetryPolicy(policy llm.RetryPolicy) *Config {
+	c.RetryPolicy = policy
+	return c
+}
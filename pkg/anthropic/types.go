@@ -0,0 +1,111 @@
+// Package anthropic provides a client for the Anthropic Messages API.
+package anthropic
+
+// MessagesRequest represents a request to the Messages API.
+type MessagesRequest struct {
+	// Model is the name of the model to use.
+	Model string `json:"model"`
+
+	// MaxTokens is the maximum number of tokens to generate. It is required by the API.
+	MaxTokens int `json:"max_tokens"`
+
+	// System is the system prompt. Unlike OpenAI it is not part of the messages list.
+	System string `json:"system,omitempty"`
+
+	// Messages is the conversation so far, alternating "user" and "assistant" turns.
+	Messages []Message `json:"messages"`
+
+	// Temperature controls the randomness of the output, between 0 and 1.
+	Temperature float64 `json:"temperature,omitempty"`
+
+	// Stream indicates whether to stream the response as server-sent events.
+	Stream bool `json:"stream,omitempty"`
+}
+
+// Message represents a single message in a conversation.
+type Message struct {
+	// Role is either "user" or "assistant".
+	Role string `json:"role"`
+
+	// Content is the text of the message.
+	Content string `json:"content"`
+}
+
+// MessagesResponse represents a response from the Messages API.
+type MessagesResponse struct {
+	// ID is a unique identifier for the message.
+	ID string `json:"id"`
+
+	// Type is always "message".
+	Type string `json:"type"`
+
+	// Role is always "assistant".
+	Role string `json:"role"`
+
+	// Content is the list of content blocks generated by the model.
+	Content []ContentBlock `json:"content"`
+
+	// Model is the name of the model that generated the message.
+	Model string `json:"model"`
+
+	// StopReason is the reason the model stopped, e.g. "end_turn" or "max_tokens".
+	StopReason string `json:"stop_reason"`
+
+	// Usage is the token usage statistics for the message.
+	Usage Usage `json:"usage"`
+}
+
+// ContentBlock is a single block of generated content.
+type ContentBlock struct {
+	// Type is the block type; only "text" blocks are used by this client.
+	Type string `json:"type"`
+
+	// Text is the generated text of a "text" block.
+	Text string `json:"text"`
+}
+
+// Usage represents the token usage of a message.
+type Usage struct {
+	// InputTokens is the number of tokens in the prompt.
+	InputTokens int `json:"input_tokens"`
+
+	// OutputTokens is the number of generated tokens.
+	OutputTokens int `json:"output_tokens"`
+}
+
+// StreamEvent is the payload of a server-sent event in a streamed response.
+// Only the fields used by this client are decoded.
+type StreamEvent struct {
+	// Type is the event type, e.g. "message_start" or "content_block_delta".
+	Type string `json:"type"`
+
+	// Message is set on "message_start" events.
+	Message *MessagesResponse `json:"message,omitempty"`
+
+	// Delta is set on "content_block_delta" and "message_delta" events.
+	Delta *StreamDelta `json:"delta,omitempty"`
+
+	// Error is set on "error" events.
+	Error *ErrorDetail `json:"error,omitempty"`
+}
+
+// StreamDelta is an incremental update in a streamed response.
+type StreamDelta struct {
+	// Type is "text_delta" for content deltas.
+	Type string `json:"type"`
+
+	// Text is the newly generated text of a "text_delta".
+	Text string `json:"text"`
+
+	// StopReason is set on the final "message_delta" event.
+	StopReason string `json:"stop_reason"`
+}
+
+// ErrorDetail describes an API error.
+type ErrorDetail struct {
+	// Type is the error type, e.g. "rate_limit_error" or "overloaded_error".
+	Type string `json:"type"`
+
+	// Message is the human readable error message.
+	Message string `json:"message"`
+}
@@ -0,0 +1,175 @@
+// Package apierror writes the JSON error responses of the API and tags every
+// request with an ID that is returned to the client and logged with failures.
+package apierror
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/http"
+	"regexp"
+)
+
+// RequestIDHeader carries the request ID. A valid ID sent by the client or a
+// proxy is kept, otherwise a new one is generated.
+const RequestIDHeader = "X-Request-ID"
+
+// Error codes. Clients should branch on the code rather than the message,
+// which is meant for people and may change. The catalogue is documented in
+// the README.
+const (
+	// CodeInvalidRequest means the request body or a query parameter is malformed.
+	CodeInvalidRequest = "invalid_request"
+
+	// CodeRequestTooLarge means the request body is larger than MAX_REQUEST_BYTES.
+	CodeRequestTooLarge = "request_too_large"
+
+	// CodeValidationFailed means some fields are invalid. The details list them.
+	CodeValidationFailed = "validation_failed"
+
+	// CodeUnauthorized means the request needs an administrator.
+	CodeUnauthorized = "unauthorized"
+
+	// CodeInvalidCredentials means the username or password is wrong.
+	CodeInvalidCredentials = "invalid_credentials"
+
+	// CodeNotFound means no endpoint matches the path and method.
+	CodeNotFound = "not_found"
+
+	// CodeSessionNotFound means no session has the given token.
+	CodeSessionNotFound = "session_not_found"
+
+	// CodeTokenExpired means the token has expired.
+	CodeTokenExpired = "token_expired"
+
+	// CodeTokenRevoked means the token has been revoked.
+	CodeTokenRevoked = "token_revoked"
+
+	// CodeWrongTokenPurpose means the token does not grant what was asked,
+	// e.g. an invitation token was used to view the results.
+	CodeWrongTokenPurpose = "wrong_token_purpose"
+
+	// CodeWrongSessionKind means the token belongs to a group session where a
+	// pair session is expected, or the other way around.
+	CodeWrongSessionKind = "wrong_session_kind"
+
+	// CodeAwaitingPartner means User B has not submitted answers yet.
+	CodeAwaitingPartner = "awaiting_partner"
+
+	// CodeAnalysisNotStarted means the group is still waiting for members.
+	CodeAnalysisNotStarted = "analysis_not_started"
+
+	// CodeAnalysisStarted means the group analysis has already started.
+	CodeAnalysisStarted = "analysis_started"
+
+	// CodeAlreadySubmitted means User B has already submitted answers, and
+	// may not revise them.
+	CodeAlreadySubmitted = "already_submitted"
+
+	// CodeAnalysisRunning means the answers cannot be revised or erased
+	// while the analysis runs.
+	CodeAnalysisRunning = "analysis_running"
+
+	// CodeDataErased means a participant erased their answers, which closed
+	// the session.
+	CodeDataErased = "data_erased"
+
+	// CodeIdempotencyKeyReused means the Idempotency-Key was sent before with
+	// a different request body.
+	CodeIdempotencyKeyReused = "idempotency_key_reused"
+
+	// CodeRequestInProgress means a request with the same Idempotency-Key is
+	// still being processed.
+	CodeRequestInProgress = "request_in_progress"
+
+	// CodeGroupFull means every invited member has already joined.
+	CodeGroupFull = "group_full"
+
+	// CodeNoMembers means no member has joined the group yet.
+	CodeNoMembers = "no_members"
+
+	// CodeQuestionnaireNotFound means no questionnaire has the given slug.
+	CodeQuestionnaireNotFound = "questionnaire_not_found"
+
+	// CodeQuestionSetNotFound means the questionnaire has no such question set version.
+	CodeQuestionSetNotFound = "question_set_not_found"
+
+	// CodeQuestionNotFound means the question bank has no question with the given ID.
+	CodeQuestionNotFound = "question_not_found"
+
+	// CodeSlugTaken means a questionnaire with the slug already exists.
+	CodeSlugTaken = "slug_taken"
+
+	// CodeQuestionBankEmpty means there are no questions to publish.
+	CodeQuestionBankEmpty = "question_bank_empty"
+
+	// CodeInternal means the server failed. Report the request ID when asking for help.
+	CodeInternal = "internal_error"
+)
+
+// requestIDPattern matches request IDs accepted from clients.
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// Response is the body of every error response.
+type Response struct {
+	Error Body `json:"error"`
+}
+
+// Body describes an error. Details depend on the code, e.g. the invalid
+// fields for CodeValidationFailed.
+type Body struct {
+	Code      string      `json:"code"`
+	Message   string      `json:"message"`
+	Details   interface{} `json:"details,omitempty"`
+	RequestID string      `json:"requestId,omitempty"`
+}
+
+// Write writes an error response.
+func Write(w http.ResponseWriter, status int, code, message string) {
+	WriteDetails(w, status, code, message, nil)
+}
+
+// WriteDetails writes an error response with details.
+func WriteDetails(w http.ResponseWriter, status int, code, message string, details interface{}) {
+	requestID := w.Header().Get(RequestIDHeader)
+	if status >= http.StatusInternalServerError {
+		log.Printf("Request %s failed with %d: %s", requestID, status, message)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(Response{Error: Body{
+		Code:      code,
+		Message:   message,
+		Details:   details,
+		RequestID: requestID,
+	}})
+}
+
+// NotFound is the handler for requests no endpoint matches.
+func NotFound(w http.ResponseWriter, r *http.Request) {
+	Write(w, http.StatusNotFound, CodeNotFound, "Not found")
+}
+
+// RequestID is middleware that sets the request ID response header, which
+// Write includes in error responses.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(RequestIDHeader)
+		if !requestIDPattern.MatchString(requestID) {
+			requestID = newRequestID()
+		}
+		w.Header().Set(RequestIDHeader, requestID)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// newRequestID returns a random request ID.
+func newRequestID() string {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(buf)
+}
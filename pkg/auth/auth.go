@@ -0,0 +1,266 @@
+// Package auth authenticates the administrators who manage the question bank.
+// Administrators authenticate with an API key in the Authorization header or
+// with the session cookie set by logging in. Passwords are stored as bcrypt
+// hashes; API keys and session tokens are random, so SHA-256 is enough.
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"openai-api/pkg/apierror"
+	"openai-api/pkg/models"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// SessionCookie is the name of the cookie holding an administrator's session token.
+const SessionCookie = "cyberqa_admin"
+
+// apiKeyPrefix starts every API key, so leaked keys are easy to recognise.
+const apiKeyPrefix = "cqa_"
+
+// MinPasswordLength is the shortest password accepted for an administrator.
+const MinPasswordLength = 12
+
+var (
+	// ErrInvalidCredentials is returned when a username, password, API key
+	// or session token is wrong, expired or revoked.
+	ErrInvalidCredentials = errors.New("invalid credentials")
+
+	// ErrWeakPassword is returned when a password is shorter than MinPasswordLength.
+	ErrWeakPassword = errors.New("password must be at least 12 characters")
+)
+
+// dummyHash is compared against when a login names an unknown user, so that
+// unknown and known usernames take the same time to reject.
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("cyberqa-dummy-password"), bcrypt.DefaultCost)
+
+// DefaultSessionDuration is how long an administrator's session lasts unless
+// the Service says otherwise.
+const DefaultSessionDuration = 12 * time.Hour
+
+type contextKey struct{}
+
+// Service authenticates administrators and manages their sessions and API
+// keys, stored in DB.
+type Service struct {
+	DB *gorm.DB
+
+	// SessionDuration is how long a session started by Login lasts.
+	SessionDuration time.Duration
+}
+
+// NewService returns a Service storing administrators in db, with sessions
+// lasting DefaultSessionDuration.
+func NewService(db *gorm.DB) *Service {
+	return &Service{DB: db, SessionDuration: DefaultSessionDuration}
+}
+
+// RequireAdmin wraps a handler so that it is only called for authenticated
+// administrators, and responds 401 otherwise. The handler can retrieve the
+// administrator with AdminFromContext.
+func (s *Service) RequireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		admin, err := s.Authenticate(r)
+		if err != nil {
+			if !errors.Is(err, ErrInvalidCredentials) {
+				apierror.Write(w, http.StatusInternalServerError, apierror.CodeInternal, "Failed to authenticate")
+				return
+			}
+			w.Header().Set("WWW-Authenticate", `Bearer realm="cyberqa"`)
+			apierror.Write(w, http.StatusUnauthorized, apierror.CodeUnauthorized, "Unauthorized")
+			return
+		}
+		next(w, r.WithContext(context.WithValue(r.Context(), contextKey{}, admin)))
+	}
+}
+
+// AdminFromContext returns the administrator authenticated by RequireAdmin.
+func AdminFromContext(ctx context.Context) (*models.AdminUser, bool) {
+	admin, ok := ctx.Value(contextKey{}).(*models.AdminUser)
+	return admin, ok
+}
+
+// Authenticate returns the administrator a request is authenticated as,
+// using the API key in the Authorization header if there is one and the
+// session cookie otherwise.
+func (s *Service) Authenticate(r *http.Request) (*models.AdminUser, error) {
+	if header := r.Header.Get("Authorization"); header != "" {
+		key, ok := strings.CutPrefix(header, "Bearer ")
+		if !ok {
+			return nil, ErrInvalidCredentials
+		}
+		return s.authenticateAPIKey(strings.TrimSpace(key))
+	}
+	if cookie, err := r.Cookie(SessionCookie); err == nil {
+		return s.authenticateSession(cookie.Value)
+	}
+	return nil, ErrInvalidCredentials
+}
+
+// authenticateAPIKey returns the administrator an API key belongs to.
+func (s *Service) authenticateAPIKey(key string) (*models.AdminUser, error) {
+	if !strings.HasPrefix(key, apiKeyPrefix) {
+		return nil, ErrInvalidCredentials
+	}
+	var apiKey models.APIKey
+	err := s.DB.Joins("AdminUser").Where("key_hash = ?", HashToken(key)).First(&apiKey).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+	if apiKey.AdminUser.ID == 0 {
+		return nil, ErrInvalidCredentials
+	}
+
+	now := time.Now()
+	s.DB.Model(&apiKey).UpdateColumn("last_used_at", &now)
+	return &apiKey.AdminUser, nil
+}
+
+// authenticateSession returns the administrator a session token belongs to.
+func (s *Service) authenticateSession(token string) (*models.AdminUser, error) {
+	if token == "" {
+		return nil, ErrInvalidCredentials
+	}
+	var session models.AdminSession
+	err := s.DB.Joins("AdminUser").
+		Where("token_hash = ? AND expires_at > ?", HashToken(token), time.Now()).
+		First(&session).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+	if session.AdminUser.ID == 0 {
+		return nil, ErrInvalidCredentials
+	}
+	return &session.AdminUser, nil
+}
+
+// Login checks an administrator's username and password and starts a new
+// session. It returns the session token to set as the SessionCookie and when
+// it expires.
+func (s *Service) Login(username, password string) (*models.AdminUser, string, time.Time, error) {
+	var admin models.AdminUser
+	err := s.DB.Where("username = ?", username).First(&admin).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+		return nil, "", time.Time{}, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, "", time.Time{}, err
+	}
+	if bcrypt.CompareHashAndPassword([]byte(admin.PasswordHash), []byte(password)) != nil {
+		return nil, "", time.Time{}, ErrInvalidCredentials
+	}
+
+	token, err := RandomToken()
+	if err != nil {
+		return nil, "", time.Time{}, err
+	}
+	session := models.AdminSession{
+		AdminUserID: admin.ID,
+		TokenHash:   HashToken(token),
+		ExpiresAt:   time.Now().Add(s.SessionDuration),
+	}
+	if err := s.DB.Create(&session).Error; err != nil {
+		return nil, "", time.Time{}, err
+	}
+
+	// Expired sessions are never used again, so clean them up while we are here
+	s.DB.Unscoped().Where("expires_at <= ?", time.Now()).Delete(&models.AdminSession{})
+	return &admin, token, session.ExpiresAt, nil
+}
+
+// Logout ends the session with the given token.
+func (s *Service) Logout(token string) error {
+	return s.DB.Unscoped().Where("token_hash = ?", HashToken(token)).Delete(&models.AdminSession{}).Error
+}
+
+// CreateAdmin creates an administrator with the given password.
+func (s *Service) CreateAdmin(username, password string) (*models.AdminUser, error) {
+	hash, err := HashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+	admin := models.AdminUser{Username: username, PasswordHash: hash}
+	if err := s.DB.Create(&admin).Error; err != nil {
+		return nil, err
+	}
+	return &admin, nil
+}
+
+// SetPassword changes an administrator's password and logs out all of their sessions.
+func (s *Service) SetPassword(admin *models.AdminUser, password string) error {
+	hash, err := HashPassword(password)
+	if err != nil {
+		return err
+	}
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(admin).Update("password_hash", hash).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Where("admin_user_id = ?", admin.ID).Delete(&models.AdminSession{}).Error
+	})
+}
+
+// HashPassword returns the bcrypt hash of a password.
+func HashPassword(password string) (string, error) {
+	if len(password) < MinPasswordLength {
+		return "", ErrWeakPassword
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// CreateAPIKey creates a new API key for an administrator. The returned key
+// is only available now; the database keeps its hash.
+func (s *Service) CreateAPIKey(admin *models.AdminUser, name string) (string, *models.APIKey, error) {
+	token, err := RandomToken()
+	if err != nil {
+		return "", nil, err
+	}
+	key := apiKeyPrefix + token
+	apiKey := models.APIKey{
+		AdminUserID: admin.ID,
+		Name:        name,
+		Prefix:      key[:len(apiKeyPrefix)+8],
+		KeyHash:     HashToken(key),
+	}
+	if err := s.DB.Create(&apiKey).Error; err != nil {
+		return "", nil, err
+	}
+	return key, &apiKey, nil
+}
+
+// RandomToken returns 32 random bytes, hex encoded, for use as an API key or
+// any other secret token.
+func RandomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// HashToken returns the hex-encoded SHA-256 of a token from RandomToken, as
+// stored in place of the token.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
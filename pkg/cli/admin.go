@@ -0,0 +1,195 @@
+package cli
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"openai-api/pkg/auth"
+	"openai-api/pkg/config"
+	"openai-api/pkg/database"
+	"openai-api/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+// runAdmin implements the admin subcommand.
+func runAdmin(cfg *config.Config, args []string) int {
+	if len(args) == 0 {
+		usage(os.Stderr)
+		return 2
+	}
+
+	var run func(service *auth.Service, args []string) error
+	var nargs []int
+	switch args[0] {
+	case "create":
+		run, nargs = adminCreate, []int{1}
+	case "passwd":
+		run, nargs = adminPasswd, []int{1}
+	case "list":
+		run, nargs = adminList, []int{0}
+	case "create-key":
+		run, nargs = adminCreateKey, []int{1, 2}
+	case "list-keys":
+		run, nargs = adminListKeys, []int{0}
+	case "revoke-key":
+		run, nargs = adminRevokeKey, []int{1}
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown admin command %q\n\n", args[0])
+		usage(os.Stderr)
+		return 2
+	}
+	if n := len(args) - 1; n < nargs[0] || n > nargs[len(nargs)-1] {
+		usage(os.Stderr)
+		return 2
+	}
+
+	db, err := database.Open(cfg.Database.DSN)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to connect to database: %v\n", err)
+		return 1
+	}
+	if err := run(auth.NewService(db), args[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// adminCreate creates an administrator.
+func adminCreate(service *auth.Service, args []string) error {
+	password, err := readPassword()
+	if err != nil {
+		return err
+	}
+	admin, err := service.CreateAdmin(args[0], password)
+	if err != nil {
+		return fmt.Errorf("failed to create admin %q: %w", args[0], err)
+	}
+	fmt.Printf("Created admin %s\n", admin.Username)
+	return nil
+}
+
+// adminPasswd changes an administrator's password.
+func adminPasswd(service *auth.Service, args []string) error {
+	admin, err := findAdmin(service.DB, args[0])
+	if err != nil {
+		return err
+	}
+	password, err := readPassword()
+	if err != nil {
+		return err
+	}
+	if err := service.SetPassword(admin, password); err != nil {
+		return fmt.Errorf("failed to change password of admin %q: %w", admin.Username, err)
+	}
+	fmt.Printf("Changed password of admin %s and logged out their sessions\n", admin.Username)
+	return nil
+}
+
+// adminList prints every administrator.
+func adminList(service *auth.Service, args []string) error {
+	var admins []models.AdminUser
+	if err := service.DB.Order("username").Find(&admins).Error; err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "USERNAME\tCREATED AT")
+	for _, admin := range admins {
+		fmt.Fprintf(w, "%s\t%s\n", admin.Username, admin.CreatedAt.Format("2006-01-02 15:04:05"))
+	}
+	return w.Flush()
+}
+
+// adminCreateKey creates an API key for an administrator and prints it.
+func adminCreateKey(service *auth.Service, args []string) error {
+	admin, err := findAdmin(service.DB, args[0])
+	if err != nil {
+		return err
+	}
+	name := ""
+	if len(args) > 1 {
+		name = args[1]
+	}
+	key, _, err := service.CreateAPIKey(admin, name)
+	if err != nil {
+		return fmt.Errorf("failed to create API key: %w", err)
+	}
+	fmt.Fprintln(os.Stderr, "Store this key now, it cannot be shown again:")
+	fmt.Println(key)
+	return nil
+}
+
+// adminListKeys prints every API key that has not been revoked.
+func adminListKeys(service *auth.Service, args []string) error {
+	var keys []models.APIKey
+	if err := service.DB.Joins("AdminUser").Order("api_keys.id").Find(&keys).Error; err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PREFIX\tADMIN\tNAME\tCREATED AT\tLAST USED")
+	for _, key := range keys {
+		lastUsed := "never"
+		if key.LastUsedAt != nil {
+			lastUsed = key.LastUsedAt.Format("2006-01-02 15:04:05")
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", key.Prefix, key.AdminUser.Username, key.Name,
+			key.CreatedAt.Format("2006-01-02 15:04:05"), lastUsed)
+	}
+	return w.Flush()
+}
+
+// adminRevokeKey revokes the API key with the given prefix.
+func adminRevokeKey(service *auth.Service, args []string) error {
+	result := service.DB.Where("prefix = ?", args[0]).Delete(&models.APIKey{})
+	if result.Error != nil {
+		return result.Error
+	}
+	switch result.RowsAffected {
+	case 0:
+		return fmt.Errorf("no API key with prefix %q", args[0])
+	case 1:
+		fmt.Printf("Revoked API key %s\n", args[0])
+	default:
+		fmt.Printf("Revoked %d API keys with prefix %s\n", result.RowsAffected, args[0])
+	}
+	return nil
+}
+
+// findAdmin loads an administrator by username.
+func findAdmin(db *gorm.DB, username string) (*models.AdminUser, error) {
+	var admin models.AdminUser
+	err := db.Where("username = ?", username).First(&admin).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("no admin named %q", username)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &admin, nil
+}
+
+// readPassword reads a new password from ADMIN_PASSWORD, or else from the
+// first line of standard input so it never appears in the shell history.
+func readPassword() (string, error) {
+	if password := os.Getenv("ADMIN_PASSWORD"); password != "" {
+		return password, nil
+	}
+	fmt.Fprintf(os.Stderr, "Password (at least %d characters): ", auth.MinPasswordLength)
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", fmt.Errorf("failed to read password: %w", err)
+	}
+	password := strings.TrimRight(line, "\r\n")
+	if password == "" {
+		return "", errors.New("no password given")
+	}
+	return password, nil
+}
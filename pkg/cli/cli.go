@@ -0,0 +1,85 @@
+// Package cli implements the administrative subcommands of the Cyber Q&A application.
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"openai-api/pkg/config"
+)
+
+// command is a subcommand run with the loaded settings and the remaining
+// command-line arguments. It returns the process exit code.
+type command func(cfg *config.Config, args []string) int
+
+// commands maps subcommand names to their implementations.
+var commands = map[string]command{
+	"migrate": runMigrate,
+	"admin":   runAdmin,
+	"config":  runConfig,
+	"purge":   runPurge,
+	"rekey":   runRekey,
+	"token":   runToken,
+}
+
+// Run runs the subcommand named by args[0] with the settings in cfg and
+// returns the process exit code.
+func Run(cfg *config.Config, args []string) int {
+	if len(args) == 0 {
+		usage(os.Stderr)
+		return 2
+	}
+	if args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		usage(os.Stdout)
+		return 0
+	}
+
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n", args[0])
+		usage(os.Stderr)
+		return 2
+	}
+	return cmd(cfg, args[1:])
+}
+
+// runConfig implements the config subcommand.
+func runConfig(cfg *config.Config, args []string) int {
+	if len(args) > 0 {
+		usage(os.Stderr)
+		return 2
+	}
+	if err := cfg.Print(os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// usage prints the list of subcommands.
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: openai-api [flags] [command]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Without a command the HTTP server is started. Run \"openai-api -h\" to list the flags.")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	fmt.Fprintln(w, "  config               Print the effective settings, with secrets redacted")
+	fmt.Fprintln(w, "  migrate status       Show which schema migrations have been applied")
+	fmt.Fprintln(w, "  migrate up [N]       Apply the next N pending migrations (default: all)")
+	fmt.Fprintln(w, "  migrate down [N]     Roll back the last N applied migrations (default: 1)")
+	fmt.Fprintln(w, "  admin create USER    Create an admin (password from ADMIN_PASSWORD or stdin)")
+	fmt.Fprintln(w, "  admin passwd USER    Change an admin's password and log out their sessions")
+	fmt.Fprintln(w, "  admin list           List admins")
+	fmt.Fprintln(w, "  admin create-key USER [NAME]")
+	fmt.Fprintln(w, "                       Create an API key for an admin and print it")
+	fmt.Fprintln(w, "  admin list-keys      List API keys")
+	fmt.Fprintln(w, "  admin revoke-key PREFIX")
+	fmt.Fprintln(w, "                       Revoke the API key with the given prefix")
+	fmt.Fprintln(w, "  purge [--dry-run]    Delete the sessions the retention settings no longer keep")
+	fmt.Fprintln(w, "  rekey                Encrypt answers and summaries with the active encryption key")
+	fmt.Fprintln(w, "  token issue LINK PARTICIPANT")
+	fmt.Fprintln(w, "                       Issue a participant of a session created before the upgrade a")
+	fmt.Fprintln(w, "                       results token of their own; PARTICIPANT is an ID, initiator,")
+	fmt.Fprintln(w, "                       or member in pair sessions")
+}
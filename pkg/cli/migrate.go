@@ -0,0 +1,95 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"openai-api/pkg/config"
+	"openai-api/pkg/database"
+
+	"gorm.io/gorm"
+)
+
+// runMigrate implements the migrate subcommand.
+func runMigrate(cfg *config.Config, args []string) int {
+	if len(args) == 0 || len(args) > 2 {
+		usage(os.Stderr)
+		return 2
+	}
+	steps := 0
+	if len(args) == 2 {
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 1 {
+			fmt.Fprintf(os.Stderr, "Invalid number of migrations %q\n", args[1])
+			return 2
+		}
+		steps = n
+	}
+
+	db, err := database.Open(cfg.Database.DSN)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to connect to database: %v\n", err)
+		return 1
+	}
+
+	switch args[0] {
+	case "status":
+		return migrateStatus(db)
+	case "up":
+		applied, err := database.MigrateUp(db, steps)
+		for _, migration := range applied {
+			fmt.Printf("Applied %d_%s\n", migration.Version, migration.Name)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		if len(applied) == 0 {
+			fmt.Println("No pending migrations")
+		}
+	case "down":
+		reverted, err := database.MigrateDown(db, steps)
+		for _, migration := range reverted {
+			fmt.Printf("Reverted %d_%s\n", migration.Version, migration.Name)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		if len(reverted) == 0 {
+			fmt.Println("No applied migrations")
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown migrate command %q\n\n", args[0])
+		usage(os.Stderr)
+		return 2
+	}
+	return 0
+}
+
+// migrateStatus prints every migration and whether it has been applied.
+func migrateStatus(db *gorm.DB) int {
+	states, err := database.MigrationStatus(db)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tSTATUS\tAPPLIED AT")
+	for _, state := range states {
+		status, appliedAt := "pending", ""
+		if state.Applied {
+			status = "applied"
+			appliedAt = state.AppliedAt.Format("2006-01-02 15:04:05")
+		}
+		if state.Unknown {
+			status = "unknown"
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", state.Version, state.Name, status, appliedAt)
+	}
+	w.Flush()
+	return 0
+}
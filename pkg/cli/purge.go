@@ -0,0 +1,71 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"openai-api/pkg/config"
+	"openai-api/pkg/database"
+	"openai-api/pkg/models"
+	"openai-api/pkg/retention"
+)
+
+// runPurge implements the purge subcommand.
+func runPurge(cfg *config.Config, args []string) int {
+	dryRun := false
+	switch {
+	case len(args) == 0:
+	case len(args) == 1 && args[0] == "--dry-run":
+		dryRun = true
+	default:
+		usage(os.Stderr)
+		return 2
+	}
+
+	policy := retention.NewPolicy(cfg.Retention)
+	if !policy.Enabled() {
+		fmt.Fprintln(os.Stderr, "No retention configured; set retention.invite_days or retention.results_days")
+		return 1
+	}
+
+	db, err := database.Open(cfg.Database.DSN)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to connect to database: %v\n", err)
+		return 1
+	}
+
+	if dryRun {
+		due, err := retention.Due(db, policy, time.Now())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to find expired sessions: %v\n", err)
+			return 1
+		}
+		printPurgeRecords(due)
+		fmt.Printf("Would delete %s\n", retention.Summary(due))
+		return 0
+	}
+
+	purged, err := retention.Purge(db, policy, retention.TriggerCLI, time.Now())
+	printPurgeRecords(purged)
+	fmt.Printf("Deleted %s\n", retention.Summary(purged))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to delete expired sessions: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// printPurgeRecords lists the sessions in records.
+func printPurgeRecords(records []models.PurgeRecord) {
+	if len(records) == 0 {
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SESSION\tKIND\tCREATED AT\tREASON")
+	for _, record := range records {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", record.SessionID, record.SessionKind, record.SessionCreatedAt.Format("2006-01-02 15:04:05"), record.Reason)
+	}
+	w.Flush()
+}
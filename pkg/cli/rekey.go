@@ -0,0 +1,51 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"openai-api/pkg/config"
+	"openai-api/pkg/database"
+	"openai-api/pkg/encryption"
+)
+
+// encryptedColumns are the columns tagged serializer:encrypted, as
+// table.column pairs.
+var encryptedColumns = [][2]string{
+	{"participants", "answers"},
+	{"sessions", "summary"},
+}
+
+// runRekey implements the rekey subcommand.
+func runRekey(cfg *config.Config, args []string) int {
+	if len(args) > 0 {
+		usage(os.Stderr)
+		return 2
+	}
+
+	keyring, err := encryption.NewKeyring(cfg.Encryption)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if !keyring.Enabled() {
+		fmt.Fprintln(os.Stderr, "No encryption keys configured; set encryption.keys or encryption.key_file")
+		return 1
+	}
+
+	db, err := database.Open(cfg.Database.DSN)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to connect to database: %v\n", err)
+		return 1
+	}
+
+	for _, column := range encryptedColumns {
+		changed, err := encryption.RekeyColumn(db, keyring, column[0], column[1])
+		fmt.Printf("Re-encrypted %d values of %s.%s with key %q\n", changed, column[0], column[1], keyring.ActiveKey())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+	return 0
+}
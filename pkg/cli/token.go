@@ -0,0 +1,36 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"openai-api/pkg/config"
+	"openai-api/pkg/database"
+	"openai-api/pkg/handlers"
+)
+
+// runToken implements the token subcommand.
+func runToken(cfg *config.Config, args []string) int {
+	if len(args) != 3 || args[0] != "issue" {
+		usage(os.Stderr)
+		return 2
+	}
+
+	db, err := database.Open(cfg.Database.DSN)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to connect to database: %v\n", err)
+		return 1
+	}
+	settings := handlers.DefaultConfig()
+	settings.ResultsTokenDuration = time.Duration(cfg.Tokens.ResultsHours) * time.Hour
+	app := handlers.New(db, nil, settings, nil)
+
+	participant, token, err := app.IssueResultsToken(args[1], args[2])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to issue results token: %v\n", err)
+		return 1
+	}
+	fmt.Printf("Issued a results token for participant %d (%s) of session %d:\n%s\n", participant.ID, participant.Role, participant.SessionID, token)
+	return 0
+}
@@ -0,0 +1,129 @@
+// Package client is a Go client for the Cyber Q&A API.
+//
+// The types and methods in client_gen.go are generated from the OpenAPI
+// document in pkg/openapi; regenerate them after changing it:
+//
+//	go generate ./pkg/client
+package client
+
+//go:generate go run openai-api/pkg/openapi/genclient -o client_gen.go
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Client calls the API. Administrator endpoints need APIKey, or an
+// HTTPClient with a cookie jar after calling AdminLogin.
+type Client struct {
+	// BaseURL is the URL of the API, e.g. "http://localhost:8088/api".
+	BaseURL string
+
+	// APIKey is sent as a bearer token if set.
+	APIKey string
+
+	// HTTPClient sends the requests, http.DefaultClient if nil.
+	HTTPClient *http.Client
+}
+
+// New returns a client for the API at baseURL.
+func New(baseURL string) *Client {
+	return &Client{BaseURL: strings.TrimSuffix(baseURL, "/")}
+}
+
+// Error is returned for error responses. Branch on Code, which is one of the
+// codes listed in the README.
+type Error struct {
+	StatusCode int
+	ErrorBody
+}
+
+func (e *Error) Error() string {
+	if e.RequestID != "" {
+		return fmt.Sprintf("%s (%d): %s [request %s]", e.Code, e.StatusCode, e.Message, e.RequestID)
+	}
+	return fmt.Sprintf("%s (%d): %s", e.Code, e.StatusCode, e.Message)
+}
+
+// do sends a request with an optional JSON body and decodes the JSON response
+// into out, unless it is nil.
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, header http.Header, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+	resp, err := c.send(ctx, method, path, query, header, reader)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// stream sends a request for a server-sent event stream.
+func (c *Client) stream(ctx context.Context, method, path string, query url.Values, header http.Header) (*http.Response, error) {
+	return c.send(ctx, method, path, query, header, nil)
+}
+
+// send sends a request and returns the response if it is successful, or an
+// *Error otherwise.
+func (c *Client) send(ctx context.Context, method, path string, query url.Values, header http.Header, body io.Reader) (*http.Response, error) {
+	target := c.BaseURL + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.APIKey)
+	}
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 300 {
+		return resp, nil
+	}
+
+	defer resp.Body.Close()
+	apiErr := &Error{StatusCode: resp.StatusCode}
+	var envelope ErrorResponse
+	if err := json.NewDecoder(resp.Body).Decode(&envelope); err == nil && envelope.Error.Code != "" {
+		apiErr.ErrorBody = envelope.Error
+	} else {
+		apiErr.Message = http.StatusText(resp.StatusCode)
+	}
+	if apiErr.RequestID == "" {
+		apiErr.RequestID = resp.Header.Get("X-Request-ID")
+	}
+	return nil, apiErr
+}
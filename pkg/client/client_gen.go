@@ -0,0 +1,798 @@
+// Code generated by openai-api/pkg/openapi/genclient from pkg/openapi/openapi.json. DO NOT EDIT.
+
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Answers mirrors the Answers schema.
+// Answers keyed by question text or question ID. Choice and text answers are
+// strings, scale and number answers are numbers, and multi-select and ranking
+// answers are lists of options.
+type Answers map[string]interface{}
+
+// SubmitUserARequest mirrors the SubmitUserARequest schema.
+// Answers of the initiator of a pair session.
+type SubmitUserARequest struct {
+	Answers Answers `json:"answers"`
+	// Whether User B may see the answers.
+	ShareAnswers bool `json:"shareAnswers,omitempty"`
+	// Slug of the questionnaire, empty for the default one.
+	Questionnaire string `json:"questionnaire,omitempty"`
+	// Question set version the answers were given against, zero for the latest.
+	QuestionSetVersion int `json:"questionSetVersion,omitempty"`
+}
+
+// SubmitUserAResponse mirrors the SubmitUserAResponse schema.
+// The created pair session.
+type SubmitUserAResponse struct {
+	// Token to invite User B with.
+	InviteToken string `json:"inviteToken"`
+	// Token for User A to see the results with.
+	ResultsToken string `json:"resultsToken"`
+}
+
+// SubmitUserBRequest mirrors the SubmitUserBRequest schema.
+// Answers of the invited partner.
+type SubmitUserBRequest struct {
+	// Invitation token, or User B's results token to revise the answers.
+	Token   string  `json:"token"`
+	Answers Answers `json:"answers"`
+	// Whether User A may see the answers.
+	ShareAnswers bool `json:"shareAnswers,omitempty"`
+}
+
+// SubmitUserBResponse mirrors the SubmitUserBResponse schema.
+// The queued analysis.
+type SubmitUserBResponse struct {
+	Success bool `json:"success"`
+	// Analysis status.
+	Status string `json:"status"`
+	// 0 for the first submission, then the number of the revision.
+	Revision int `json:"revision"`
+	// Token for User B to see the results with. Only returned for the first
+	// submission.
+	ResultsToken string `json:"resultsToken,omitempty"`
+}
+
+// ResultsResponse mirrors the ResultsResponse schema.
+// Results of a pair session. While the analysis is in progress only status is
+// set.
+type ResultsResponse struct {
+	// Analysis status, or erased once a participant erased their answers.
+	Status string `json:"status"`
+	// Compatibility from 0 to 100.
+	Compatibility int    `json:"compatibility"`
+	Summary       string `json:"summary"`
+	UserAShared   bool   `json:"userAShared"`
+	UserBShared   bool   `json:"userBShared"`
+	// User A's answers, if shared.
+	UserAAnswers Answers `json:"userAAnswers"`
+	// User B's answers, if shared.
+	UserBAnswers Answers `json:"userBAnswers"`
+	// Why the analysis failed: rate_limited, timeout, provider_unavailable or
+	// failed.
+	Error string `json:"error,omitempty"`
+	// Set to local if the answers were scored without the AI provider.
+	Engine    string              `json:"engine,omitempty"`
+	Breakdown []QuestionBreakdown `json:"breakdown"`
+}
+
+// AnalysisStatusResponse mirrors the AnalysisStatusResponse schema.
+// Returned with 202 while the analysis is queued or running.
+type AnalysisStatusResponse struct {
+	Status string `json:"status"`
+}
+
+// QuestionBreakdown mirrors the QuestionBreakdown schema.
+// The verdict on a single question.
+type QuestionBreakdown struct {
+	// Zero for answers that do not match a known question.
+	QuestionID int    `json:"questionId"`
+	Question   string `json:"question"`
+	// Score from 0 to 100.
+	Score int    `json:"score"`
+	Note  string `json:"note"`
+}
+
+// StreamDeltaEvent mirrors the StreamDeltaEvent schema.
+// Payload of a delta or replace server-sent event.
+type StreamDeltaEvent struct {
+	// The next piece of the summary.
+	Content string `json:"content"`
+}
+
+// QuestionItem mirrors the QuestionItem schema.
+// A question to add to the question bank. Type defaults to single_choice if
+// isMultipleChoice is set and to text otherwise.
+type QuestionItem struct {
+	// ID of the question to update when uploading, ignored otherwise.
+	ID int `json:"id,omitempty"`
+	// Question text.
+	Question string `json:"question"`
+	Type     string `json:"type,omitempty"`
+	Required bool   `json:"required,omitempty"`
+	// Deprecated, use type.
+	IsMultipleChoice bool `json:"isMultipleChoice,omitempty"`
+	// Options of choice and ranking questions, labels of scale questions.
+	Options []string `json:"options,omitempty"`
+	// Minimum selections, scale point or number.
+	Min *float64 `json:"min,omitempty"`
+	// Maximum selections, scale point or number.
+	Max *float64 `json:"max,omitempty"`
+}
+
+// QuestionUploadRequest mirrors the QuestionUploadRequest schema.
+// The questions that replace the question bank.
+type QuestionUploadRequest []QuestionItem
+
+// QuestionUploadResponse mirrors the QuestionUploadResponse schema.
+// The uploaded question bank.
+type QuestionUploadResponse struct {
+	Message string `json:"message"`
+	// Question set version the questions were published as.
+	Version int `json:"version,omitempty"`
+}
+
+// QuestionResponse mirrors the QuestionResponse schema.
+// A published question. isMultipleChoice is set for single choice questions,
+// for older clients.
+type QuestionResponse struct {
+	ID               int      `json:"id"`
+	Question         string   `json:"question"`
+	Type             string   `json:"type"`
+	Required         bool     `json:"required"`
+	IsMultipleChoice bool     `json:"isMultipleChoice"`
+	Options          []string `json:"options"`
+	Min              *float64 `json:"min,omitempty"`
+	Max              *float64 `json:"max,omitempty"`
+}
+
+// BankQuestionResponse mirrors the BankQuestionResponse schema.
+// A question of the editable question bank.
+type BankQuestionResponse struct {
+	ID               int      `json:"id"`
+	Question         string   `json:"question"`
+	Type             string   `json:"type"`
+	Required         bool     `json:"required"`
+	IsMultipleChoice bool     `json:"isMultipleChoice"`
+	Options          []string `json:"options"`
+	Min              *float64 `json:"min,omitempty"`
+	Max              *float64 `json:"max,omitempty"`
+	Position         int      `json:"position"`
+}
+
+// ReorderQuestionsRequest mirrors the ReorderQuestionsRequest schema.
+// The new order of the question bank.
+type ReorderQuestionsRequest struct {
+	// IDs of every question in the bank, in their new order.
+	IDs []int `json:"ids"`
+}
+
+// QuestionSetResponse mirrors the QuestionSetResponse schema.
+// A published question set version.
+type QuestionSetResponse struct {
+	Version       int       `json:"version"`
+	PublishedBy   string    `json:"publishedBy"`
+	PublishedAt   time.Time `json:"publishedAt"`
+	QuestionCount int       `json:"questionCount"`
+	// Left out when listing versions.
+	Questions []QuestionResponse `json:"questions,omitempty"`
+}
+
+// QuestionnaireRequest mirrors the QuestionnaireRequest schema.
+// Questionnaire metadata.
+type QuestionnaireRequest struct {
+	// Lowercase letters, digits and hyphens. Cannot be changed.
+	Slug        string `json:"slug,omitempty"`
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Language    string `json:"language,omitempty"`
+	Theme       string `json:"theme,omitempty"`
+}
+
+// QuestionnaireResponse mirrors the QuestionnaireResponse schema.
+// A questionnaire.
+type QuestionnaireResponse struct {
+	Slug        string `json:"slug"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Language    string `json:"language"`
+	Theme       string `json:"theme"`
+	// Latest published question set version.
+	Version *int `json:"version,omitempty"`
+	Default bool `json:"default"`
+}
+
+// CreateGroupRequest mirrors the CreateGroupRequest schema.
+// Answers of the initiator of a group session.
+type CreateGroupRequest struct {
+	// Display name.
+	Name string `json:"name,omitempty"`
+	// Number of members to invite, 1 to 8.
+	Size         int     `json:"size"`
+	Answers      Answers `json:"answers"`
+	ShareAnswers bool    `json:"shareAnswers,omitempty"`
+	// Slug of the questionnaire, empty for the default one.
+	Questionnaire string `json:"questionnaire,omitempty"`
+	// Question set version the answers were given against, zero for the latest.
+	QuestionSetVersion int `json:"questionSetVersion,omitempty"`
+}
+
+// CreateGroupResponse mirrors the CreateGroupResponse schema.
+// The created group session.
+type CreateGroupResponse struct {
+	// Token to invite members with.
+	InviteToken string `json:"inviteToken"`
+	// Token for the initiator to see the results with.
+	ResultsToken string `json:"resultsToken"`
+}
+
+// JoinGroupRequest mirrors the JoinGroupRequest schema.
+// Answers of a group member.
+type JoinGroupRequest struct {
+	// Display name.
+	Name         string  `json:"name,omitempty"`
+	Answers      Answers `json:"answers"`
+	ShareAnswers bool    `json:"shareAnswers,omitempty"`
+}
+
+// JoinGroupResponse mirrors the JoinGroupResponse schema.
+// Progress of a group session.
+type JoinGroupResponse struct {
+	Success bool `json:"success"`
+	// open while members are joining, otherwise the analysis status.
+	Status string `json:"status"`
+	Joined int    `json:"joined"`
+	Size   int    `json:"size"`
+	// Token for the new member to see the results with. Only returned when
+	// joining.
+	ResultsToken string `json:"resultsToken,omitempty"`
+}
+
+// GroupResultsResponse mirrors the GroupResultsResponse schema.
+// Results of a group session.
+type GroupResultsResponse struct {
+	// open while members are joining, erased once a participant erased their
+	// answers, otherwise the analysis status.
+	Status string `json:"status"`
+	Size   int    `json:"size"`
+	Joined int    `json:"joined"`
+	// Compatibility of the group from 0 to 100.
+	Compatibility int                `json:"compatibility"`
+	Summary       string             `json:"summary"`
+	Participants  []GroupParticipant `json:"participants"`
+	// Pairwise compatibility, indexed like participants.
+	Matrix [][]int       `json:"matrix"`
+	Pairs  []PairVerdict `json:"pairs"`
+	// Why the analysis failed.
+	Error string `json:"error,omitempty"`
+	// Set to local if the answers were scored without the AI provider.
+	Engine string `json:"engine,omitempty"`
+}
+
+// GroupParticipant mirrors the GroupParticipant schema.
+// A member of a group session.
+type GroupParticipant struct {
+	ID     int    `json:"id"`
+	Name   string `json:"name"`
+	Role   string `json:"role"`
+	Shared bool   `json:"shared"`
+	// Set if the member shared their answers.
+	Answers Answers `json:"answers,omitempty"`
+}
+
+// PairVerdict mirrors the PairVerdict schema.
+// The verdict on a pair of group members.
+type PairVerdict struct {
+	// Participant ID.
+	A int `json:"a"`
+	// Participant ID.
+	B     int    `json:"b"`
+	Score int    `json:"score"`
+	Note  string `json:"note"`
+}
+
+// DataExportResponse mirrors the DataExportResponse schema.
+// Everything stored about a participant.
+type DataExportResponse struct {
+	SessionKind string `json:"sessionKind"`
+	Role        string `json:"role"`
+	// Display name, empty in pair sessions.
+	Name string `json:"name"`
+	// The participant's answers, empty once erased.
+	Answers      Answers `json:"answers"`
+	ShareAnswers bool    `json:"shareAnswers"`
+	// Number of times the answers were revised.
+	Revisions   int       `json:"revisions"`
+	SubmittedAt time.Time `json:"submittedAt"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+	// When the participant erased their answers, null if never.
+	ErasedAt *time.Time `json:"erasedAt"`
+	// Null until the analysis has finished.
+	Results DataExportResults `json:"results"`
+}
+
+// DataExportResults mirrors the DataExportResults schema.
+// The results of the session a participant took part in.
+type DataExportResults struct {
+	Status string `json:"status"`
+	// Compatibility from 0 to 100.
+	Compatibility int `json:"compatibility"`
+	// Empty once a participant erased their answers.
+	Summary string `json:"summary"`
+	Engine  string `json:"engine,omitempty"`
+	// Per-question scores, pair sessions only.
+	Breakdown []QuestionBreakdown `json:"breakdown,omitempty"`
+	// The participant's scores with each other member, group sessions only.
+	Pairs []PairVerdict `json:"pairs,omitempty"`
+}
+
+// AdminLoginRequest mirrors the AdminLoginRequest schema.
+// Administrator credentials.
+type AdminLoginRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// AdminResponse mirrors the AdminResponse schema.
+// An authenticated administrator.
+type AdminResponse struct {
+	Username string `json:"username"`
+	// When the login session expires.
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+}
+
+// ErrorResponse mirrors the ErrorResponse schema.
+// The body of every error response.
+type ErrorResponse struct {
+	Error ErrorBody `json:"error"`
+}
+
+// ErrorBody mirrors the ErrorBody schema.
+// An error. Branch on code rather than message; the codes are listed in the
+// README.
+type ErrorBody struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	// Depends on the code, e.g. ValidationDetails for validation_failed.
+	Details interface{} `json:"details,omitempty"`
+	// Also returned in the X-Request-ID header.
+	RequestID string `json:"requestId,omitempty"`
+}
+
+// ValidationDetails mirrors the ValidationDetails schema.
+// Details of a validation_failed error.
+type ValidationDetails struct {
+	Fields []FieldError `json:"fields"`
+}
+
+// FieldError mirrors the FieldError schema.
+// An invalid field.
+type FieldError struct {
+	// JSON path of the field, e.g. answers.<question> or name.
+	Field string `json:"field"`
+	// Set for answers to known questions.
+	QuestionID int    `json:"questionId,omitempty"`
+	Code       string `json:"code"`
+	Message    string `json:"message"`
+}
+
+// ListQuestionnaires calls GET /questionnaires: List the questionnaires that
+// have a published question set.
+func (c *Client) ListQuestionnaires(ctx context.Context) ([]QuestionnaireResponse, error) {
+	var out []QuestionnaireResponse
+	if err := c.do(ctx, "GET", "/questionnaires", nil, nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// GetQuestionsParams are the query parameters of GetQuestions. Zero values are
+// left out.
+type GetQuestionsParams struct {
+	// Invitation or results token, to get the version the session is answered
+	// against.
+	Token string
+	// Slug of the questionnaire, the default one if empty.
+	Questionnaire string
+	// Question set version, the latest if empty.
+	Version int
+}
+
+// GetQuestions calls GET /questions: Get the questions of a question set
+// version.
+func (c *Client) GetQuestions(ctx context.Context, params GetQuestionsParams) ([]QuestionResponse, error) {
+	query := url.Values{}
+	if params.Token != "" {
+		query.Set("token", params.Token)
+	}
+	if params.Questionnaire != "" {
+		query.Set("questionnaire", params.Questionnaire)
+	}
+	if params.Version != 0 {
+		query.Set("version", strconv.Itoa(params.Version))
+	}
+	var out []QuestionResponse
+	if err := c.do(ctx, "GET", "/questions", query, nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// SubmitUserAParams are the header parameters of SubmitUserA. Zero values are
+// left out.
+type SubmitUserAParams struct {
+	// Key chosen by the client to retry the request safely, e.g. a UUID. Retries
+	// by the same caller with the same key and body within 24 hours get the first
+	// response again, including the tokens it created. Keys are scoped to the
+	// token in the request body, if any; without one, the key alone replays the
+	// response, so it must be hard to guess.
+	IdempotencyKey string
+}
+
+// SubmitUserA calls POST /submit-user-a: Start a pair session with User A's
+// answers.
+func (c *Client) SubmitUserA(ctx context.Context, params SubmitUserAParams, body SubmitUserARequest) (*SubmitUserAResponse, error) {
+	header := http.Header{}
+	if params.IdempotencyKey != "" {
+		header.Set("Idempotency-Key", params.IdempotencyKey)
+	}
+	var out SubmitUserAResponse
+	if err := c.do(ctx, "POST", "/submit-user-a", nil, header, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// SubmitUserBParams are the header parameters of SubmitUserB. Zero values are
+// left out.
+type SubmitUserBParams struct {
+	// Key chosen by the client to retry the request safely, e.g. a UUID. Retries
+	// by the same caller with the same key and body within 24 hours get the first
+	// response again, including the tokens it created. Keys are scoped to the
+	// token in the request body, if any; without one, the key alone replays the
+	// response, so it must be hard to guess.
+	IdempotencyKey string
+}
+
+// SubmitUserB calls POST /submit-user-b: Submit User B's answers and queue the
+// analysis.
+// Submit with the invitation token the first time. User B may revise the
+// answers with their results token if the server allows it, which analyses them
+// again.
+func (c *Client) SubmitUserB(ctx context.Context, params SubmitUserBParams, body SubmitUserBRequest) (*SubmitUserBResponse, error) {
+	header := http.Header{}
+	if params.IdempotencyKey != "" {
+		header.Set("Idempotency-Key", params.IdempotencyKey)
+	}
+	var out SubmitUserBResponse
+	if err := c.do(ctx, "POST", "/submit-user-b", nil, header, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetResults calls GET /results/{token}: Get the results of a pair session.
+// Every successful response is decoded into the result.
+func (c *Client) GetResults(ctx context.Context, token string) (*ResultsResponse, error) {
+	var out ResultsResponse
+	if err := c.do(ctx, "GET", "/results/"+url.PathEscape(token), nil, nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// StreamResults calls GET /results/{token}/stream: Stream the summary of a pair
+// session as it is generated.
+// The caller must close the body of the returned event stream.
+func (c *Client) StreamResults(ctx context.Context, token string) (*http.Response, error) {
+	return c.stream(ctx, "GET", "/results/"+url.PathEscape(token)+"/stream", nil, nil)
+}
+
+// CreateGroup calls POST /groups: Start a group session with the initiator's
+// answers.
+func (c *Client) CreateGroup(ctx context.Context, body CreateGroupRequest) (*CreateGroupResponse, error) {
+	var out CreateGroupResponse
+	if err := c.do(ctx, "POST", "/groups", nil, nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetGroupResults calls GET /groups/{token}: Get the results of a group
+// session.
+// Every successful response is decoded into the result.
+func (c *Client) GetGroupResults(ctx context.Context, token string) (*GroupResultsResponse, error) {
+	var out GroupResultsResponse
+	if err := c.do(ctx, "GET", "/groups/"+url.PathEscape(token), nil, nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// JoinGroup calls POST /groups/{token}/join: Join a group session.
+func (c *Client) JoinGroup(ctx context.Context, token string, body JoinGroupRequest) (*JoinGroupResponse, error) {
+	var out JoinGroupResponse
+	if err := c.do(ctx, "POST", "/groups/"+url.PathEscape(token)+"/join", nil, nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// AnalyzeGroup calls POST /groups/{token}/analyze: Start the analysis before
+// every member has joined.
+func (c *Client) AnalyzeGroup(ctx context.Context, token string) (*JoinGroupResponse, error) {
+	var out JoinGroupResponse
+	if err := c.do(ctx, "POST", "/groups/"+url.PathEscape(token)+"/analyze", nil, nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// StreamGroupResults calls GET /groups/{token}/stream: Stream the summary of a
+// group session as it is generated.
+// The caller must close the body of the returned event stream.
+func (c *Client) StreamGroupResults(ctx context.Context, token string) (*http.Response, error) {
+	return c.stream(ctx, "GET", "/groups/"+url.PathEscape(token)+"/stream", nil, nil)
+}
+
+// RevokeToken calls DELETE /tokens/{token}: Revoke an invitation or results
+// token.
+// Anyone holding a token can revoke it, e.g. after sharing it by mistake. Other
+// tokens of the session keep working.
+func (c *Client) RevokeToken(ctx context.Context, token string) error {
+	return c.do(ctx, "DELETE", "/tokens/"+url.PathEscape(token), nil, nil, nil, nil)
+}
+
+// ExportData calls GET /my-data/{token}: Export everything stored about a
+// participant.
+// Returns the participant's answers, share flag and the results of their
+// session, but none of the answers of the other participants. Legacy tokens,
+// shared by both users of a pair session, are refused.
+func (c *Client) ExportData(ctx context.Context, token string) (*DataExportResponse, error) {
+	var out DataExportResponse
+	if err := c.do(ctx, "GET", "/my-data/"+url.PathEscape(token), nil, nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// EraseData calls DELETE /my-data/{token}: Erase a participant's answers.
+// Permanently erases the participant's answers and name, and redacts the
+// summary and the notes of the analysis. Scores are kept. The session is
+// closed: its status becomes erased, and it takes no more answers, revisions or
+// members. Erasing again succeeds without changing anything.
+func (c *Client) EraseData(ctx context.Context, token string) error {
+	return c.do(ctx, "DELETE", "/my-data/"+url.PathEscape(token), nil, nil, nil, nil)
+}
+
+// GetSpec calls GET /openapi.json: Get this OpenAPI document.
+func (c *Client) GetSpec(ctx context.Context) (map[string]interface{}, error) {
+	var out map[string]interface{}
+	if err := c.do(ctx, "GET", "/openapi.json", nil, nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// AdminLogin calls POST /admin/login: Log in and set the session cookie.
+func (c *Client) AdminLogin(ctx context.Context, body AdminLoginRequest) (*AdminResponse, error) {
+	var out AdminResponse
+	if err := c.do(ctx, "POST", "/admin/login", nil, nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// AdminLogout calls POST /admin/logout: Log out and clear the session cookie.
+func (c *Client) AdminLogout(ctx context.Context) error {
+	return c.do(ctx, "POST", "/admin/logout", nil, nil, nil, nil)
+}
+
+// AdminMe calls GET /admin/me: Get the authenticated administrator.
+func (c *Client) AdminMe(ctx context.Context) (*AdminResponse, error) {
+	var out AdminResponse
+	if err := c.do(ctx, "GET", "/admin/me", nil, nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// UploadQuestionsParams are the query parameters of UploadQuestions. Zero
+// values are left out.
+type UploadQuestionsParams struct {
+	// Slug of the questionnaire, the default one if empty.
+	Questionnaire string
+}
+
+// UploadQuestions calls POST /questions/upload: Replace the question bank and
+// publish it.
+// Questions whose id is already in the bank are updated in place, other ids are
+// ignored.
+func (c *Client) UploadQuestions(ctx context.Context, params UploadQuestionsParams, body QuestionUploadRequest) (*QuestionUploadResponse, error) {
+	query := url.Values{}
+	if params.Questionnaire != "" {
+		query.Set("questionnaire", params.Questionnaire)
+	}
+	var out QuestionUploadResponse
+	if err := c.do(ctx, "POST", "/questions/upload", query, nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ListAdminQuestionnaires calls GET /admin/questionnaires: List every
+// questionnaire, including unpublished ones.
+func (c *Client) ListAdminQuestionnaires(ctx context.Context) ([]QuestionnaireResponse, error) {
+	var out []QuestionnaireResponse
+	if err := c.do(ctx, "GET", "/admin/questionnaires", nil, nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// CreateQuestionnaire calls POST /admin/questionnaires: Create a questionnaire.
+func (c *Client) CreateQuestionnaire(ctx context.Context, body QuestionnaireRequest) (*QuestionnaireResponse, error) {
+	var out QuestionnaireResponse
+	if err := c.do(ctx, "POST", "/admin/questionnaires", nil, nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// UpdateQuestionnaire calls PUT /admin/questionnaires/{slug}: Update a
+// questionnaire's metadata.
+func (c *Client) UpdateQuestionnaire(ctx context.Context, slug string, body QuestionnaireRequest) (*QuestionnaireResponse, error) {
+	var out QuestionnaireResponse
+	if err := c.do(ctx, "PUT", "/admin/questionnaires/"+url.PathEscape(slug), nil, nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ListBankQuestionsParams are the query parameters of ListBankQuestions. Zero
+// values are left out.
+type ListBankQuestionsParams struct {
+	// Slug of the questionnaire, the default one if empty.
+	Questionnaire string
+}
+
+// ListBankQuestions calls GET /admin/questions: List the question bank.
+func (c *Client) ListBankQuestions(ctx context.Context, params ListBankQuestionsParams) ([]BankQuestionResponse, error) {
+	query := url.Values{}
+	if params.Questionnaire != "" {
+		query.Set("questionnaire", params.Questionnaire)
+	}
+	var out []BankQuestionResponse
+	if err := c.do(ctx, "GET", "/admin/questions", query, nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// CreateQuestionParams are the query parameters of CreateQuestion. Zero values
+// are left out.
+type CreateQuestionParams struct {
+	// Slug of the questionnaire, the default one if empty.
+	Questionnaire string
+}
+
+// CreateQuestion calls POST /admin/questions: Add a question to the end of the
+// question bank.
+func (c *Client) CreateQuestion(ctx context.Context, params CreateQuestionParams, body QuestionItem) (*BankQuestionResponse, error) {
+	query := url.Values{}
+	if params.Questionnaire != "" {
+		query.Set("questionnaire", params.Questionnaire)
+	}
+	var out BankQuestionResponse
+	if err := c.do(ctx, "POST", "/admin/questions", query, nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ReorderQuestionsParams are the query parameters of ReorderQuestions. Zero
+// values are left out.
+type ReorderQuestionsParams struct {
+	// Slug of the questionnaire, the default one if empty.
+	Questionnaire string
+}
+
+// ReorderQuestions calls PUT /admin/questions/order: Reorder the question bank.
+func (c *Client) ReorderQuestions(ctx context.Context, params ReorderQuestionsParams, body ReorderQuestionsRequest) ([]BankQuestionResponse, error) {
+	query := url.Values{}
+	if params.Questionnaire != "" {
+		query.Set("questionnaire", params.Questionnaire)
+	}
+	var out []BankQuestionResponse
+	if err := c.do(ctx, "PUT", "/admin/questions/order", query, nil, body, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// UpdateQuestion calls PUT /admin/questions/{id}: Update a question of the
+// bank.
+func (c *Client) UpdateQuestion(ctx context.Context, id int, body QuestionItem) (*BankQuestionResponse, error) {
+	var out BankQuestionResponse
+	if err := c.do(ctx, "PUT", "/admin/questions/"+url.PathEscape(strconv.Itoa(id)), nil, nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// DeleteQuestion calls DELETE /admin/questions/{id}: Delete a question from the
+// bank.
+func (c *Client) DeleteQuestion(ctx context.Context, id int) error {
+	return c.do(ctx, "DELETE", "/admin/questions/"+url.PathEscape(strconv.Itoa(id)), nil, nil, nil, nil)
+}
+
+// ListQuestionSetsParams are the query parameters of ListQuestionSets. Zero
+// values are left out.
+type ListQuestionSetsParams struct {
+	// Slug of the questionnaire, the default one if empty.
+	Questionnaire string
+}
+
+// ListQuestionSets calls GET /admin/question-sets: List the published question
+// set versions, newest first, without their questions.
+func (c *Client) ListQuestionSets(ctx context.Context, params ListQuestionSetsParams) ([]QuestionSetResponse, error) {
+	query := url.Values{}
+	if params.Questionnaire != "" {
+		query.Set("questionnaire", params.Questionnaire)
+	}
+	var out []QuestionSetResponse
+	if err := c.do(ctx, "GET", "/admin/question-sets", query, nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// PublishQuestionSetParams are the query parameters of PublishQuestionSet. Zero
+// values are left out.
+type PublishQuestionSetParams struct {
+	// Slug of the questionnaire, the default one if empty.
+	Questionnaire string
+}
+
+// PublishQuestionSet calls POST /admin/question-sets: Publish the question bank
+// as a new question set version.
+// Every successful response is decoded into the result.
+func (c *Client) PublishQuestionSet(ctx context.Context, params PublishQuestionSetParams) (*QuestionSetResponse, error) {
+	query := url.Values{}
+	if params.Questionnaire != "" {
+		query.Set("questionnaire", params.Questionnaire)
+	}
+	var out QuestionSetResponse
+	if err := c.do(ctx, "POST", "/admin/question-sets", query, nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetQuestionSetParams are the query parameters of GetQuestionSet. Zero values
+// are left out.
+type GetQuestionSetParams struct {
+	// Slug of the questionnaire, the default one if empty.
+	Questionnaire string
+}
+
+// GetQuestionSet calls GET /admin/question-sets/{version}: Get a question set
+// version.
+func (c *Client) GetQuestionSet(ctx context.Context, version int, params GetQuestionSetParams) (*QuestionSetResponse, error) {
+	query := url.Values{}
+	if params.Questionnaire != "" {
+		query.Set("questionnaire", params.Questionnaire)
+	}
+	var out QuestionSetResponse
+	if err := c.do(ctx, "GET", "/admin/question-sets/"+url.PathEscape(strconv.Itoa(version)), query, nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
@@ -0,0 +1,215 @@
+// Package config loads the settings of the Cyber Q&A application.
+//
+// Every setting has a default and can be set in a YAML or TOML config file,
+// with an environment variable and with a command-line flag. Later sources
+// win:
+//
+//	defaults < config file < environment variables < flags
+//
+// The config file is named by the -config flag or CONFIG_FILE. Its keys are
+// the setting names, nested by section, e.g. port under server; the flag of
+// a setting is its full name, e.g. -server.port.
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Config holds every setting. The tags of its fields describe the settings:
+// key is the name in config files and flags, env the environment variable,
+// usage the help text, and secret marks values that are never printed.
+type Config struct {
+	Server     Server     `key:"server"`
+	Database   Database   `key:"database"`
+	LLM        LLM        `key:"llm"`
+	Analysis   Analysis   `key:"analysis"`
+	Questions  Questions  `key:"questions"`
+	Admin      Admin      `key:"admin"`
+	Tokens     Tokens     `key:"tokens"`
+	Retention  Retention  `key:"retention"`
+	Encryption Encryption `key:"encryption"`
+
+	// file is the config file the settings were read from, if any.
+	file string
+
+	// sources records where each setting was set, by full name.
+	sources map[string]string
+}
+
+// Server configures the HTTP server.
+type Server struct {
+	Port            int    `key:"port" env:"PORT" usage:"HTTP port"`
+	DistPath        string `key:"dist_path" env:"DIST_PATH" usage:"directory of the built frontend"`
+	MaxRequestBytes int64  `key:"max_request_bytes" env:"MAX_REQUEST_BYTES" usage:"maximum size of a request body in bytes"`
+
+	ReadTimeoutSeconds     int `key:"read_timeout_seconds" env:"HTTP_READ_TIMEOUT" usage:"time limit for reading a request in seconds"`
+	WriteTimeoutSeconds    int `key:"write_timeout_seconds" env:"HTTP_WRITE_TIMEOUT" usage:"time limit for writing a response in seconds, except for event streams"`
+	IdleTimeoutSeconds     int `key:"idle_timeout_seconds" env:"HTTP_IDLE_TIMEOUT" usage:"how long an idle keep-alive connection stays open in seconds"`
+	ShutdownTimeoutSeconds int `key:"shutdown_timeout_seconds" env:"SHUTDOWN_TIMEOUT" usage:"how long to wait for requests and analyses to finish on SIGTERM in seconds"`
+}
+
+// Database configures the database connection.
+type Database struct {
+	DSN         string `key:"dsn" env:"DATABASE_DSN" secret:"true" usage:"database as type:connection, where type is sqlite, mysql or postgres"`
+	AutoMigrate bool   `key:"auto_migrate" env:"AUTO_MIGRATE" usage:"apply pending migrations on startup"`
+}
+
+// LLM selects and configures the LLM provider.
+type LLM struct {
+	Provider       string `key:"provider" env:"LLM_PROVIDER" usage:"LLM provider: openai, anthropic or ollama"`
+	Model          string `key:"model" env:"MODELS" usage:"model, empty for the provider's default"`
+	ResponseFormat string `key:"response_format" env:"LLM_RESPONSE_FORMAT" usage:"how the model is asked for JSON: json_object, json_schema or none"`
+	MaxRepairs     int    `key:"max_repairs" env:"VERDICT_MAX_REPAIRS" usage:"how many times invalid model output is sent back to be repaired"`
+
+	OpenAI    OpenAI    `key:"openai"`
+	Anthropic Anthropic `key:"anthropic"`
+	Ollama    Ollama    `key:"ollama"`
+}
+
+// OpenAI configures the OpenAI-compatible provider.
+type OpenAI struct {
+	APIKey     string `key:"api_key" env:"OPENAI_API_KEY" secret:"true" usage:"OpenAI API key"`
+	APIBase    string `key:"api_base" env:"OPENAI_API_BASE" usage:"base URL of the OpenAI-compatible API"`
+	MaxRetries int    `key:"max_retries" env:"OPENAI_MAX_RETRIES" usage:"retries of rate limited, failed or timed out requests"`
+}
+
+// Anthropic configures the Anthropic provider.
+type Anthropic struct {
+	APIKey     string `key:"api_key" env:"ANTHROPIC_API_KEY" secret:"true" usage:"Anthropic API key"`
+	APIBase    string `key:"api_base" env:"ANTHROPIC_API_BASE" usage:"base URL of the Anthropic API"`
+	Version    string `key:"version" env:"ANTHROPIC_VERSION" usage:"anthropic-version header"`
+	MaxRetries int    `key:"max_retries" env:"ANTHROPIC_MAX_RETRIES" usage:"retries of rate limited, failed or timed out requests"`
+}
+
+// Ollama configures the Ollama provider.
+type Ollama struct {
+	APIBase    string `key:"api_base" env:"OLLAMA_API_BASE" usage:"URL of the Ollama server"`
+	APIKey     string `key:"api_key" env:"OLLAMA_API_KEY" secret:"true" usage:"bearer token for an Ollama server behind an authenticating proxy"`
+	MaxRetries int    `key:"max_retries" env:"OLLAMA_MAX_RETRIES" usage:"retries of rate limited, failed or timed out requests"`
+}
+
+// Analysis configures the compatibility analyses.
+type Analysis struct {
+	Scorer                string `key:"scorer" env:"SCORER" usage:"analysis engine: llm, or local for the offline scorer"`
+	ScorerFallback        string `key:"scorer_fallback" env:"SCORER_FALLBACK" usage:"what to do when the LLM fails: local to use the offline scorer, or none"`
+	Workers               int    `key:"workers" env:"ANALYSIS_WORKERS" usage:"number of concurrent background analyses"`
+	TimeoutSeconds        int    `key:"timeout_seconds" env:"ANALYSIS_TIMEOUT" usage:"time limit of a single analysis in seconds"`
+	SystemPrompt          string `key:"system_prompt" env:"SYSTEM_PROMPT" usage:"system prompt of pair analyses, read from system_prompt_file if empty"`
+	SystemPromptFile      string `key:"system_prompt_file" env:"SYSTEM_PROMPT_FILE" usage:"file holding the system prompt of pair analyses"`
+	GroupSystemPrompt     string `key:"group_system_prompt" env:"GROUP_SYSTEM_PROMPT" usage:"system prompt of group analyses, read from group_system_prompt_file if empty"`
+	GroupSystemPromptFile string `key:"group_system_prompt_file" env:"GROUP_SYSTEM_PROMPT_FILE" usage:"file holding the system prompt of group analyses"`
+}
+
+// Questions configures questionnaires and answers.
+type Questions struct {
+	DefaultQuestionnaire string `key:"default_questionnaire" env:"DEFAULT_QUESTIONNAIRE" usage:"slug of the questionnaire used when a request names none"`
+	MaxAnswerLength      int    `key:"max_answer_length" env:"MAX_ANSWER_LENGTH" usage:"maximum number of characters of a text answer"`
+	MaxAnswerRevisions   int    `key:"max_answer_revisions" env:"MAX_ANSWER_REVISIONS" usage:"how many times User B may revise their answers, each time analysing them again; 0 forbids revisions"`
+}
+
+// Admin configures administrator accounts.
+type Admin struct {
+	SessionHours int `key:"session_hours" env:"ADMIN_SESSION_HOURS" usage:"hours an administrator stays logged in"`
+}
+
+// Tokens configures the tokens that let participants answer a session and
+// see its results.
+type Tokens struct {
+	InviteHours  int `key:"invite_hours" env:"INVITE_TOKEN_HOURS" usage:"hours an invitation works; 0 means forever"`
+	ResultsHours int `key:"results_hours" env:"RESULTS_TOKEN_HOURS" usage:"hours a results link works; 0 means forever"`
+	LegacyDays   int `key:"legacy_days" env:"LEGACY_TOKEN_DAYS" usage:"days links created before the upgrade to separate tokens keep working; 0 stops them at once"`
+}
+
+// Retention configures how long sessions are kept before they are deleted
+// for good. See package retention.
+type Retention struct {
+	InviteDays           int `key:"invite_days" env:"INVITE_RETENTION_DAYS" usage:"days after which unanswered sessions are deleted; 0 means never"`
+	ResultsDays          int `key:"results_days" env:"RESULTS_RETENTION_DAYS" usage:"days after the analysis that sessions are deleted; 0 means never"`
+	SweepIntervalMinutes int `key:"sweep_interval_minutes" env:"RETENTION_SWEEP_MINUTES" usage:"minutes between the server's checks for expired sessions"`
+}
+
+// Encryption configures the encryption of answers and summaries at rest.
+// See package encryption.
+type Encryption struct {
+	Keys      string `key:"keys" env:"ENCRYPTION_KEYS" secret:"true" usage:"encryption keys as comma-separated id:key pairs, with 32-byte base64 keys; none stores data unencrypted"`
+	KeyFile   string `key:"key_file" env:"ENCRYPTION_KEY_FILE" usage:"file with more encryption keys, one id:key pair per line"`
+	ActiveKey string `key:"active_key" env:"ENCRYPTION_ACTIVE_KEY" usage:"ID of the key new data is encrypted with; defaults to the first key"`
+}
+
+// Default returns the default settings.
+func Default() *Config {
+	return &Config{
+		Server: Server{
+			Port:            8088,
+			DistPath:        "frontend/cyberqa/dist",
+			MaxRequestBytes: 1 << 20,
+
+			ReadTimeoutSeconds:     30,
+			WriteTimeoutSeconds:    60,
+			IdleTimeoutSeconds:     120,
+			ShutdownTimeoutSeconds: 25,
+		},
+		Database: Database{
+			DSN:         "sqlite:cyberqa.db",
+			AutoMigrate: true,
+		},
+		LLM: LLM{
+			Provider:       "openai",
+			ResponseFormat: "json_object",
+			MaxRepairs:     2,
+			OpenAI:         OpenAI{MaxRetries: 3},
+			Anthropic:      Anthropic{MaxRetries: 3},
+			Ollama:         Ollama{MaxRetries: 3},
+		},
+		Analysis: Analysis{
+			Scorer:                "llm",
+			ScorerFallback:        "local",
+			Workers:               2,
+			TimeoutSeconds:        120,
+			SystemPromptFile:      "system_prompt.txt",
+			GroupSystemPromptFile: "group_prompt.txt",
+		},
+		Questions: Questions{
+			DefaultQuestionnaire: "default",
+			MaxAnswerLength:      2000,
+		},
+		Admin: Admin{
+			SessionHours: 12,
+		},
+		Tokens: Tokens{
+			LegacyDays: 30,
+		},
+		Retention: Retention{
+			SweepIntervalMinutes: 60,
+		},
+	}
+}
+
+// Prompts returns the system prompts of pair and group analyses, reading
+// them from their files unless they are set.
+func (a Analysis) Prompts() (system, group string, err error) {
+	if system, err = readPrompt(a.SystemPrompt, a.SystemPromptFile); err != nil {
+		return "", "", err
+	}
+	if group, err = readPrompt(a.GroupSystemPrompt, a.GroupSystemPromptFile); err != nil {
+		return "", "", err
+	}
+	return system, group, nil
+}
+
+// readPrompt returns prompt, or else the contents of file.
+func readPrompt(prompt, file string) (string, error) {
+	if prompt != "" {
+		return prompt, nil
+	}
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return "", fmt.Errorf("failed to read system prompt: %w", err)
+	}
+	if strings.TrimSpace(string(content)) == "" {
+		return "", fmt.Errorf("system prompt file %s is empty", file)
+	}
+	return string(content), nil
+}
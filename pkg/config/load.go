@@ -0,0 +1,251 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"unicode/utf8"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// setting is a single field of Config.
+type setting struct {
+	// name is the full name, e.g. "server.port".
+	name   string
+	env    string
+	usage  string
+	secret bool
+
+	// value is the field itself.
+	value reflect.Value
+}
+
+// Load reads the settings from the config file, the environment and the
+// flags in args, the command-line arguments without the program name, and
+// validates them. It returns the arguments left after the flags, e.g. a
+// subcommand. If args ask for help, Load prints the flags and returns
+// flag.ErrHelp.
+func Load(args []string) (*Config, []string, error) {
+	c := Default()
+	c.sources = make(map[string]string)
+	settings := c.settings()
+
+	// Parse the flags first to find the config file, but apply them last
+	type flagValue struct {
+		setting setting
+		value   string
+	}
+	var flagValues []flagValue
+	fs := flag.NewFlagSet("openai-api", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	file := fs.String("config", os.Getenv("CONFIG_FILE"), "YAML or TOML config file (env CONFIG_FILE)")
+	for _, s := range settings {
+		s := s
+		usage := fmt.Sprintf("%s (default %s, env %s)", s.usage, s.format(), s.env)
+		record := func(value string) error {
+			flagValues = append(flagValues, flagValue{s, value})
+			return nil
+		}
+		if s.value.Kind() == reflect.Bool {
+			fs.BoolFunc(s.name, usage, record)
+		} else {
+			fs.Func(s.name, usage, record)
+		}
+	}
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: openai-api [flags] [command]")
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintln(os.Stderr, `Without a command the HTTP server is started. Run "openai-api help" to list the commands.`)
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintln(os.Stderr, "Flags:")
+		fs.SetOutput(os.Stderr)
+		fs.PrintDefaults()
+		fs.SetOutput(io.Discard)
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	byName := make(map[string]setting, len(settings))
+	for _, s := range settings {
+		byName[s.name] = s
+	}
+	if *file != "" {
+		if err := c.loadFile(*file, byName); err != nil {
+			return nil, nil, err
+		}
+	}
+	for _, s := range settings {
+		if value := os.Getenv(s.env); value != "" {
+			if err := s.set(value); err != nil {
+				return nil, nil, fmt.Errorf("environment variable %s: %w", s.env, err)
+			}
+			c.sources[s.name] = "env " + s.env
+		}
+	}
+	for _, v := range flagValues {
+		if err := v.setting.set(v.value); err != nil {
+			return nil, nil, fmt.Errorf("flag -%s: %w", v.setting.name, err)
+		}
+		c.sources[v.setting.name] = "flag"
+	}
+
+	if err := c.Validate(); err != nil {
+		return nil, nil, err
+	}
+	return c, fs.Args(), nil
+}
+
+// loadFile applies the settings in a YAML or TOML config file.
+func (c *Config) loadFile(path string, settings map[string]setting) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+	values := make(map[string]interface{})
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &values)
+	case ".toml":
+		err = toml.Unmarshal(data, &values)
+	default:
+		return fmt.Errorf("config file %s must end in .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	c.file = path
+	var apply func(prefix string, values map[string]interface{}) error
+	apply = func(prefix string, values map[string]interface{}) error {
+		for key, value := range values {
+			name := prefix + key
+			if section, ok := value.(map[string]interface{}); ok {
+				if err := apply(name+".", section); err != nil {
+					return err
+				}
+				continue
+			}
+			s, ok := settings[name]
+			if !ok {
+				return fmt.Errorf("config file %s: unknown setting %s", path, name)
+			}
+			if value == nil {
+				continue
+			}
+			var raw string
+			switch value := value.(type) {
+			case string:
+				raw = value
+			case bool, int, int64, float64:
+				raw = fmt.Sprint(value)
+			default:
+				return fmt.Errorf("config file %s: %s must be a single value", path, name)
+			}
+			if err := s.set(raw); err != nil {
+				return fmt.Errorf("config file %s: %w", path, err)
+			}
+			c.sources[name] = "file"
+		}
+		return nil
+	}
+	return apply("", values)
+}
+
+// Print writes every setting, its effective value and where it was set to w.
+// Secrets are redacted and long values shortened.
+func (c *Config) Print(w io.Writer) error {
+	if c.file != "" {
+		fmt.Fprintf(w, "Config file: %s\n", c.file)
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "SETTING\tVALUE\tSOURCE")
+	for _, s := range c.settings() {
+		source := c.sources[s.name]
+		if source == "" {
+			source = "default"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", s.name, s.format(), source)
+	}
+	return tw.Flush()
+}
+
+// settings returns the settings of c in the order they are declared.
+func (c *Config) settings() []setting {
+	var settings []setting
+	var walk func(prefix string, v reflect.Value)
+	walk = func(prefix string, v reflect.Value) {
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			key := field.Tag.Get("key")
+			if key == "" {
+				continue
+			}
+			if field.Type.Kind() == reflect.Struct {
+				walk(prefix+key+".", v.Field(i))
+				continue
+			}
+			settings = append(settings, setting{
+				name:   prefix + key,
+				env:    field.Tag.Get("env"),
+				usage:  field.Tag.Get("usage"),
+				secret: field.Tag.Get("secret") == "true",
+				value:  v.Field(i),
+			})
+		}
+	}
+	walk("", reflect.ValueOf(c).Elem())
+	return settings
+}
+
+// set parses raw into the setting.
+func (s setting) set(raw string) error {
+	switch s.value.Kind() {
+	case reflect.String:
+		s.value.SetString(raw)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(strings.TrimSpace(raw), 10, 64)
+		if err != nil {
+			return fmt.Errorf("%s: %q is not an integer", s.name, raw)
+		}
+		s.value.SetInt(n)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(strings.TrimSpace(raw))
+		if err != nil {
+			return fmt.Errorf("%s: %q is not true or false", s.name, raw)
+		}
+		s.value.SetBool(b)
+	default:
+		return errors.New("unsupported setting type " + s.value.Type().String())
+	}
+	return nil
+}
+
+// maxPrintedLength is the number of characters of long values that are printed.
+const maxPrintedLength = 40
+
+// format returns the value of the setting as printed, redacting secrets.
+func (s setting) format() string {
+	if s.value.Kind() != reflect.String {
+		return fmt.Sprint(s.value.Interface())
+	}
+	value := s.value.String()
+	switch {
+	case value == "":
+		return `""`
+	case s.secret:
+		return "<redacted>"
+	case utf8.RuneCountInString(value) > maxPrintedLength:
+		return fmt.Sprintf("%s... (%d characters)", strconv.Quote(string([]rune(value)[:maxPrintedLength])), utf8.RuneCountInString(value))
+	}
+	return strconv.Quote(value)
+}
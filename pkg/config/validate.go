@@ -0,0 +1,85 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Validate checks every setting and reports all invalid ones at once. The
+// system prompts are not read; see Analysis.Prompts.
+func (c *Config) Validate() error {
+	var problems []string
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			problems = append(problems, fmt.Sprintf(format, args...))
+		}
+	}
+	oneOf := func(name, value string, allowed ...string) {
+		for _, a := range allowed {
+			if value == a {
+				return
+			}
+		}
+		problems = append(problems, fmt.Sprintf("%s must be one of %s, not %q", name, strings.Join(allowed, ", "), value))
+	}
+	atLeast := func(name string, value, min int64) {
+		check(value >= min, "%s must be at least %d, not %d", name, min, value)
+	}
+	validURL := func(name, value string) {
+		if value == "" {
+			return
+		}
+		u, err := url.Parse(value)
+		check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "", "%s must be an http or https URL, not %q", name, value)
+	}
+
+	check(c.Server.Port >= 1 && c.Server.Port <= 65535, "server.port must be between 1 and 65535, not %d", c.Server.Port)
+	atLeast("server.max_request_bytes", c.Server.MaxRequestBytes, 1)
+	atLeast("server.read_timeout_seconds", int64(c.Server.ReadTimeoutSeconds), 1)
+	atLeast("server.write_timeout_seconds", int64(c.Server.WriteTimeoutSeconds), 1)
+	atLeast("server.idle_timeout_seconds", int64(c.Server.IdleTimeoutSeconds), 1)
+	atLeast("server.shutdown_timeout_seconds", int64(c.Server.ShutdownTimeoutSeconds), 1)
+
+	if driver, conn, ok := strings.Cut(c.Database.DSN, ":"); !ok || conn == "" {
+		problems = append(problems, "database.dsn must be type:connection, e.g. sqlite:cyberqa.db")
+	} else {
+		oneOf("database.dsn type", driver, "sqlite", "mysql", "postgres", "postgresql")
+	}
+
+	oneOf("llm.provider", strings.ToLower(c.LLM.Provider), "openai", "anthropic", "claude", "ollama")
+	oneOf("llm.response_format", c.LLM.ResponseFormat, "json_object", "json_schema", "none")
+	atLeast("llm.max_repairs", int64(c.LLM.MaxRepairs), 0)
+	validURL("llm.openai.api_base", c.LLM.OpenAI.APIBase)
+	atLeast("llm.openai.max_retries", int64(c.LLM.OpenAI.MaxRetries), 0)
+	validURL("llm.anthropic.api_base", c.LLM.Anthropic.APIBase)
+	atLeast("llm.anthropic.max_retries", int64(c.LLM.Anthropic.MaxRetries), 0)
+	validURL("llm.ollama.api_base", c.LLM.Ollama.APIBase)
+	atLeast("llm.ollama.max_retries", int64(c.LLM.Ollama.MaxRetries), 0)
+
+	oneOf("analysis.scorer", c.Analysis.Scorer, "llm", "local")
+	oneOf("analysis.scorer_fallback", c.Analysis.ScorerFallback, "local", "none")
+	atLeast("analysis.workers", int64(c.Analysis.Workers), 1)
+	atLeast("analysis.timeout_seconds", int64(c.Analysis.TimeoutSeconds), 1)
+
+	check(c.Questions.DefaultQuestionnaire != "", "questions.default_questionnaire must not be empty")
+	atLeast("questions.max_answer_length", int64(c.Questions.MaxAnswerLength), 1)
+	atLeast("questions.max_answer_revisions", int64(c.Questions.MaxAnswerRevisions), 0)
+
+	atLeast("admin.session_hours", int64(c.Admin.SessionHours), 1)
+	atLeast("tokens.invite_hours", int64(c.Tokens.InviteHours), 0)
+	atLeast("tokens.results_hours", int64(c.Tokens.ResultsHours), 0)
+	atLeast("tokens.legacy_days", int64(c.Tokens.LegacyDays), 0)
+
+	atLeast("retention.invite_days", int64(c.Retention.InviteDays), 0)
+	atLeast("retention.results_days", int64(c.Retention.ResultsDays), 0)
+	atLeast("retention.sweep_interval_minutes", int64(c.Retention.SweepIntervalMinutes), 1)
+
+	check(c.Encryption.ActiveKey == "" || c.Encryption.Keys != "" || c.Encryption.KeyFile != "", "encryption.active_key is set, but no encryption keys are")
+
+	if len(problems) > 0 {
+		return errors.New("invalid configuration:\n  " + strings.Join(problems, "\n  "))
+	}
+	return nil
+}
@@ -2,11 +2,11 @@
 package database
 
 import (
-	"log"
-	"os"
+	"errors"
+	"fmt"
 	"strings"
 
-	"openai-api/pkg/models"
+	"openai-api/pkg/encryption"
 
 	"gorm.io/driver/mysql"
 	"gorm.io/driver/postgres"
@@ -14,45 +14,57 @@ import (
 	"gorm.io/gorm"
 )
 
-// DB is the global database instance.
-var DB *gorm.DB
+// Connect opens the database named by dsn and applies pending migrations
+// if migrate is set.
+func Connect(dsn string, migrate bool) (*gorm.DB, error) {
+	db, err := Open(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
 
-// Connect initializes the database connection using environment variables.
-func Connect() {
-	// Get database DSN from environment variable, default to "sqlite:cyberqa.db"
-	dsn := os.Getenv("DATABASE_DSN")
-	if dsn == "" {
-		dsn = "sqlite:cyberqa.db"
+	// Run migrations
+	if !migrate {
+		return db, nil
 	}
+	if err := Migrate(db); err != nil {
+		return nil, fmt.Errorf("failed to migrate database: %w", err)
+	}
+	return db, nil
+}
+
+// Open opens the database named by dsn, without applying migrations. The
+// DSN has the form type:connection_string, where type is sqlite, mysql or
+// postgres, e.g. "sqlite:cyberqa.db". It also registers the serializer of
+// encrypted fields, see encryption.Register.
+func Open(dsn string) (*gorm.DB, error) {
+	encryption.Register()
 
-	// Parse the DSL to determine database type and connection string
+	// Parse the DSN to determine database type and connection string
 	parts := strings.SplitN(dsn, ":", 2)
 	if len(parts) != 2 {
-		log.Fatal("Invalid database DSL format. Expected format: type:connection_string")
+		return nil, errors.New("invalid database DSN format, expected type:connection_string")
 	}
 
 	dbType := strings.ToLower(parts[0])
 	connectionString := parts[1]
 
-	var err error
 	switch dbType {
 	case "sqlite":
-		DB, err = gorm.Open(sqlite.Open(connectionString), &gorm.Config{})
+		return gorm.Open(sqlite.Open(connectionString), &gorm.Config{})
 	case "mysql":
-		DB, err = gorm.Open(mysql.Open(connectionString), &gorm.Config{})
+		return gorm.Open(mysql.Open(connectionString), &gorm.Config{})
 	case "postgres", "postgresql":
-		DB, err = gorm.Open(postgres.Open(connectionString), &gorm.Config{})
+		return gorm.Open(postgres.Open(connectionString), &gorm.Config{})
 	default:
-		log.Fatal("Unsupported database type:", dbType)
-	}
-
-	if err != nil {
-		log.Fatal("Failed to connect to database:", err)
+		return nil, fmt.Errorf("unsupported database type: %s", dbType)
 	}
+}
 
-	// Run migrations
-	err = DB.AutoMigrate(&models.UserA{}, &models.UserB{}, &models.Session{}, &models.Question{})
+// Close closes the connection pool of db.
+func Close(db *gorm.DB) error {
+	sqlDB, err := db.DB()
 	if err != nil {
-		log.Fatal("Failed to migrate database:", err)
+		return err
 	}
+	return sqlDB.Close()
 }
@@ -0,0 +1,204 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrIrreversible is returned when rolling back a migration that has no Down step.
+var ErrIrreversible = errors.New("migration cannot be rolled back")
+
+// Migration is a versioned schema change.
+// Migrations must not use the models package: they describe the schema as it
+// was when they were written, so they declare their own snapshot structs.
+//
+// Each step runs in a transaction, but MySQL commits schema changes
+// immediately, so a step that fails there can be left half applied. Up and
+// Down must therefore be safe to run again after failing part way: they skip
+// schema changes that have already been made and data they already moved.
+type Migration struct {
+	// Version orders the migrations. It must be unique and never change once released.
+	Version int
+
+	// Name is a short description shown by the migrate command.
+	Name string
+
+	// Up applies the change.
+	Up func(tx *gorm.DB) error
+
+	// Down reverts the change, or is nil if the change cannot be reverted.
+	Down func(tx *gorm.DB) error
+}
+
+// SchemaMigration records an applied migration in the schema_migrations table.
+type SchemaMigration struct {
+	Version   int    `gorm:"primaryKey;autoIncrement:false"`
+	Name      string `gorm:"size:255"`
+	AppliedAt time.Time
+}
+
+// TableName returns the name of the table applied migrations are recorded in.
+func (SchemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// MigrationState is a migration together with whether it has been applied.
+type MigrationState struct {
+	Migration
+
+	// Applied reports whether the migration is recorded in schema_migrations.
+	Applied bool
+
+	// AppliedAt is when the migration was applied, if it was.
+	AppliedAt time.Time
+
+	// Unknown reports an applied migration this build does not know about,
+	// e.g. one applied by a newer version of the application.
+	Unknown bool
+}
+
+// Migrate applies every pending migration.
+func Migrate(db *gorm.DB) error {
+	_, err := MigrateUp(db, 0)
+	return err
+}
+
+// MigrateUp applies up to steps pending migrations in version order,
+// or all of them if steps is zero. It returns the migrations it applied.
+// A failed migration is not recorded; on MySQL it may have been partly
+// applied, and running MigrateUp again completes it.
+func MigrateUp(db *gorm.DB, steps int) ([]Migration, error) {
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for _, migration := range sortedMigrations() {
+		if _, ok := applied[migration.Version]; ok {
+			continue
+		}
+		if steps > 0 && len(done) >= steps {
+			break
+		}
+
+		log.Printf("Applying migration %d_%s", migration.Version, migration.Name)
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := migration.Up(tx); err != nil {
+				return err
+			}
+			return tx.Create(&SchemaMigration{
+				Version:   migration.Version,
+				Name:      migration.Name,
+				AppliedAt: time.Now(),
+			}).Error
+		})
+		if err != nil {
+			return done, fmt.Errorf("migration %d_%s failed: %w", migration.Version, migration.Name, err)
+		}
+		done = append(done, migration)
+	}
+	return done, nil
+}
+
+// MigrateDown reverts the last steps applied migrations in reverse version
+// order, one if steps is zero. It returns the migrations it reverted.
+// Like MigrateUp, running it again completes a step that failed part way.
+func MigrateDown(db *gorm.DB, steps int) ([]Migration, error) {
+	if steps <= 0 {
+		steps = 1
+	}
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return nil, err
+	}
+
+	migrations := sortedMigrations()
+	var done []Migration
+	for i := len(migrations) - 1; i >= 0 && len(done) < steps; i-- {
+		migration := migrations[i]
+		if _, ok := applied[migration.Version]; !ok {
+			continue
+		}
+		if migration.Down == nil {
+			return done, fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, ErrIrreversible)
+		}
+
+		log.Printf("Reverting migration %d_%s", migration.Version, migration.Name)
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := migration.Down(tx); err != nil {
+				return err
+			}
+			return tx.Delete(&SchemaMigration{}, migration.Version).Error
+		})
+		if err != nil {
+			return done, fmt.Errorf("reverting migration %d_%s failed: %w", migration.Version, migration.Name, err)
+		}
+		done = append(done, migration)
+	}
+	return done, nil
+}
+
+// MigrationStatus lists every known migration and whether it has been applied,
+// followed by applied migrations this build does not know about.
+func MigrationStatus(db *gorm.DB) ([]MigrationState, error) {
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var states []MigrationState
+	for _, migration := range sortedMigrations() {
+		state := MigrationState{Migration: migration}
+		if record, ok := applied[migration.Version]; ok {
+			state.Applied = true
+			state.AppliedAt = record.AppliedAt
+			delete(applied, migration.Version)
+		}
+		states = append(states, state)
+	}
+
+	var unknown []MigrationState
+	for _, record := range applied {
+		unknown = append(unknown, MigrationState{
+			Migration: Migration{Version: record.Version, Name: record.Name},
+			Applied:   true,
+			AppliedAt: record.AppliedAt,
+			Unknown:   true,
+		})
+	}
+	sort.Slice(unknown, func(i, j int) bool { return unknown[i].Version < unknown[j].Version })
+	return append(states, unknown...), nil
+}
+
+// appliedMigrations returns the applied migrations by version,
+// creating the schema_migrations table if needed.
+func appliedMigrations(db *gorm.DB) (map[int]SchemaMigration, error) {
+	if !db.Migrator().HasTable(&SchemaMigration{}) {
+		if err := db.Migrator().CreateTable(&SchemaMigration{}); err != nil {
+			return nil, fmt.Errorf("failed to create schema_migrations: %w", err)
+		}
+	}
+
+	var records []SchemaMigration
+	if err := db.Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("failed to load schema_migrations: %w", err)
+	}
+	applied := make(map[int]SchemaMigration, len(records))
+	for _, record := range records {
+		applied[record.Version] = record
+	}
+	return applied, nil
+}
+
+// sortedMigrations returns the registered migrations in version order.
+func sortedMigrations() []Migration {
+	sorted := append([]Migration(nil), migrations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Version < sorted[j].Version })
+	return sorted
+}
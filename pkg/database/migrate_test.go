@@ -0,0 +1,124 @@
+package database
+
+import (
+	"io"
+	"log"
+	"os"
+	"strings"
+	"testing"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// The tests migrate in-memory SQLite databases.
+
+func TestMain(m *testing.M) {
+	log.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+// openTestDB returns an empty in-memory database.
+func openTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := Open("sqlite::memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Logger = logger.Discard
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Every connection would get a database of its own
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+	return db
+}
+
+// tables returns the tables of db other than schema_migrations and those
+// SQLite keeps for itself.
+func tables(t *testing.T, db *gorm.DB) []string {
+	t.Helper()
+	names, err := db.Migrator().GetTables()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var result []string
+	for _, name := range names {
+		if name != "schema_migrations" && !strings.HasPrefix(name, "sqlite_") {
+			result = append(result, name)
+		}
+	}
+	return result
+}
+
+func TestMigrateUpDownUp(t *testing.T) {
+	db := openTestDB(t)
+
+	applied, err := MigrateUp(db, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != len(migrations) {
+		t.Fatalf("applied %d migrations, want %d", len(applied), len(migrations))
+	}
+	created := tables(t, db)
+
+	reverted, err := MigrateDown(db, len(migrations))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reverted) != len(migrations) {
+		t.Fatalf("reverted %d migrations, want %d", len(reverted), len(migrations))
+	}
+	if left := tables(t, db); len(left) != 0 {
+		t.Errorf("tables left after reverting every migration: %v", left)
+	}
+
+	if applied, err = MigrateUp(db, 0); err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != len(migrations) {
+		t.Fatalf("applied %d migrations again, want %d", len(applied), len(migrations))
+	}
+	if again := tables(t, db); len(again) != len(created) {
+		t.Errorf("got tables %v after migrating again, want %v", again, created)
+	}
+
+	states, err := MigrationStatus(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, state := range states {
+		if !state.Applied || state.Unknown {
+			t.Errorf("migration %d_%s: applied %v, unknown %v", state.Version, state.Name, state.Applied, state.Unknown)
+		}
+	}
+}
+
+// TestMigrationsRerun runs every step twice, as happens when a step that
+// failed part way on MySQL is run again.
+func TestMigrationsRerun(t *testing.T) {
+	db := openTestDB(t)
+	for _, migration := range sortedMigrations() {
+		for i := 0; i < 2; i++ {
+			if err := migration.Up(db); err != nil {
+				t.Fatalf("migration %d_%s, run %d: %v", migration.Version, migration.Name, i+1, err)
+			}
+		}
+	}
+
+	migrations := sortedMigrations()
+	for i := len(migrations) - 1; i >= 0; i-- {
+		migration := migrations[i]
+		for run := 0; run < 2; run++ {
+			if err := migration.Down(db); err != nil {
+				t.Fatalf("reverting migration %d_%s, run %d: %v", migration.Version, migration.Name, run+1, err)
+			}
+		}
+	}
+	if left := tables(t, db); len(left) != 0 {
+		t.Errorf("tables left after reverting every migration: %v", left)
+	}
+}
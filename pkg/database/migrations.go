@@ -0,0 +1,979 @@
+package database
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// migrations lists every schema migration. Add new migrations at the end with
+// the next version, and never change a migration once it has been released.
+var migrations = []Migration{
+	{Version: 1, Name: "baseline", Up: baselineUp, Down: baselineDown},
+	{Version: 2, Name: "move_users_to_participants", Up: moveUsersToParticipants, Down: moveParticipantsToUsers},
+	{Version: 3, Name: "add_admin_auth", Up: addAdminAuth, Down: dropAdminAuth},
+	{Version: 4, Name: "add_question_sets", Up: addQuestionSets, Down: dropQuestionSets},
+	{Version: 5, Name: "add_questionnaires", Up: addQuestionnaires, Down: dropQuestionnaires},
+	{Version: 6, Name: "add_question_types", Up: addQuestionTypes, Down: dropQuestionTypes},
+	{Version: 7, Name: "add_submission_guards", Up: addSubmissionGuards, Down: dropSubmissionGuards},
+	{Version: 8, Name: "split_session_tokens", Up: splitSessionTokens, Down: joinSessionTokens},
+	{Version: 9, Name: "add_purge_records", Up: addPurgeRecords, Down: dropPurgeRecords},
+	{Version: 10, Name: "add_participant_erasure", Up: addParticipantErasure, Down: dropParticipantErasure},
+	{Version: 11, Name: "redact_idempotent_responses", Up: redactIdempotentResponses, Down: unredactIdempotentResponses},
+	{Version: 12, Name: "scope_idempotency_keys", Up: deleteIdempotentRequests, Down: deleteIdempotentRequests},
+}
+
+// v1Session is the sessions table at migration 1. The v1 snapshots declare
+// their associations so every driver gets the foreign keys AutoMigrate created.
+type v1Session struct {
+	gorm.Model
+	Token          string `gorm:"uniqueIndex;size:255"`
+	Kind           string `gorm:"size:20"`
+	GroupSize      int
+	Compatibility  int
+	Summary        string            `gorm:"type:text"`
+	Status         string            `gorm:"size:20;index"`
+	AnalysisError  string            `gorm:"type:text"`
+	ErrorCode      string            `gorm:"size:50"`
+	Engine         string            `gorm:"size:100"`
+	QuestionScores []v1QuestionScore `gorm:"foreignKey:SessionID"`
+	Participants   []v1Participant   `gorm:"foreignKey:SessionID"`
+	PairScores     []v1PairScore     `gorm:"foreignKey:SessionID"`
+}
+
+func (v1Session) TableName() string { return "sessions" }
+
+// v1QuestionScore is the question_scores table at migration 1.
+type v1QuestionScore struct {
+	gorm.Model
+	SessionID  uint `gorm:"index"`
+	QuestionID uint
+	Question   string `gorm:"type:text"`
+	Score      int
+	Note       string `gorm:"type:text"`
+}
+
+func (v1QuestionScore) TableName() string { return "question_scores" }
+
+// v1Participant is the participants table at migration 1.
+type v1Participant struct {
+	gorm.Model
+	SessionID    uint   `gorm:"index"`
+	Role         string `gorm:"size:20"`
+	Name         string `gorm:"size:100"`
+	Answers      string `gorm:"type:text"`
+	ShareAnswers bool
+}
+
+func (v1Participant) TableName() string { return "participants" }
+
+// v1PairScore is the pair_scores table at migration 1.
+type v1PairScore struct {
+	gorm.Model
+	SessionID      uint `gorm:"index"`
+	ParticipantAID uint
+	ParticipantBID uint
+	Score          int
+	Note           string `gorm:"type:text"`
+}
+
+func (v1PairScore) TableName() string { return "pair_scores" }
+
+// v1Question is the questions table at migration 1.
+type v1Question struct {
+	gorm.Model
+	ID               uint   `gorm:"primaryKey"`
+	QuestionText     string `gorm:"type:text"`
+	IsMultipleChoice bool
+	Options          string `gorm:"type:text"`
+}
+
+func (v1Question) TableName() string { return "questions" }
+
+// baselineUp creates the schema as it was when versioned migrations were introduced.
+// Databases created by AutoMigrate before then already have some or all of
+// these tables, so it only adds what is missing.
+func baselineUp(tx *gorm.DB) error {
+	return tx.Migrator().AutoMigrate(&v1Session{}, &v1QuestionScore{}, &v1Participant{}, &v1PairScore{}, &v1Question{})
+}
+
+// baselineDown drops every table of the baseline schema, and the legacy
+// user tables reverting migration 2 creates.
+func baselineDown(tx *gorm.DB) error {
+	return tx.Migrator().DropTable(&v1PairScore{}, &v1Participant{}, &v1QuestionScore{}, &v1Question{}, &v1Session{}, &v0UserA{}, &v0UserB{})
+}
+
+// v0UserA is the legacy user_as table that held User A's answers before participants existed.
+type v0UserA struct {
+	gorm.Model
+	Token        string `gorm:"uniqueIndex;size:255"`
+	Answers      string `gorm:"type:text"`
+	ShareAnswers bool
+}
+
+func (v0UserA) TableName() string { return "user_as" }
+
+// v0UserB is the legacy user_bs table that held User B's answers before participants existed.
+type v0UserB struct {
+	gorm.Model
+	Token        string `gorm:"uniqueIndex;size:255"`
+	Answers      string `gorm:"type:text"`
+	ShareAnswers bool
+}
+
+func (v0UserB) TableName() string { return "user_bs" }
+
+// v0Session is the part of the sessions table that referenced the legacy tables.
+type v0Session struct {
+	ID      uint
+	Token   string
+	UserAID *uint
+	UserA   *v0UserA
+	UserBID *uint
+	UserB   *v0UserB
+}
+
+func (v0Session) TableName() string { return "sessions" }
+
+// moveUsersToParticipants moves the answers of pair sessions from the legacy
+// user_as and user_bs tables into participants, then drops the legacy tables
+// and the sessions columns that referenced them. Sessions keep their tokens,
+// so existing invitation and result links keep working. It does nothing on
+// databases that never had the legacy tables.
+func moveUsersToParticipants(tx *gorm.DB) error {
+	migrator := tx.Migrator()
+	if !migrator.HasTable(&v0UserA{}) {
+		return nil
+	}
+
+	var moved int
+	if migrator.HasColumn(&v0Session{}, "UserAID") {
+		var sessions []v0Session
+		query := tx.Where("user_a_id IS NOT NULL").Preload("UserA")
+		if migrator.HasTable(&v0UserB{}) {
+			query = query.Preload("UserB")
+		}
+		if err := query.Find(&sessions).Error; err != nil {
+			return fmt.Errorf("failed to load legacy sessions: %w", err)
+		}
+
+		for _, session := range sessions {
+			if session.UserA == nil {
+				log.Printf("Session %d references a missing user_as row, skipping it", session.ID)
+				continue
+			}
+			var count int64
+			if err := tx.Model(&v1Participant{}).Where("session_id = ?", session.ID).Count(&count).Error; err != nil {
+				return fmt.Errorf("failed to check participants of session %d: %w", session.ID, err)
+			}
+			if count > 0 {
+				// Moved by an earlier attempt that failed further on
+				continue
+			}
+			participants := []v1Participant{newV1Participant(session.ID, "initiator", session.UserA.Model, session.UserA.Answers, session.UserA.ShareAnswers)}
+			if session.UserB != nil {
+				participants = append(participants, newV1Participant(session.ID, "member", session.UserB.Model, session.UserB.Answers, session.UserB.ShareAnswers))
+			}
+			if err := tx.Create(&participants).Error; err != nil {
+				return fmt.Errorf("failed to move answers of session %d: %w", session.ID, err)
+			}
+			moved++
+		}
+	}
+	if err := tx.Model(&v1Session{}).Where("kind IS NULL OR kind = ''").Update("kind", "pair").Error; err != nil {
+		return err
+	}
+
+	// Drop the foreign keys before the tables they point to
+	for _, name := range []string{"UserA", "UserB"} {
+		if migrator.HasConstraint(&v0Session{}, name) {
+			if err := migrator.DropConstraint(&v0Session{}, name); err != nil {
+				return fmt.Errorf("failed to drop constraint on sessions.%s: %w", name, err)
+			}
+		}
+	}
+	for _, name := range []string{"UserAID", "UserBID"} {
+		if err := dropColumn(tx, &v0Session{}, name); err != nil {
+			return fmt.Errorf("failed to drop column sessions.%s: %w", name, err)
+		}
+	}
+	if err := migrator.DropTable(&v0UserA{}, &v0UserB{}); err != nil {
+		return fmt.Errorf("failed to drop legacy user tables: %w", err)
+	}
+	if err := restoreSessionIndexes(tx); err != nil {
+		return err
+	}
+
+	log.Printf("Moved %d pair sessions from user_as/user_bs to participants", moved)
+	return nil
+}
+
+// moveParticipantsToUsers recreates the legacy user_as and user_bs tables and
+// moves the answers of pair sessions back into them.
+func moveParticipantsToUsers(tx *gorm.DB) error {
+	if err := createTables(tx, &v0UserA{}, &v0UserB{}); err != nil {
+		return fmt.Errorf("failed to create legacy user tables: %w", err)
+	}
+	for _, name := range []string{"UserAID", "UserBID"} {
+		if err := addColumn(tx, &v0Session{}, name); err != nil {
+			return fmt.Errorf("failed to add column sessions.%s: %w", name, err)
+		}
+	}
+	for _, name := range []string{"UserA", "UserB"} {
+		if err := createConstraint(tx, &v0Session{}, name); err != nil {
+			return fmt.Errorf("failed to create constraint on sessions.%s: %w", name, err)
+		}
+	}
+
+	var sessions []v1Session
+	if err := tx.Where("kind = ?", "pair").Preload("Participants", func(db *gorm.DB) *gorm.DB {
+		return db.Order("id")
+	}).Find(&sessions).Error; err != nil {
+		return fmt.Errorf("failed to load pair sessions: %w", err)
+	}
+	for _, session := range sessions {
+		updates := map[string]interface{}{}
+		for _, participant := range session.Participants {
+			switch {
+			case participant.Role == "initiator" && updates["user_a_id"] == nil:
+				user := v0UserA{Model: participant.Model, Token: session.Token, Answers: participant.Answers, ShareAnswers: participant.ShareAnswers}
+				user.ID = 0
+				if err := tx.Create(&user).Error; err != nil {
+					return fmt.Errorf("failed to move answers of session %d: %w", session.ID, err)
+				}
+				updates["user_a_id"] = user.ID
+			case participant.Role == "member" && updates["user_b_id"] == nil:
+				user := v0UserB{Model: participant.Model, Token: session.Token, Answers: participant.Answers, ShareAnswers: participant.ShareAnswers}
+				user.ID = 0
+				if err := tx.Create(&user).Error; err != nil {
+					return fmt.Errorf("failed to move answers of session %d: %w", session.ID, err)
+				}
+				updates["user_b_id"] = user.ID
+			}
+		}
+		if len(updates) == 0 {
+			continue
+		}
+		if err := tx.Model(&v0Session{ID: session.ID}).Updates(updates).Error; err != nil {
+			return fmt.Errorf("failed to update session %d: %w", session.ID, err)
+		}
+		if err := tx.Unscoped().Where("session_id = ?", session.ID).Delete(&v1Participant{}).Error; err != nil {
+			return fmt.Errorf("failed to delete participants of session %d: %w", session.ID, err)
+		}
+	}
+	return restoreSessionIndexes(tx)
+}
+
+// newV1Participant builds a participant from a legacy user row, keeping its timestamps.
+func newV1Participant(sessionID uint, role string, model gorm.Model, answers string, shareAnswers bool) v1Participant {
+	participant := v1Participant{
+		SessionID:    sessionID,
+		Role:         role,
+		Answers:      answers,
+		ShareAnswers: shareAnswers,
+	}
+	participant.CreatedAt = model.CreatedAt
+	participant.UpdatedAt = model.UpdatedAt
+	return participant
+}
+
+// restoreSessionIndexes recreates the indexes of the sessions table.
+// SQLite rebuilds the table to add constraints or drop columns, which loses them.
+func restoreSessionIndexes(tx *gorm.DB) error {
+	return restoreIndexes(tx, &v1Session{}, "Token", "Status", "DeletedAt")
+}
+
+// restoreIndexes recreates the indexes a model declares on the given fields
+// if they are missing.
+func restoreIndexes(tx *gorm.DB, model interface{}, fields ...string) error {
+	migrator := tx.Migrator()
+	for _, field := range fields {
+		if !migrator.HasIndex(model, field) {
+			if err := migrator.CreateIndex(model, field); err != nil {
+				return fmt.Errorf("failed to restore index on %s: %w", field, err)
+			}
+		}
+	}
+	return nil
+}
+
+// The helpers below only change the schema if the change has not been made
+// yet. MySQL commits schema changes immediately, even inside a transaction,
+// so a migration that fails there can be left half applied; running it again
+// then skips what is already done.
+
+// createTables creates the tables of the models that do not exist yet.
+func createTables(tx *gorm.DB, models ...interface{}) error {
+	for _, model := range models {
+		if tx.Migrator().HasTable(model) {
+			continue
+		}
+		if err := tx.Migrator().CreateTable(model); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addColumn adds the column of a model's field if it is missing.
+func addColumn(tx *gorm.DB, model interface{}, field string) error {
+	if tx.Migrator().HasColumn(model, field) {
+		return nil
+	}
+	return tx.Migrator().AddColumn(model, field)
+}
+
+// dropColumn drops the column of a model's field if it exists.
+func dropColumn(tx *gorm.DB, model interface{}, field string) error {
+	if !tx.Migrator().HasColumn(model, field) {
+		return nil
+	}
+	return tx.Migrator().DropColumn(model, field)
+}
+
+// createIndex creates an index a model declares if it is missing.
+func createIndex(tx *gorm.DB, model interface{}, name string) error {
+	if tx.Migrator().HasIndex(model, name) {
+		return nil
+	}
+	return tx.Migrator().CreateIndex(model, name)
+}
+
+// dropIndex drops an index a model declares if it exists.
+func dropIndex(tx *gorm.DB, model interface{}, name string) error {
+	if !tx.Migrator().HasIndex(model, name) {
+		return nil
+	}
+	return tx.Migrator().DropIndex(model, name)
+}
+
+// createConstraint creates a foreign key a model declares if it is missing.
+func createConstraint(tx *gorm.DB, model interface{}, name string) error {
+	if tx.Migrator().HasConstraint(model, name) {
+		return nil
+	}
+	return tx.Migrator().CreateConstraint(model, name)
+}
+
+// v3AdminUser is the admin_users table at migration 3.
+type v3AdminUser struct {
+	gorm.Model
+	Username     string `gorm:"uniqueIndex;size:100"`
+	PasswordHash string `gorm:"size:255"`
+}
+
+func (v3AdminUser) TableName() string { return "admin_users" }
+
+// v3APIKey is the api_keys table at migration 3.
+type v3APIKey struct {
+	gorm.Model
+	AdminUserID uint `gorm:"index"`
+	AdminUser   v3AdminUser
+	Name        string `gorm:"size:100"`
+	Prefix      string `gorm:"size:20"`
+	KeyHash     string `gorm:"uniqueIndex;size:64"`
+	LastUsedAt  *time.Time
+}
+
+func (v3APIKey) TableName() string { return "api_keys" }
+
+// v3AdminSession is the admin_sessions table at migration 3.
+type v3AdminSession struct {
+	gorm.Model
+	AdminUserID uint `gorm:"index"`
+	AdminUser   v3AdminUser
+	TokenHash   string    `gorm:"uniqueIndex;size:64"`
+	ExpiresAt   time.Time `gorm:"index"`
+}
+
+func (v3AdminSession) TableName() string { return "admin_sessions" }
+
+// addAdminAuth creates the tables holding administrators and their credentials.
+func addAdminAuth(tx *gorm.DB) error {
+	return createTables(tx, &v3AdminUser{}, &v3APIKey{}, &v3AdminSession{})
+}
+
+// dropAdminAuth drops the tables holding administrators and their credentials.
+func dropAdminAuth(tx *gorm.DB) error {
+	return tx.Migrator().DropTable(&v3AdminSession{}, &v3APIKey{}, &v3AdminUser{})
+}
+
+// v4Question is the questions table at migration 4.
+type v4Question struct {
+	gorm.Model
+	ID               uint   `gorm:"primaryKey"`
+	QuestionText     string `gorm:"type:text"`
+	IsMultipleChoice bool
+	Options          string `gorm:"type:text"`
+	Position         int
+}
+
+func (v4Question) TableName() string { return "questions" }
+
+// v4QuestionSet is the question_sets table at migration 4.
+type v4QuestionSet struct {
+	gorm.Model
+	Version     int                 `gorm:"uniqueIndex"`
+	PublishedBy string              `gorm:"size:100"`
+	Questions   []v4QuestionSetItem `gorm:"foreignKey:QuestionSetID"`
+}
+
+func (v4QuestionSet) TableName() string { return "question_sets" }
+
+// v4QuestionSetItem is the question_set_items table at migration 4.
+type v4QuestionSetItem struct {
+	ID               uint `gorm:"primaryKey"`
+	QuestionSetID    uint `gorm:"index"`
+	QuestionID       uint
+	Position         int
+	QuestionText     string `gorm:"type:text"`
+	IsMultipleChoice bool
+	Options          string `gorm:"type:text"`
+}
+
+func (v4QuestionSetItem) TableName() string { return "question_set_items" }
+
+// v4Session is the part of the sessions table that references question sets.
+type v4Session struct {
+	ID            uint
+	QuestionSetID *uint `gorm:"index"`
+}
+
+func (v4Session) TableName() string { return "sessions" }
+
+// addQuestionSets adds question ordering and published question sets. Soft
+// deleted questions are purged, since they kept their IDs from being reused.
+// If the bank has questions they are published as version 1, and every
+// existing session is pinned to it.
+func addQuestionSets(tx *gorm.DB) error {
+	if err := addColumn(tx, &v4Question{}, "Position"); err != nil {
+		return fmt.Errorf("failed to add column questions.position: %w", err)
+	}
+	if err := tx.Unscoped().Where("deleted_at IS NOT NULL").Delete(&v4Question{}).Error; err != nil {
+		return fmt.Errorf("failed to purge deleted questions: %w", err)
+	}
+	if err := tx.Model(&v4Question{}).Where("1 = 1").UpdateColumn("position", gorm.Expr("id")).Error; err != nil {
+		return fmt.Errorf("failed to number questions: %w", err)
+	}
+	if err := createTables(tx, &v4QuestionSet{}, &v4QuestionSetItem{}); err != nil {
+		return fmt.Errorf("failed to create question set tables: %w", err)
+	}
+	if err := addColumn(tx, &v4Session{}, "QuestionSetID"); err != nil {
+		return fmt.Errorf("failed to add column sessions.question_set_id: %w", err)
+	}
+	if err := createIndex(tx, &v4Session{}, "QuestionSetID"); err != nil {
+		return fmt.Errorf("failed to create index on sessions.question_set_id: %w", err)
+	}
+
+	var questions []v4Question
+	if err := tx.Order("position, id").Find(&questions).Error; err != nil {
+		return fmt.Errorf("failed to load questions: %w", err)
+	}
+	if len(questions) == 0 {
+		return nil
+	}
+	// Reuse the set published by an earlier attempt that failed further on
+	var set v4QuestionSet
+	if err := tx.Where("version = ?", 1).Limit(1).Find(&set).Error; err != nil {
+		return fmt.Errorf("failed to load question set 1: %w", err)
+	}
+	if set.ID == 0 {
+		set = v4QuestionSet{Version: 1, PublishedBy: "migration"}
+		for _, question := range questions {
+			set.Questions = append(set.Questions, v4QuestionSetItem{
+				QuestionID:       question.ID,
+				Position:         question.Position,
+				QuestionText:     question.QuestionText,
+				IsMultipleChoice: question.IsMultipleChoice,
+				Options:          question.Options,
+			})
+		}
+		if err := tx.Create(&set).Error; err != nil {
+			return fmt.Errorf("failed to publish question set 1: %w", err)
+		}
+		log.Printf("Published %d existing questions as question set 1", len(questions))
+	}
+	if err := tx.Model(&v4Session{}).Where("question_set_id IS NULL").Update("question_set_id", set.ID).Error; err != nil {
+		return fmt.Errorf("failed to pin sessions to question set 1: %w", err)
+	}
+	return nil
+}
+
+// dropQuestionSets drops the question sets, the sessions' references to them
+// and question ordering.
+func dropQuestionSets(tx *gorm.DB) error {
+	migrator := tx.Migrator()
+	if err := dropIndex(tx, &v4Session{}, "QuestionSetID"); err != nil {
+		return fmt.Errorf("failed to drop index on sessions.question_set_id: %w", err)
+	}
+	if err := dropColumn(tx, &v4Session{}, "QuestionSetID"); err != nil {
+		return fmt.Errorf("failed to drop column sessions.question_set_id: %w", err)
+	}
+	if err := restoreSessionIndexes(tx); err != nil {
+		return err
+	}
+	if err := migrator.DropTable(&v4QuestionSetItem{}, &v4QuestionSet{}); err != nil {
+		return fmt.Errorf("failed to drop question set tables: %w", err)
+	}
+	if err := dropColumn(tx, &v4Question{}, "Position"); err != nil {
+		return fmt.Errorf("failed to drop column questions.position: %w", err)
+	}
+	return restoreIndexes(tx, &v4Question{}, "DeletedAt")
+}
+
+// v5Questionnaire is the questionnaires table at migration 5.
+type v5Questionnaire struct {
+	gorm.Model
+	Slug        string `gorm:"uniqueIndex;size:100"`
+	Title       string `gorm:"size:255"`
+	Description string `gorm:"type:text"`
+	Language    string `gorm:"size:20"`
+	Theme       string `gorm:"size:50"`
+}
+
+func (v5Questionnaire) TableName() string { return "questionnaires" }
+
+// v5Question is the part of the questions table that references questionnaires.
+type v5Question struct {
+	ID              uint
+	QuestionnaireID uint `gorm:"index"`
+}
+
+func (v5Question) TableName() string { return "questions" }
+
+// v5QuestionSet is the part of the question_sets table that references questionnaires.
+// Versions are numbered per questionnaire from migration 5 on.
+type v5QuestionSet struct {
+	ID              uint
+	QuestionnaireID uint `gorm:"uniqueIndex:idx_question_sets_questionnaire_version,priority:1"`
+	Version         int  `gorm:"uniqueIndex:idx_question_sets_questionnaire_version,priority:2"`
+}
+
+func (v5QuestionSet) TableName() string { return "question_sets" }
+
+// v5Session is the part of the sessions table that references questionnaires.
+type v5Session struct {
+	ID              uint
+	QuestionnaireID *uint `gorm:"index"`
+}
+
+func (v5Session) TableName() string { return "sessions" }
+
+// addQuestionnaires adds questionnaires, each with its own question bank and
+// question set versions. The existing questions, question sets and sessions
+// move to a new questionnaire named "default".
+func addQuestionnaires(tx *gorm.DB) error {
+	if err := createTables(tx, &v5Questionnaire{}); err != nil {
+		return fmt.Errorf("failed to create questionnaires: %w", err)
+	}
+	var questionnaire v5Questionnaire
+	err := tx.Where(v5Questionnaire{Slug: "default"}).
+		Attrs(v5Questionnaire{Title: "默认问卷", Language: "zh-CN"}).
+		FirstOrCreate(&questionnaire).Error
+	if err != nil {
+		return fmt.Errorf("failed to create the default questionnaire: %w", err)
+	}
+
+	for _, model := range []interface{}{&v5Question{}, &v5QuestionSet{}, &v5Session{}} {
+		if err := addColumn(tx, model, "QuestionnaireID"); err != nil {
+			return fmt.Errorf("failed to add column questionnaire_id: %w", err)
+		}
+		if err := tx.Model(model).Where("1 = 1").Update("questionnaire_id", questionnaire.ID).Error; err != nil {
+			return fmt.Errorf("failed to move rows to the default questionnaire: %w", err)
+		}
+	}
+	if err := createIndex(tx, &v5Question{}, "QuestionnaireID"); err != nil {
+		return fmt.Errorf("failed to create index on questions.questionnaire_id: %w", err)
+	}
+	if err := createIndex(tx, &v5Session{}, "QuestionnaireID"); err != nil {
+		return fmt.Errorf("failed to create index on sessions.questionnaire_id: %w", err)
+	}
+	if err := dropIndex(tx, &v4QuestionSet{}, "Version"); err != nil {
+		return fmt.Errorf("failed to drop index on question_sets.version: %w", err)
+	}
+	if err := createIndex(tx, &v5QuestionSet{}, "idx_question_sets_questionnaire_version"); err != nil {
+		return fmt.Errorf("failed to create index on question_sets.questionnaire_id, version: %w", err)
+	}
+	return nil
+}
+
+// dropQuestionnaires drops questionnaires. It refuses to if questions or
+// question sets belong to more than one questionnaire, since their question
+// banks and versions cannot be told apart without them.
+func dropQuestionnaires(tx *gorm.DB) error {
+	for _, model := range []interface{}{&v5Question{}, &v5QuestionSet{}} {
+		if !tx.Migrator().HasColumn(model, "QuestionnaireID") {
+			continue
+		}
+		var count int64
+		if err := tx.Model(model).Distinct("questionnaire_id").Count(&count).Error; err != nil {
+			return fmt.Errorf("failed to count questionnaires: %w", err)
+		}
+		if count > 1 {
+			return fmt.Errorf("questions of %d questionnaires exist, delete all but one first: %w", count, ErrIrreversible)
+		}
+	}
+
+	if err := dropIndex(tx, &v5QuestionSet{}, "idx_question_sets_questionnaire_version"); err != nil {
+		return fmt.Errorf("failed to drop index on question_sets.questionnaire_id, version: %w", err)
+	}
+	if err := dropIndex(tx, &v5Question{}, "QuestionnaireID"); err != nil {
+		return fmt.Errorf("failed to drop index on questions.questionnaire_id: %w", err)
+	}
+	if err := dropIndex(tx, &v5Session{}, "QuestionnaireID"); err != nil {
+		return fmt.Errorf("failed to drop index on sessions.questionnaire_id: %w", err)
+	}
+	for _, model := range []interface{}{&v5Question{}, &v5QuestionSet{}, &v5Session{}} {
+		if err := dropColumn(tx, model, "QuestionnaireID"); err != nil {
+			return fmt.Errorf("failed to drop column questionnaire_id: %w", err)
+		}
+	}
+	if err := restoreIndexes(tx, &v4Question{}, "DeletedAt"); err != nil {
+		return err
+	}
+	if err := restoreIndexes(tx, &v4QuestionSet{}, "Version", "DeletedAt"); err != nil {
+		return err
+	}
+	if err := restoreIndexes(tx, &v4Session{}, "QuestionSetID"); err != nil {
+		return err
+	}
+	if err := restoreSessionIndexes(tx); err != nil {
+		return err
+	}
+	if err := tx.Migrator().DropTable(&v5Questionnaire{}); err != nil {
+		return fmt.Errorf("failed to drop questionnaires: %w", err)
+	}
+	return nil
+}
+
+// v6Question is the part of the questions table that describes question types.
+type v6Question struct {
+	ID       uint
+	Type     string `gorm:"size:20"`
+	Required bool
+	Min      *float64
+	Max      *float64
+}
+
+func (v6Question) TableName() string { return "questions" }
+
+// v6QuestionSetItem is the part of the question_set_items table that describes question types.
+type v6QuestionSetItem struct {
+	ID       uint
+	Type     string `gorm:"size:20"`
+	Required bool
+	Min      *float64
+	Max      *float64
+}
+
+func (v6QuestionSetItem) TableName() string { return "question_set_items" }
+
+// v6QuestionTypeColumns lists the columns added by migration 6.
+var v6QuestionTypeColumns = []string{"Type", "Required", "Min", "Max"}
+
+// addQuestionTypes adds question types, required flags and ranges. Existing
+// multiple choice questions become single choice, the others free text.
+func addQuestionTypes(tx *gorm.DB) error {
+	for _, model := range []interface{}{&v6Question{}, &v6QuestionSetItem{}} {
+		for _, column := range v6QuestionTypeColumns {
+			if err := addColumn(tx, model, column); err != nil {
+				return fmt.Errorf("failed to add column %s: %w", column, err)
+			}
+		}
+		err := tx.Model(model).Where("type IS NULL OR type = ''").UpdateColumns(map[string]interface{}{
+			"type":     gorm.Expr("CASE WHEN is_multiple_choice THEN 'single_choice' ELSE 'text' END"),
+			"required": false,
+		}).Error
+		if err != nil {
+			return fmt.Errorf("failed to set question types: %w", err)
+		}
+	}
+	return nil
+}
+
+// dropQuestionTypes drops question types. It refuses to if any question has a
+// type other than single choice or free text, since older versions cannot
+// show those questions or read their answers.
+func dropQuestionTypes(tx *gorm.DB) error {
+	for _, model := range []interface{}{&v6Question{}, &v6QuestionSetItem{}} {
+		if !tx.Migrator().HasColumn(model, "Type") {
+			continue
+		}
+		var count int64
+		err := tx.Model(model).Where("type NOT IN ?", []string{"single_choice", "text"}).Count(&count).Error
+		if err != nil {
+			return fmt.Errorf("failed to count typed questions: %w", err)
+		}
+		if count > 0 {
+			return fmt.Errorf("%d questions are neither single choice nor free text: %w", count, ErrIrreversible)
+		}
+	}
+	for _, model := range []interface{}{&v6Question{}, &v6QuestionSetItem{}} {
+		for _, column := range v6QuestionTypeColumns {
+			if err := dropColumn(tx, model, column); err != nil {
+				return fmt.Errorf("failed to drop column %s: %w", column, err)
+			}
+		}
+	}
+	if err := restoreIndexes(tx, &v4Question{}, "DeletedAt"); err != nil {
+		return err
+	}
+	if err := restoreIndexes(tx, &v5Question{}, "QuestionnaireID"); err != nil {
+		return err
+	}
+	return restoreIndexes(tx, &v4QuestionSetItem{}, "QuestionSetID")
+}
+
+// v7Participant is the part of the participants table that counts revisions.
+type v7Participant struct {
+	ID        uint
+	Revisions int
+}
+
+func (v7Participant) TableName() string { return "participants" }
+
+// v7IdempotentRequest is the idempotent_requests table at migration 7.
+type v7IdempotentRequest struct {
+	ID             uint   `gorm:"primaryKey"`
+	Endpoint       string `gorm:"uniqueIndex:idx_idempotent_requests_endpoint_key,priority:1;size:100"`
+	IdempotencyKey string `gorm:"uniqueIndex:idx_idempotent_requests_endpoint_key,priority:2;size:255"`
+	RequestHash    string `gorm:"size:64"`
+	StatusCode     int
+	Response       string    `gorm:"type:text"`
+	CreatedAt      time.Time `gorm:"index"`
+}
+
+func (v7IdempotentRequest) TableName() string { return "idempotent_requests" }
+
+// addSubmissionGuards counts the revisions of participants' answers and
+// creates the table of responses to idempotent requests.
+func addSubmissionGuards(tx *gorm.DB) error {
+	if err := addColumn(tx, &v7Participant{}, "Revisions"); err != nil {
+		return fmt.Errorf("failed to add column Revisions: %w", err)
+	}
+	if err := tx.Model(&v7Participant{}).Where("revisions IS NULL").UpdateColumn("revisions", 0).Error; err != nil {
+		return fmt.Errorf("failed to set revisions: %w", err)
+	}
+	return createTables(tx, &v7IdempotentRequest{})
+}
+
+// dropSubmissionGuards drops the revision counts and the responses to
+// idempotent requests.
+func dropSubmissionGuards(tx *gorm.DB) error {
+	if err := tx.Migrator().DropTable(&v7IdempotentRequest{}); err != nil {
+		return fmt.Errorf("failed to drop idempotent requests: %w", err)
+	}
+	if err := dropColumn(tx, &v7Participant{}, "Revisions"); err != nil {
+		return fmt.Errorf("failed to drop column Revisions: %w", err)
+	}
+	return restoreIndexes(tx, &v1Participant{}, "SessionID", "DeletedAt")
+}
+
+// v8Session is the part of the sessions table that holds the session token.
+type v8Session struct {
+	ID    uint
+	Token string
+}
+
+func (v8Session) TableName() string { return "sessions" }
+
+// v8SessionToken is the session_tokens table at migration 8.
+type v8SessionToken struct {
+	gorm.Model
+	SessionID     uint `gorm:"index"`
+	ParticipantID *uint
+	Purpose       string `gorm:"size:20"`
+	TokenHash     string `gorm:"uniqueIndex;size:64"`
+	ExpiresAt     *time.Time
+}
+
+func (v8SessionToken) TableName() string { return "session_tokens" }
+
+// splitSessionTokens moves the session tokens to their own table, storing
+// only their hashes. The existing tokens become legacy tokens, which keep
+// granting everything they did.
+func splitSessionTokens(tx *gorm.DB) error {
+	if err := createTables(tx, &v8SessionToken{}); err != nil {
+		return fmt.Errorf("failed to create session tokens: %w", err)
+	}
+
+	var sessions []v8Session
+	if tx.Migrator().HasColumn(&v8Session{}, "Token") {
+		// Skip the tokens copied by an earlier attempt that failed further on
+		copied := tx.Model(&v8SessionToken{}).Select("session_id")
+		if err := tx.Where("token <> '' AND id NOT IN (?)", copied).Find(&sessions).Error; err != nil {
+			return fmt.Errorf("failed to read session tokens: %w", err)
+		}
+	}
+	tokens := make([]v8SessionToken, 0, len(sessions))
+	for _, session := range sessions {
+		hash := sha256.Sum256([]byte(session.Token))
+		tokens = append(tokens, v8SessionToken{
+			SessionID: session.ID,
+			Purpose:   "legacy",
+			TokenHash: hex.EncodeToString(hash[:]),
+		})
+	}
+	if len(tokens) > 0 {
+		if err := tx.CreateInBatches(&tokens, 500).Error; err != nil {
+			return fmt.Errorf("failed to copy session tokens: %w", err)
+		}
+	}
+
+	if err := dropIndex(tx, &v1Session{}, "Token"); err != nil {
+		return fmt.Errorf("failed to drop index on sessions.token: %w", err)
+	}
+	if err := dropColumn(tx, &v8Session{}, "Token"); err != nil {
+		return fmt.Errorf("failed to drop column token: %w", err)
+	}
+	if err := restoreIndexes(tx, &v1Session{}, "Status", "DeletedAt"); err != nil {
+		return err
+	}
+	if err := restoreIndexes(tx, &v4Session{}, "QuestionSetID"); err != nil {
+		return err
+	}
+	return restoreIndexes(tx, &v5Session{}, "QuestionnaireID")
+}
+
+// joinSessionTokens moves the session tokens back into the sessions table. It
+// refuses to if there are sessions, since only the hashes of their tokens are
+// stored.
+func joinSessionTokens(tx *gorm.DB) error {
+	var count int64
+	if err := tx.Model(&v8Session{}).Count(&count).Error; err != nil {
+		return fmt.Errorf("failed to count sessions: %w", err)
+	}
+	if count > 0 {
+		return fmt.Errorf("%d sessions only have hashed tokens, delete them first: %w", count, ErrIrreversible)
+	}
+
+	if err := tx.Migrator().DropTable(&v8SessionToken{}); err != nil {
+		return fmt.Errorf("failed to drop session tokens: %w", err)
+	}
+	if err := addColumn(tx, &v1Session{}, "Token"); err != nil {
+		return fmt.Errorf("failed to add column token: %w", err)
+	}
+	return restoreSessionIndexes(tx)
+}
+
+// v9PurgeRecord is the purge_records table at migration 9.
+type v9PurgeRecord struct {
+	ID               uint      `gorm:"primaryKey"`
+	CreatedAt        time.Time `gorm:"index"`
+	SessionID        uint      `gorm:"index"`
+	SessionKind      string    `gorm:"size:20"`
+	SessionCreatedAt time.Time
+	Reason           string `gorm:"size:30"`
+	Trigger          string `gorm:"size:20"`
+}
+
+func (v9PurgeRecord) TableName() string { return "purge_records" }
+
+// addPurgeRecords creates the audit log of sessions deleted under the
+// retention policy.
+func addPurgeRecords(tx *gorm.DB) error {
+	return createTables(tx, &v9PurgeRecord{})
+}
+
+// dropPurgeRecords drops the audit log of purged sessions, unless it records
+// any.
+func dropPurgeRecords(tx *gorm.DB) error {
+	if !tx.Migrator().HasTable(&v9PurgeRecord{}) {
+		return nil
+	}
+	var count int64
+	if err := tx.Model(&v9PurgeRecord{}).Count(&count).Error; err != nil {
+		return fmt.Errorf("failed to count purge records: %w", err)
+	}
+	if count > 0 {
+		return fmt.Errorf("the audit log records %d purged sessions: %w", count, ErrIrreversible)
+	}
+	return tx.Migrator().DropTable(&v9PurgeRecord{})
+}
+
+// v10Participant is the part of the participants table that records erasure.
+type v10Participant struct {
+	ID       uint
+	ErasedAt *time.Time
+}
+
+func (v10Participant) TableName() string { return "participants" }
+
+// addParticipantErasure records when participants erased their answers.
+func addParticipantErasure(tx *gorm.DB) error {
+	if err := addColumn(tx, &v10Participant{}, "ErasedAt"); err != nil {
+		return fmt.Errorf("failed to add column ErasedAt: %w", err)
+	}
+	return nil
+}
+
+// dropParticipantErasure drops the erasure times, unless a participant has
+// erased their answers: earlier versions do not know erased sessions.
+func dropParticipantErasure(tx *gorm.DB) error {
+	if !tx.Migrator().HasColumn(&v10Participant{}, "ErasedAt") {
+		return restoreIndexes(tx, &v1Participant{}, "SessionID", "DeletedAt")
+	}
+	var count int64
+	if err := tx.Model(&v10Participant{}).Where("erased_at IS NOT NULL").Count(&count).Error; err != nil {
+		return fmt.Errorf("failed to count erased participants: %w", err)
+	}
+	if count > 0 {
+		return fmt.Errorf("%d participants erased their answers, delete their sessions first: %w", count, ErrIrreversible)
+	}
+	if err := dropColumn(tx, &v10Participant{}, "ErasedAt"); err != nil {
+		return fmt.Errorf("failed to drop column ErasedAt: %w", err)
+	}
+	return restoreIndexes(tx, &v1Participant{}, "SessionID", "DeletedAt")
+}
+
+// v11IdempotentRequest is the part of the idempotent_requests table that
+// links responses to the session their tokens belong to.
+type v11IdempotentRequest struct {
+	ID        uint
+	SessionID *uint `gorm:"index"`
+}
+
+func (v11IdempotentRequest) TableName() string { return "idempotent_requests" }
+
+// redactIdempotentResponses links stored responses to their sessions. The
+// stored responses are deleted: they hold tokens in plaintext, and are only
+// kept for a day to replay retries anyway.
+func redactIdempotentResponses(tx *gorm.DB) error {
+	if err := tx.Where("1 = 1").Delete(&v7IdempotentRequest{}).Error; err != nil {
+		return fmt.Errorf("failed to delete idempotent requests: %w", err)
+	}
+	if err := addColumn(tx, &v11IdempotentRequest{}, "SessionID"); err != nil {
+		return fmt.Errorf("failed to add column SessionID: %w", err)
+	}
+	return restoreIndexes(tx, &v11IdempotentRequest{}, "SessionID")
+}
+
+// unredactIdempotentResponses drops the sessions of stored responses. The
+// stored responses are deleted, since earlier versions would replay the
+// token hashes in them as tokens.
+func unredactIdempotentResponses(tx *gorm.DB) error {
+	if err := tx.Where("1 = 1").Delete(&v7IdempotentRequest{}).Error; err != nil {
+		return fmt.Errorf("failed to delete idempotent requests: %w", err)
+	}
+	if err := dropIndex(tx, &v11IdempotentRequest{}, "SessionID"); err != nil {
+		return fmt.Errorf("failed to drop index on idempotent_requests.session_id: %w", err)
+	}
+	if err := dropColumn(tx, &v11IdempotentRequest{}, "SessionID"); err != nil {
+		return fmt.Errorf("failed to drop column SessionID: %w", err)
+	}
+	return restoreIndexes(tx, &v7IdempotentRequest{}, "idx_idempotent_requests_endpoint_key", "CreatedAt")
+}
+
+// deleteIdempotentRequests deletes the stored responses, both ways: from
+// migration 12 keys are stored hashed and scoped to the caller, and responses
+// encrypted, so neither version can read the other's.
+func deleteIdempotentRequests(tx *gorm.DB) error {
+	if err := tx.Where("1 = 1").Delete(&v7IdempotentRequest{}).Error; err != nil {
+		return fmt.Errorf("failed to delete idempotent requests: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,302 @@
+// Package encryption encrypts personal data at rest: the answers of
+// participants and the summaries of sessions. Values are sealed with
+// envelope encryption: each value is encrypted with AES-256-GCM under a
+// random data key of its own, which is in turn encrypted with a key of the
+// Keyring. Stored values name the key their data key is encrypted with, so
+// keys can be rotated: see Keyring.Rekey.
+//
+// Model fields are encrypted by tagging them with serializer:encrypted, once
+// the serializer is registered, see Register. The serializer takes the
+// keyring from the context of the *gorm.DB, see Attach.
+package encryption
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"openai-api/pkg/config"
+)
+
+// prefix starts every encrypted value. Values without it are plaintext,
+// written before encryption was enabled.
+const prefix = "enc:v1:"
+
+// keySize is the size of keys and data keys in bytes, selecting AES-256.
+const keySize = 32
+
+// keyIDPattern matches valid key IDs.
+var keyIDPattern = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,32}$`)
+
+// Keyring holds the keys data keys are encrypted with, by ID. New values are
+// encrypted with the active key; the others are kept to decrypt values
+// written before the keys were rotated. A Keyring without keys stores
+// values unencrypted.
+type Keyring struct {
+	keys   map[string][]byte
+	active string
+}
+
+// NewKeyring returns the keyring configured by cfg: the keys in cfg.Keys and
+// in cfg.KeyFile, each given as id:key with the key base64 encoded. The
+// active key is cfg.ActiveKey, or the first key.
+func NewKeyring(cfg config.Encryption) (*Keyring, error) {
+	keyring := &Keyring{keys: make(map[string][]byte)}
+	for _, entry := range strings.Split(cfg.Keys, ",") {
+		if err := keyring.add(entry); err != nil {
+			return nil, fmt.Errorf("invalid encryption.keys: %w", err)
+		}
+	}
+	if cfg.KeyFile != "" {
+		file, err := os.Open(cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read encryption key file: %w", err)
+		}
+		defer file.Close()
+		scanner := bufio.NewScanner(file)
+		for line := 1; scanner.Scan(); line++ {
+			if strings.HasPrefix(strings.TrimSpace(scanner.Text()), "#") {
+				continue
+			}
+			if err := keyring.add(scanner.Text()); err != nil {
+				return nil, fmt.Errorf("invalid encryption key file %s, line %d: %w", cfg.KeyFile, line, err)
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("failed to read encryption key file: %w", err)
+		}
+	}
+
+	if cfg.ActiveKey != "" {
+		if _, ok := keyring.keys[cfg.ActiveKey]; !ok {
+			return nil, fmt.Errorf("encryption.active_key %q is not one of the encryption keys", cfg.ActiveKey)
+		}
+		keyring.active = cfg.ActiveKey
+	}
+	return keyring, nil
+}
+
+// add adds a key given as id:key, ignoring blank entries. The first key
+// added becomes the active key.
+func (k *Keyring) add(entry string) error {
+	entry = strings.TrimSpace(entry)
+	if entry == "" {
+		return nil
+	}
+	id, encoded, ok := strings.Cut(entry, ":")
+	if !ok || !keyIDPattern.MatchString(id) {
+		return errors.New("keys must be given as id:key, where the ID consists of letters, digits, '_', '.' or '-'")
+	}
+	if _, ok := k.keys[id]; ok {
+		return fmt.Errorf("key %q is given twice", id)
+	}
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(key) != keySize {
+		return fmt.Errorf("key %q must be %d bytes, base64 encoded", id, keySize)
+	}
+	k.keys[id] = key
+	if k.active == "" {
+		k.active = id
+	}
+	return nil
+}
+
+// Enabled reports whether new values are encrypted.
+func (k *Keyring) Enabled() bool {
+	return k.active != ""
+}
+
+// ActiveKey returns the ID of the key new values are encrypted with, or ""
+// if encryption is not enabled.
+func (k *Keyring) ActiveKey() string {
+	return k.active
+}
+
+// Seal encrypts plaintext with a new data key, encrypted with the active
+// key. aad names what the value is, e.g. "participants.answers"; Open must
+// be given the same. Empty values are not encrypted, nor is anything if the
+// keyring has no keys.
+func (k *Keyring) Seal(plaintext, aad string) (string, error) {
+	if plaintext == "" || !k.Enabled() {
+		return plaintext, nil
+	}
+	dataKey := make([]byte, keySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return "", err
+	}
+	data, err := seal(dataKey, []byte(plaintext), []byte(aad))
+	if err != nil {
+		return "", err
+	}
+	return k.wrap(dataKey, data)
+}
+
+// Open decrypts a value returned by Seal. Plaintext values are returned as is.
+func (k *Keyring) Open(stored, aad string) (string, error) {
+	env, encrypted, err := parse(stored)
+	if err != nil || !encrypted {
+		return stored, err
+	}
+	dataKey, err := k.unwrap(env)
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := open(dataKey, env.data, []byte(aad))
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt %s: %w", aad, err)
+	}
+	return string(plaintext), nil
+}
+
+// Rekey returns stored as it would be encrypted with the active key, and
+// whether that differs from stored. Plaintext values are encrypted; values
+// encrypted with another key only get their data key encrypted again, the
+// data itself is left as it is.
+func (k *Keyring) Rekey(stored, aad string) (string, bool, error) {
+	if !k.Enabled() {
+		return "", false, errors.New("no encryption keys configured")
+	}
+	env, encrypted, err := parse(stored)
+	switch {
+	case err != nil:
+		return "", false, err
+	case stored == "" || (encrypted && env.keyID == k.active):
+		return stored, false, nil
+	case !encrypted:
+		rekeyed, err := k.Seal(stored, aad)
+		return rekeyed, err == nil, err
+	}
+	dataKey, err := k.unwrap(env)
+	if err != nil {
+		return "", false, err
+	}
+	rekeyed, err := k.wrap(dataKey, env.data)
+	return rekeyed, err == nil, err
+}
+
+// SealWithSecret encrypts plaintext under a key derived from secret rather
+// than with a keyring, for values only whoever knows secret may read back,
+// e.g. the responses replayed to the client that chose their idempotency key.
+// aad names what the value is; OpenWithSecret must be given the same.
+func SealWithSecret(secret, plaintext, aad string) (string, error) {
+	sealed, err := seal(secretKey(secret), []byte(plaintext), []byte(aad))
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// OpenWithSecret decrypts a value returned by SealWithSecret.
+func OpenWithSecret(secret, stored, aad string) (string, error) {
+	sealed, err := base64.StdEncoding.DecodeString(stored)
+	if err != nil {
+		return "", errors.New("malformed encrypted value")
+	}
+	plaintext, err := open(secretKey(secret), sealed, []byte(aad))
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt %s: %w", aad, err)
+	}
+	return string(plaintext), nil
+}
+
+// secretKey derives the key SealWithSecret encrypts with from secret. It is
+// not a plain hash of secret, which may be stored to look values up.
+func secretKey(secret string) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("encryption.SealWithSecret"))
+	return mac.Sum(nil)
+}
+
+// envelope is an encrypted value: the data key, encrypted with key keyID,
+// and the data, encrypted with the data key.
+type envelope struct {
+	keyID   string
+	dataKey []byte
+	data    []byte
+}
+
+// parse splits an encrypted value into its parts. It reports false for
+// plaintext values.
+func parse(stored string) (envelope, bool, error) {
+	rest, ok := strings.CutPrefix(stored, prefix)
+	if !ok {
+		return envelope{}, false, nil
+	}
+	parts := strings.Split(rest, ":")
+	if len(parts) != 3 {
+		return envelope{}, true, errors.New("malformed encrypted value")
+	}
+	dataKey, err := base64.StdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return envelope{}, true, errors.New("malformed encrypted value")
+	}
+	data, err := base64.StdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return envelope{}, true, errors.New("malformed encrypted value")
+	}
+	return envelope{keyID: parts[0], dataKey: dataKey, data: data}, true, nil
+}
+
+// wrap encrypts dataKey with the active key and formats the encrypted value.
+func (k *Keyring) wrap(dataKey, data []byte) (string, error) {
+	wrapped, err := seal(k.keys[k.active], dataKey, []byte(k.active))
+	if err != nil {
+		return "", err
+	}
+	return prefix + k.active + ":" + base64.StdEncoding.EncodeToString(wrapped) + ":" + base64.StdEncoding.EncodeToString(data), nil
+}
+
+// unwrap decrypts the data key of env.
+func (k *Keyring) unwrap(env envelope) ([]byte, error) {
+	key, ok := k.keys[env.keyID]
+	if !ok {
+		return nil, fmt.Errorf("value is encrypted with unknown key %q", env.keyID)
+	}
+	dataKey, err := open(key, env.dataKey, []byte(env.keyID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt data key with key %q: %w", env.keyID, err)
+	}
+	return dataKey, nil
+}
+
+// seal encrypts plaintext with AES-GCM under key, prefixing the random nonce.
+func seal(key, plaintext, aad []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, aad), nil
+}
+
+// open decrypts a value returned by seal.
+func open(key, sealed, aad []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, errors.New("malformed encrypted value")
+	}
+	return gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], aad)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,206 @@
+package encryption
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"openai-api/pkg/config"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// testKey returns a key entry for a keyring, with every byte of the key b.
+func testKey(id string, b byte) string {
+	return id + ":" + base64.StdEncoding.EncodeToString([]byte(strings.Repeat(string(rune(b)), keySize)))
+}
+
+func newTestKeyring(t *testing.T, cfg config.Encryption) *Keyring {
+	t.Helper()
+	keyring, err := NewKeyring(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return keyring
+}
+
+func TestSealOpen(t *testing.T) {
+	keyring := newTestKeyring(t, config.Encryption{Keys: testKey("k1", 'a')})
+	for _, plaintext := range []string{"", "hello", `{"1":"红"}`} {
+		sealed, err := keyring.Seal(plaintext, "participants.answers")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if plaintext != "" && !strings.HasPrefix(sealed, prefix+"k1:") {
+			t.Errorf("Seal(%q) = %q, want it encrypted with k1", plaintext, sealed)
+		}
+		if plaintext != "" && strings.Contains(sealed, plaintext) {
+			t.Errorf("Seal(%q) = %q, contains the plaintext", plaintext, sealed)
+		}
+		opened, err := keyring.Open(sealed, "participants.answers")
+		if err != nil || opened != plaintext {
+			t.Errorf("Open(Seal(%q)) = %q, %v", plaintext, opened, err)
+		}
+	}
+
+	// Values are bound to what they are
+	sealed, err := keyring.Seal("hello", "participants.answers")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := keyring.Open(sealed, "sessions.summary"); err == nil {
+		t.Error("Open with other additional data succeeded")
+	}
+}
+
+func TestOpenWrongKey(t *testing.T) {
+	sealed, err := newTestKeyring(t, config.Encryption{Keys: testKey("k1", 'a')}).Seal("hello", "sessions.summary")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, keys := range map[string]string{
+		"unknown key":   testKey("k2", 'a'),
+		"different key": testKey("k1", 'b'),
+	} {
+		if _, err := newTestKeyring(t, config.Encryption{Keys: keys}).Open(sealed, "sessions.summary"); err == nil {
+			t.Errorf("%s: Open succeeded", name)
+		}
+	}
+}
+
+func TestOpenTampered(t *testing.T) {
+	keyring := newTestKeyring(t, config.Encryption{Keys: testKey("k1", 'a')})
+	sealed, err := keyring.Seal("hello", "sessions.summary")
+	if err != nil {
+		t.Fatal(err)
+	}
+	env, _, err := parse(sealed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encode := base64.StdEncoding.EncodeToString
+	flip := func(b []byte) []byte {
+		flipped := append([]byte(nil), b...)
+		flipped[len(flipped)-1] ^= 1
+		return flipped
+	}
+	for name, stored := range map[string]string{
+		"data":       prefix + "k1:" + encode(env.dataKey) + ":" + encode(flip(env.data)),
+		"data key":   prefix + "k1:" + encode(flip(env.dataKey)) + ":" + encode(env.data),
+		"truncated":  prefix + "k1:" + encode(env.dataKey) + ":" + encode(env.data[:4]),
+		"malformed":  prefix + "k1:" + encode(env.dataKey),
+		"not base64": prefix + "k1:" + encode(env.dataKey) + ":!",
+	} {
+		if _, err := keyring.Open(stored, "sessions.summary"); err == nil {
+			t.Errorf("%s: Open succeeded", name)
+		}
+	}
+}
+
+func TestPlaintextPassthrough(t *testing.T) {
+	disabled := newTestKeyring(t, config.Encryption{})
+	sealed, err := disabled.Seal("hello", "sessions.summary")
+	if err != nil || sealed != "hello" {
+		t.Errorf("Seal without keys = %q, %v, want the plaintext", sealed, err)
+	}
+
+	// Values written before encryption was enabled are read as they are
+	for _, keyring := range []*Keyring{disabled, newTestKeyring(t, config.Encryption{Keys: testKey("k1", 'a')})} {
+		opened, err := keyring.Open("hello", "sessions.summary")
+		if err != nil || opened != "hello" {
+			t.Errorf("Open(plaintext) = %q, %v", opened, err)
+		}
+	}
+}
+
+func TestRekey(t *testing.T) {
+	old := newTestKeyring(t, config.Encryption{Keys: testKey("k1", 'a')})
+	rotated := newTestKeyring(t, config.Encryption{Keys: testKey("k1", 'a') + "," + testKey("k2", 'b'), ActiveKey: "k2"})
+	sealed, err := old.Seal("hello", "sessions.summary")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rekeyed, changed, err := rotated.Rekey(sealed, "sessions.summary")
+	if err != nil || !changed || !strings.HasPrefix(rekeyed, prefix+"k2:") {
+		t.Fatalf("Rekey = %q, %v, %v, want it encrypted with k2", rekeyed, changed, err)
+	}
+	if opened, err := rotated.Open(rekeyed, "sessions.summary"); err != nil || opened != "hello" {
+		t.Errorf("Open(Rekey) = %q, %v", opened, err)
+	}
+	if _, err := old.Open(rekeyed, "sessions.summary"); err == nil {
+		t.Error("Open of the rekeyed value without k2 succeeded")
+	}
+
+	// Values encrypted with the active key, and empty ones, are left alone
+	for _, stored := range []string{rekeyed, ""} {
+		if again, changed, err := rotated.Rekey(stored, "sessions.summary"); err != nil || changed || again != stored {
+			t.Errorf("Rekey(%q) = %q, %v, %v, want it unchanged", stored, again, changed, err)
+		}
+	}
+
+	// Plaintext values are encrypted
+	rekeyed, changed, err = rotated.Rekey("hello", "sessions.summary")
+	if err != nil || !changed || !strings.HasPrefix(rekeyed, prefix+"k2:") {
+		t.Errorf("Rekey(plaintext) = %q, %v, %v", rekeyed, changed, err)
+	}
+
+	if _, _, err := newTestKeyring(t, config.Encryption{}).Rekey(sealed, "sessions.summary"); err == nil {
+		t.Error("Rekey without keys succeeded")
+	}
+}
+
+func TestSealWithSecret(t *testing.T) {
+	sealed, err := SealWithSecret("secret", "hello", "POST /api/submit-user-a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opened, err := OpenWithSecret("secret", sealed, "POST /api/submit-user-a"); err != nil || opened != "hello" {
+		t.Errorf("OpenWithSecret = %q, %v", opened, err)
+	}
+	if _, err := OpenWithSecret("other", sealed, "POST /api/submit-user-a"); err == nil {
+		t.Error("OpenWithSecret with another secret succeeded")
+	}
+	if _, err := OpenWithSecret("secret", sealed, "POST /api/submit-user-b"); err == nil {
+		t.Error("OpenWithSecret with other additional data succeeded")
+	}
+}
+
+// encryptedRecord is a model with an encrypted field.
+type encryptedRecord struct {
+	ID     uint
+	Secret string `gorm:"type:text;serializer:encrypted"`
+}
+
+func TestSerializer(t *testing.T) {
+	Register()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&encryptedRecord{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(&encryptedRecord{Secret: "hello"}).Error; err == nil {
+		t.Error("Create without a keyring attached succeeded")
+	}
+
+	keyring := newTestKeyring(t, config.Encryption{Keys: testKey("k1", 'a')})
+	record := encryptedRecord{Secret: "hello"}
+	if err := Attach(db, keyring).Create(&record).Error; err != nil {
+		t.Fatal(err)
+	}
+	var stored string
+	if err := db.Table("encrypted_records").Select("secret").Where("id = ?", record.ID).Scan(&stored).Error; err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(stored, prefix+"k1:") {
+		t.Errorf("stored %q, want it encrypted with k1", stored)
+	}
+	var loaded encryptedRecord
+	if err := Attach(db, keyring).First(&loaded, record.ID).Error; err != nil || loaded.Secret != "hello" {
+		t.Errorf("loaded %q, %v", loaded.Secret, err)
+	}
+}
@@ -0,0 +1,135 @@
+package encryption
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// Register registers Serializer as the serializer of fields tagged
+// serializer:encrypted. database.Open calls it, so models can declare
+// encrypted fields without depending on this package.
+func Register() {
+	schema.RegisterSerializer("encrypted", Serializer{})
+}
+
+// contextKey is the key of the keyring in the context of a *gorm.DB.
+type contextKey struct{}
+
+// Attach returns db with keyring in its context, for the serializer to
+// encrypt and decrypt with. Every *gorm.DB reading or writing encrypted
+// fields must derive from it; a context without a keyring is an error
+// rather than a reason to store plaintext.
+func Attach(db *gorm.DB, keyring *Keyring) *gorm.DB {
+	return db.WithContext(context.WithValue(db.Statement.Context, contextKey{}, keyring))
+}
+
+// keyringFrom returns the keyring attached to ctx.
+func keyringFrom(ctx context.Context, field *schema.Field) (*Keyring, error) {
+	keyring, ok := ctx.Value(contextKey{}).(*Keyring)
+	if !ok {
+		return nil, fmt.Errorf("no encryption keyring attached to the database to read or write %s", fieldName(field))
+	}
+	return keyring, nil
+}
+
+// fieldName names a field as table.column, the additional data its values
+// are encrypted with.
+func fieldName(field *schema.Field) string {
+	return field.Schema.Table + "." + field.DBName
+}
+
+// Serializer encrypts string fields tagged serializer:encrypted with the
+// keyring attached to the database, see Attach.
+//
+// Only updates from structs go through serializers: columns updated from a
+// map[string]interface{} are written as given, unencrypted.
+type Serializer struct{}
+
+// Scan decrypts the stored value into the field.
+func (Serializer) Scan(ctx context.Context, field *schema.Field, dst reflect.Value, dbValue interface{}) error {
+	var stored string
+	switch value := dbValue.(type) {
+	case nil:
+	case string:
+		stored = value
+	case []byte:
+		stored = string(value)
+	default:
+		return fmt.Errorf("failed to decrypt %s: unsupported value of type %T", fieldName(field), dbValue)
+	}
+	plaintext := stored
+	if _, encrypted, _ := parse(stored); encrypted {
+		keyring, err := keyringFrom(ctx, field)
+		if err != nil {
+			return err
+		}
+		if plaintext, err = keyring.Open(stored, fieldName(field)); err != nil {
+			return err
+		}
+	}
+	field.ReflectValueOf(ctx, dst).SetString(plaintext)
+	return nil
+}
+
+// Value encrypts the field for storing it.
+func (Serializer) Value(ctx context.Context, field *schema.Field, dst reflect.Value, fieldValue interface{}) (interface{}, error) {
+	plaintext, ok := fieldValue.(string)
+	if !ok {
+		return nil, fmt.Errorf("failed to encrypt %s: only strings can be encrypted", fieldName(field))
+	}
+	keyring, err := keyringFrom(ctx, field)
+	if err != nil {
+		return nil, err
+	}
+	return keyring.Seal(plaintext, fieldName(field))
+}
+
+// rekeyBatchSize is the number of rows RekeyColumn reads at a time.
+const rekeyBatchSize = 500
+
+// RekeyColumn re-encrypts the values of column in table with the active
+// key, see Keyring.Rekey, including soft-deleted rows. A value changed by
+// someone else meanwhile is left to them, already encrypted with the active
+// key. It returns the number of values it changed.
+func RekeyColumn(db *gorm.DB, keyring *Keyring, table, column string) (int64, error) {
+	if !keyring.Enabled() {
+		return 0, errors.New("no encryption keys configured")
+	}
+	aad := table + "." + column
+	var changed int64
+	var lastID uint
+	for {
+		var rows []struct {
+			ID     uint
+			Stored string
+		}
+		err := db.Table(table).Select("id, "+column+" AS stored").Where("id > ? AND "+column+" <> ''", lastID).
+			Order("id").Limit(rekeyBatchSize).Scan(&rows).Error
+		if err != nil {
+			return changed, fmt.Errorf("failed to read %s: %w", aad, err)
+		}
+		for _, row := range rows {
+			rekeyed, ok, err := keyring.Rekey(row.Stored, aad)
+			if err != nil {
+				return changed, fmt.Errorf("failed to re-encrypt %s of row %d: %w", aad, row.ID, err)
+			}
+			if !ok {
+				continue
+			}
+			result := db.Table(table).Where("id = ? AND "+column+" = ?", row.ID, row.Stored).UpdateColumn(column, rekeyed)
+			if result.Error != nil {
+				return changed, fmt.Errorf("failed to update %s of row %d: %w", aad, row.ID, result.Error)
+			}
+			changed += result.RowsAffected
+		}
+		if len(rows) < rekeyBatchSize {
+			return changed, nil
+		}
+		lastID = rows[len(rows)-1].ID
+	}
+}
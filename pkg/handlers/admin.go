@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"openai-api/pkg/apierror"
+	"openai-api/pkg/auth"
+)
+
+// AdminLoginRequest represents the request body for logging in as an administrator.
+type AdminLoginRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// AdminResponse describes the logged in administrator.
+type AdminResponse struct {
+	Username  string     `json:"username"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+}
+
+// AdminLogin handles POST /api/admin/login. It checks the administrator's
+// password and sets the session cookie used by the management endpoints.
+func (a *App) AdminLogin(w http.ResponseWriter, r *http.Request) {
+	var req AdminLoginRequest
+	if !a.decodeRequest(w, r, &req) {
+		return
+	}
+
+	admin, token, expiresAt, err := a.Auth.Login(req.Username, req.Password)
+	if errors.Is(err, auth.ErrInvalidCredentials) {
+		a.Logger.Printf("Failed admin login for %q from %s", req.Username, r.RemoteAddr)
+		apierror.Write(w, http.StatusUnauthorized, apierror.CodeInvalidCredentials, "Invalid username or password")
+		return
+	}
+	if err != nil {
+		a.Logger.Printf("Error logging in admin %q: %v", req.Username, err)
+		apierror.Write(w, http.StatusInternalServerError, apierror.CodeInternal, "Failed to log in")
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     auth.SessionCookie,
+		Value:    token,
+		Path:     "/api",
+		Expires:  expiresAt,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteStrictMode,
+	})
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(AdminResponse{Username: admin.Username, ExpiresAt: &expiresAt})
+}
+
+// AdminLogout handles POST /api/admin/logout. It ends the current session
+// and clears the session cookie.
+func (a *App) AdminLogout(w http.ResponseWriter, r *http.Request) {
+	if cookie, err := r.Cookie(auth.SessionCookie); err == nil {
+		if err := a.Auth.Logout(cookie.Value); err != nil {
+			a.Logger.Printf("Error logging out admin session: %v", err)
+			apierror.Write(w, http.StatusInternalServerError, apierror.CodeInternal, "Failed to log out")
+			return
+		}
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     auth.SessionCookie,
+		Path:     "/api",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteStrictMode,
+	})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// AdminMe handles GET /api/admin/me. It returns the authenticated administrator.
+func (a *App) AdminMe(w http.ResponseWriter, r *http.Request) {
+	admin, ok := auth.AdminFromContext(r.Context())
+	if !ok {
+		apierror.Write(w, http.StatusUnauthorized, apierror.CodeUnauthorized, "Unauthorized")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(AdminResponse{Username: admin.Username})
+}
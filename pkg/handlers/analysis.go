@@ -0,0 +1,327 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+
+	"openai-api/pkg/answers"
+	"openai-api/pkg/jobs"
+	"openai-api/pkg/llm"
+	"openai-api/pkg/models"
+	"openai-api/pkg/scoring"
+
+	"gorm.io/gorm"
+)
+
+// analysisInput is the user message sent to the LLM.
+type analysisInput struct {
+	Questions []analysisQuestion `json:"questions"`
+}
+
+// analysisQuestion is both users' answers to one question, as sent to the LLM.
+type analysisQuestion struct {
+	ID       uint   `json:"id"`
+	Question string `json:"question"`
+	analysisQuestionType
+	UserA interface{} `json:"userA"`
+	UserB interface{} `json:"userB"`
+}
+
+// analysisQuestionType describes the type of a question sent to the LLM, so
+// it can read the answers: the options of choice and ranking questions, the
+// labels or range of scales and the range of numbers.
+type analysisQuestionType struct {
+	Type    string   `json:"type"`
+	Options []string `json:"options,omitempty"`
+	Min     *float64 `json:"min,omitempty"`
+	Max     *float64 `json:"max,omitempty"`
+}
+
+// newAnalysisQuestionType describes the type of a question for the LLM.
+func newAnalysisQuestionType(question models.Question) analysisQuestionType {
+	response := questionResponse(question)
+	return analysisQuestionType{Type: response.Type, Options: response.Options, Min: response.Min, Max: response.Max}
+}
+
+// ProcessAnalysis runs the compatibility analysis for a session.
+// It is the handler for the background analysis queue. If it returns an
+// error, the session is marked failed rather than left running.
+func (a *App) ProcessAnalysis(ctx context.Context, sessionID uint) error {
+	var session models.Session
+	if err := a.DB.First(&session, sessionID).Error; err != nil {
+		return fmt.Errorf("failed to load session: %w", err)
+	}
+
+	// Mark the job as running, unless another worker has claimed it or it is
+	// no longer pending. The answers are loaded afterwards, so revisions made
+	// while the job was queued are analysed.
+	claimed, err := transitionSession(a.DB, session.ID, models.SessionStatusRunning, models.SessionStatusPending)
+	if err != nil {
+		return fmt.Errorf("failed to mark session running: %w", err)
+	}
+	if !claimed {
+		return nil
+	}
+	defer a.jobs.Finish(session.ID)
+
+	if session.Kind == models.SessionKindGroup {
+		err = a.processGroupAnalysis(ctx, session)
+	} else {
+		err = a.processPairAnalysis(ctx, session)
+	}
+	if err != nil {
+		a.failAnalysis(session, err)
+	}
+	return err
+}
+
+// processPairAnalysis runs the analysis of a pair session marked running.
+func (a *App) processPairAnalysis(ctx context.Context, session models.Session) error {
+	if err := a.DB.Where("session_id = ?", session.ID).Order("id").Find(&session.Participants).Error; err != nil {
+		return fmt.Errorf("failed to load participants: %w", err)
+	}
+	userA, userB := pairParticipants(session)
+	if userA == nil || userB == nil {
+		return fmt.Errorf("session %d has no User B answers", session.ID)
+	}
+
+	// Generate compatibility score and summary using the LLM
+	status := models.SessionStatusDone
+	analysisError, errorCode := "", ""
+	var verdict *OpenAIResponse
+	var engine string
+	pairs, err := a.pairSessionAnswers(session, userA, userB)
+	if err == nil {
+		if a.Config.Scorer == scoring.Engine {
+			verdict = scoreLocally(pairs)
+			engine = scoring.Engine
+		} else {
+			verdict, engine, err = a.generateCompatibilityScore(ctx, pairs, func(delta string) {
+				a.jobs.Publish(session.ID, delta)
+			})
+			if err != nil && jobs.Interrupted(ctx) {
+				return a.requeueInterrupted(session)
+			}
+			if err != nil && a.Config.ScorerFallback {
+				// Fall back to the local scorer, keeping the LLM error for diagnostics
+				a.Logger.Printf("Failed to generate compatibility score, using local scorer: %v", err)
+				analysisError, errorCode = err.Error(), analysisErrorCode(err)
+				verdict, engine, err = scoreLocally(pairs), scoring.Engine, nil
+			}
+		}
+	}
+	if engine == scoring.Engine && err == nil {
+		// Replace any summary the model streamed before it failed
+		a.jobs.Replace(session.ID, verdict.Summary)
+	}
+	if err != nil {
+		// Record the failure instead of inventing a score
+		a.Logger.Printf("Failed to generate compatibility score: %v", err)
+		verdict, engine = &OpenAIResponse{}, ""
+		status = models.SessionStatusFailed
+		analysisError = err.Error()
+		errorCode = analysisErrorCode(err)
+	}
+
+	// Update session with compatibility score, summary and per-question breakdown
+	return a.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("session_id = ?", session.ID).Delete(&models.QuestionScore{}).Error; err != nil {
+			return fmt.Errorf("failed to clear question scores: %w", err)
+		}
+		scores := make([]models.QuestionScore, 0, len(verdict.Breakdown))
+		for _, item := range verdict.Breakdown {
+			scores = append(scores, models.QuestionScore{
+				SessionID:  session.ID,
+				QuestionID: item.QuestionID,
+				Question:   item.Question,
+				Score:      item.Score,
+				Note:       item.Note,
+			})
+		}
+		if len(scores) > 0 {
+			if err := tx.Create(&scores).Error; err != nil {
+				return fmt.Errorf("failed to save question scores: %w", err)
+			}
+		}
+
+		// Update from a struct, so that the summary is encrypted
+		return tx.Model(&session).Select("compatibility", "summary", "status", "analysis_error", "error_code", "engine").Updates(&models.Session{
+			Compatibility: verdict.Compatibility,
+			Summary:       verdict.Summary,
+			Status:        status,
+			AnalysisError: analysisError,
+			ErrorCode:     errorCode,
+			Engine:        engine,
+		}).Error
+	})
+}
+
+// pairSessionAnswers matches both users' answers to the questions they were given against.
+func (a *App) pairSessionAnswers(session models.Session, userA, userB *models.Participant) ([]scoring.Pair, error) {
+	// Parse both users' answers
+	userAAnswers, err := answers.Parse(userA.Answers)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse User A answers: %w", err)
+	}
+	userBAnswers, err := answers.Parse(userB.Answers)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse User B answers: %w", err)
+	}
+
+	questions, err := a.sessionQuestions(session)
+	if err != nil {
+		return nil, err
+	}
+	return scoring.PairAnswers(questions, userAAnswers, userBAnswers), nil
+}
+
+// scoreLocally scores paired answers with the offline scoring engine.
+func scoreLocally(pairs []scoring.Pair) *OpenAIResponse {
+	result := scoring.ScorePairs(pairs)
+	verdict := &OpenAIResponse{
+		Compatibility: result.Compatibility,
+		Summary:       result.Summary,
+		Breakdown:     []QuestionBreakdown{},
+	}
+	for _, question := range result.Questions {
+		score := int(math.Round(question.Similarity * 100))
+		note := fmt.Sprintf("两人回答的相似度约为%d%%。", score)
+		switch question.Kind {
+		case scoring.KindChoice:
+			note = "两人选择了不同的选项。"
+			if question.Similarity == 1 {
+				note = "两人选择了相同的选项。"
+			}
+		case scoring.KindMultiSelect:
+			note = fmt.Sprintf("两人所选选项的重合度约为%d%%。", score)
+		case scoring.KindScale, scoring.KindNumber:
+			note = fmt.Sprintf("两人给出的数值接近程度约为%d%%。", score)
+		case scoring.KindRanking:
+			note = fmt.Sprintf("两人排序的一致程度约为%d%%。", score)
+		}
+		verdict.Breakdown = append(verdict.Breakdown, QuestionBreakdown{
+			QuestionID: question.QuestionID,
+			Question:   question.Question,
+			Score:      score,
+			Note:       note,
+		})
+	}
+	return verdict
+}
+
+// generateCompatibilityScore generates a compatibility score and summary using the configured LLM provider.
+// The summary is passed to onDelta piece by piece while the model generates it.
+// The output is validated against verdictSchema and repaired by the model if needed.
+// It also returns the engine that produced the result, as "provider/model".
+func (a *App) generateCompatibilityScore(ctx context.Context, pairs []scoring.Pair, onDelta func(string)) (*OpenAIResponse, string, error) {
+	// Send both users' answers to every question, with the question IDs the breakdown refers to
+	input := analysisInput{Questions: []analysisQuestion{}}
+	for _, pair := range pairs {
+		input.Questions = append(input.Questions, analysisQuestion{
+			ID:                   pair.Question.ID,
+			Question:             pair.Question.QuestionText,
+			analysisQuestionType: newAnalysisQuestionType(pair.Question),
+			UserA:                pair.A,
+			UserB:                pair.B,
+		})
+	}
+	answersJSON, err := json.Marshal(input)
+	if err != nil {
+		return nil, "", err
+	}
+	provider := a.LLM
+	if provider == nil {
+		return nil, "", errNoLLM
+	}
+
+	// Prepare the request
+	request := &llm.Request{
+		System: a.Config.SystemPrompt,
+		Messages: []llm.Message{
+			{
+				Role:    "user",
+				Content: string(answersJSON),
+			},
+		},
+		Temperature:    0.7,
+		MaxTokens:      4096,
+		ResponseFormat: a.verdictResponseFormat("compatibility_verdict", verdictSchema),
+	}
+
+	// Stream the response, relaying the summary as it is generated
+	extractor := &summaryExtractor{}
+	response, err := provider.Stream(ctx, request, func(content string) {
+		if delta := extractor.Write(content); delta != "" && onDelta != nil {
+			onDelta(delta)
+		}
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get response from %s: %w", provider.Name(), err)
+	}
+
+	engine := provider.Name()
+	if response.Model != "" {
+		engine += "/" + response.Model
+	}
+
+	// Validate the output, asking the model to repair it if needed
+	verdict, err := a.parseVerdict(ctx, provider, request, response.Content)
+	if err != nil {
+		return nil, "", err
+	}
+	resolveBreakdown(verdict, pairs)
+	return verdict, engine, nil
+}
+
+// resolveBreakdown ties the LLM's breakdown items to the questions that were sent.
+// Items naming an unknown question ID are matched by question text instead,
+// and the stored question text is always the one from the question bank.
+func resolveBreakdown(verdict *OpenAIResponse, pairs []scoring.Pair) {
+	byID := make(map[uint]models.Question, len(pairs))
+	byText := make(map[string]models.Question, len(pairs))
+	for _, pair := range pairs {
+		if pair.Question.ID != 0 {
+			byID[pair.Question.ID] = pair.Question
+		}
+		byText[pair.Question.QuestionText] = pair.Question
+	}
+
+	for i, item := range verdict.Breakdown {
+		question, ok := byID[item.QuestionID]
+		if !ok {
+			question, ok = byText[item.Question]
+		}
+		if ok {
+			verdict.Breakdown[i].QuestionID = question.ID
+			verdict.Breakdown[i].Question = question.QuestionText
+		} else {
+			verdict.Breakdown[i].QuestionID = 0
+		}
+	}
+}
+
+// errNoLLM is returned when an analysis needs the LLM but the App has none.
+var errNoLLM = errors.New("no LLM provider configured")
+
+// analysisErrorCode classifies an analysis error into a code the frontend can show.
+func analysisErrorCode(err error) string {
+	switch {
+	case errors.Is(err, llm.ErrRateLimit):
+		return "rate_limited"
+	case errors.Is(err, llm.ErrTimeout):
+		return "timeout"
+	case errors.Is(err, llm.ErrAuthentication):
+		return "provider_auth"
+	case errors.Is(err, llm.ErrServer):
+		return "provider_unavailable"
+	case errors.Is(err, llm.ErrBadRequest):
+		return "provider_rejected"
+	case errors.Is(err, errInvalidVerdict):
+		return "invalid_output"
+	default:
+		return "analysis_failed"
+	}
+}
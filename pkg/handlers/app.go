@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"openai-api/pkg/auth"
+	"openai-api/pkg/jobs"
+	"openai-api/pkg/llm"
+	"openai-api/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+// App holds what the handlers and the analysis workers depend on. The HTTP
+// handlers are its methods, so an App can be served by any router, e.g. in
+// tests with an in-memory database and a fake LLM.
+type App struct {
+	// DB stores the sessions, questions and administrators.
+	DB *gorm.DB
+
+	// LLM generates the analyses, unless Config.Scorer selects the local scorer.
+	LLM llm.Provider
+
+	// Config holds the settings.
+	Config Config
+
+	// Logger receives the log messages.
+	Logger *log.Logger
+
+	// Auth authenticates administrators. Wrap the management handlers in
+	// Auth.RequireAdmin.
+	Auth *auth.Service
+
+	// jobs runs the analyses in the background once Start is called.
+	jobs *jobs.Queue
+}
+
+// New creates an App. A nil logger logs to the standard logger.
+func New(db *gorm.DB, provider llm.Provider, config Config, logger *log.Logger) *App {
+	if logger == nil {
+		logger = log.Default()
+	}
+	app := &App{
+		DB:     db,
+		LLM:    provider,
+		Config: config,
+		Logger: logger,
+		Auth:   auth.NewService(db),
+	}
+	app.Auth.SessionDuration = config.AdminSessionDuration
+	app.jobs = jobs.NewQueue(app.ProcessAnalysis, config.AnalysisTimeout)
+	return app
+}
+
+// Start launches the background analysis workers and re-enqueues the
+// analyses left unfinished by a previous process. Until it is called,
+// submitted analyses wait in the queue; call ProcessAnalysis to run one.
+func (a *App) Start() {
+	a.jobs.Start(a.DB, a.Config.AnalysisWorkers)
+}
+
+// Shutdown stops the analysis workers taking new jobs and waits for the
+// running analyses to finish. If ctx is done first, the analyses are
+// cancelled and their sessions left pending, so the next process that calls
+// Start runs them again. It does not close the database.
+func (a *App) Shutdown(ctx context.Context) error {
+	return a.jobs.Shutdown(ctx)
+}
+
+// requeueInterrupted leaves a session whose analysis was cancelled by
+// Shutdown pending, for the next process to run it again.
+func (a *App) requeueInterrupted(session models.Session) error {
+	if _, err := transitionSession(a.DB, session.ID, models.SessionStatusPending, models.SessionStatusRunning); err != nil {
+		return fmt.Errorf("failed to requeue interrupted analysis: %w", err)
+	}
+	a.Logger.Printf("Analysis of session %d interrupted by shutdown, left pending", session.ID)
+	return nil
+}
+
+// failAnalysis marks a session whose analysis returned err failed, if it is
+// still running, so that it does not wait for a result forever.
+func (a *App) failAnalysis(session models.Session, cause error) {
+	err := a.DB.Transaction(func(tx *gorm.DB) error {
+		failed, err := transitionSession(tx, session.ID, models.SessionStatusFailed, models.SessionStatusRunning)
+		if err != nil || !failed {
+			return err
+		}
+		return tx.Model(&models.Session{}).Where("id = ?", session.ID).Updates(map[string]interface{}{
+			"analysis_error": cause.Error(),
+			"error_code":     analysisErrorCode(cause),
+		}).Error
+	})
+	if err != nil {
+		a.Logger.Printf("Failed to mark analysis of session %d failed: %v", session.ID, err)
+	}
+}
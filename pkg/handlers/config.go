@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"time"
+
+	"openai-api/pkg/auth"
+)
+
+// Config holds the settings of the handlers and the analysis workers.
+// Start from DefaultConfig; server.NewApp fills it in from config.Config.
+type Config struct {
+	// SystemPrompt is the system prompt of pair analyses.
+	SystemPrompt string
+
+	// GroupSystemPrompt is the system prompt of group analyses.
+	GroupSystemPrompt string
+
+	// Scorer is "local" to score answers offline instead of with the LLM.
+	Scorer string
+
+	// ScorerFallback falls back to the local scorer when the LLM fails.
+	ScorerFallback bool
+
+	// ResponseFormat is the response format requested from the LLM:
+	// "json_object", "json_schema" or "none".
+	ResponseFormat string
+
+	// MaxRepairs is how many times invalid LLM output is sent back to be repaired.
+	MaxRepairs int
+
+	// DefaultQuestionnaire is the slug of the questionnaire used when a
+	// request names none.
+	DefaultQuestionnaire string
+
+	// MaxRequestBytes is the maximum size of a request body.
+	MaxRequestBytes int64
+
+	// MaxAnswerLength is the maximum number of characters of a text answer.
+	MaxAnswerLength int
+
+	// MaxAnswerRevisions is how many times User B may revise their answers,
+	// requeueing the analysis. Zero forbids revisions.
+	MaxAnswerRevisions int
+
+	// AnalysisWorkers is the number of background analysis workers.
+	AnalysisWorkers int
+
+	// AnalysisTimeout is how long a single analysis may take.
+	AnalysisTimeout time.Duration
+
+	// AdminSessionDuration is how long an administrator stays logged in.
+	AdminSessionDuration time.Duration
+
+	// InviteTokenDuration is how long invitation tokens work. Zero means
+	// they do not expire.
+	InviteTokenDuration time.Duration
+
+	// ResultsTokenDuration is how long results tokens work. Zero means
+	// they do not expire.
+	ResultsTokenDuration time.Duration
+
+	// LegacyTokenDuration is how long legacy tokens keep working after
+	// migration 8 converted them. Zero means they no longer work.
+	LegacyTokenDuration time.Duration
+}
+
+// DefaultConfig returns the default settings, without system prompts.
+func DefaultConfig() Config {
+	return Config{
+		ScorerFallback:       true,
+		ResponseFormat:       "json_object",
+		MaxRepairs:           2,
+		DefaultQuestionnaire: "default",
+		MaxRequestBytes:      1 << 20,
+		MaxAnswerLength:      2000,
+		AnalysisWorkers:      2,
+		AnalysisTimeout:      120 * time.Second,
+		AdminSessionDuration: auth.DefaultSessionDuration,
+		LegacyTokenDuration:  30 * 24 * time.Hour,
+	}
+}
@@ -0,0 +1,846 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"openai-api/pkg/answers"
+	"openai-api/pkg/apierror"
+	"openai-api/pkg/jobs"
+	"openai-api/pkg/llm"
+	"openai-api/pkg/models"
+	"openai-api/pkg/scoring"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// maxGroupSize is the maximum number of members an initiator can invite.
+// Every pair of participants gets its own score, so the model output grows
+// quadratically with the group size.
+const maxGroupSize = 8
+
+// groupStatusOpen is the status reported for group sessions still accepting members.
+const groupStatusOpen = "open"
+
+// Errors returned by the JoinGroup transaction when no more members can join.
+var (
+	errGroupFull     = errors.New("group is full")
+	errGroupStarted  = errors.New("group analysis has already started")
+	errSessionErased = errors.New("a participant has erased their answers")
+)
+
+// CreateGroupRequest represents the request body for creating a group session.
+type CreateGroupRequest struct {
+	Name               string          `json:"name"`
+	Size               int             `json:"size"`
+	Answers            answers.Answers `json:"answers"`
+	ShareAnswers       bool            `json:"shareAnswers"`
+	Questionnaire      string          `json:"questionnaire"`      // Slug of the questionnaire, empty for the default one
+	QuestionSetVersion int             `json:"questionSetVersion"` // Zero for the latest question set
+}
+
+// CreateGroupResponse represents the response body for creating a group session.
+// InviteToken lets members join; ResultsToken lets the initiator see the results.
+type CreateGroupResponse struct {
+	InviteToken  string `json:"inviteToken"`
+	ResultsToken string `json:"resultsToken"`
+}
+
+// JoinGroupRequest represents the request body for joining a group session.
+type JoinGroupRequest struct {
+	Name         string          `json:"name"`
+	Answers      answers.Answers `json:"answers"`
+	ShareAnswers bool            `json:"shareAnswers"`
+}
+
+// JoinGroupResponse represents the response body for joining a group session.
+// ResultsToken lets the new member see the results; it is only returned by
+// JoinGroup.
+type JoinGroupResponse struct {
+	Success      bool   `json:"success"`
+	Status       string `json:"status"`
+	Joined       int    `json:"joined"`
+	Size         int    `json:"size"`
+	ResultsToken string `json:"resultsToken,omitempty"`
+}
+
+// GroupResultsResponse represents the response body for getting group results.
+// Matrix holds the pair scores in Participants order, with 100 on the diagonal.
+type GroupResultsResponse struct {
+	Status        string             `json:"status"`
+	Size          int                `json:"size"`
+	Joined        int                `json:"joined"`
+	Compatibility int                `json:"compatibility"`
+	Summary       string             `json:"summary"`
+	Participants  []GroupParticipant `json:"participants"`
+	Matrix        [][]int            `json:"matrix"`
+	Pairs         []PairVerdict      `json:"pairs"`
+	Error         string             `json:"error,omitempty"`
+	Engine        string             `json:"engine,omitempty"`
+}
+
+// GroupParticipant is a participant as shown in the group results.
+type GroupParticipant struct {
+	ID      uint            `json:"id"`
+	Name    string          `json:"name"`
+	Role    string          `json:"role"`
+	Shared  bool            `json:"shared"`
+	Answers answers.Answers `json:"answers,omitempty"`
+}
+
+// GroupVerdict represents the expected response structure from the LLM for group sessions.
+// Its JSON schema is groupVerdictSchema.
+type GroupVerdict struct {
+	Compatibility int           `json:"compatibility"`
+	Summary       string        `json:"summary"`
+	Pairs         []PairVerdict `json:"pairs"`
+}
+
+// PairVerdict is the verdict on two participants of a group session.
+type PairVerdict struct {
+	A     uint   `json:"a"`
+	B     uint   `json:"b"`
+	Score int    `json:"score"`
+	Note  string `json:"note"`
+}
+
+// groupAnalysisInput is the user message sent to the LLM for group sessions.
+type groupAnalysisInput struct {
+	Participants []groupAnalysisParticipant `json:"participants"`
+	Questions    []groupAnalysisQuestion    `json:"questions"`
+}
+
+// groupAnalysisParticipant identifies a participant in groupAnalysisInput.
+type groupAnalysisParticipant struct {
+	ID   uint   `json:"id"`
+	Name string `json:"name"`
+}
+
+// groupAnalysisQuestion is every participant's answer to one question, as sent to the LLM.
+type groupAnalysisQuestion struct {
+	ID       uint   `json:"id"`
+	Question string `json:"question"`
+	analysisQuestionType
+	Answers []groupAnalysisAnswer `json:"answers"`
+}
+
+// groupAnalysisAnswer is one participant's answer in groupAnalysisQuestion.
+type groupAnalysisAnswer struct {
+	ParticipantID uint        `json:"participantId"`
+	Answer        interface{} `json:"answer"`
+}
+
+// groupVerdictSchema is the JSON schema of GroupVerdict.
+var groupVerdictSchema = &llm.Schema{
+	Type:                 "object",
+	AdditionalProperties: boolPtr(false),
+	Required:             []string{"summary", "compatibility", "pairs"},
+	Properties: map[string]*llm.Schema{
+		"summary": {
+			Type:        "string",
+			Description: "对整个群体的总结与详细分析",
+		},
+		"compatibility": {
+			Type:        "integer",
+			Description: "整个群体的契合度，0-100",
+			Minimum:     float64Ptr(0),
+			Maximum:     float64Ptr(100),
+		},
+		"pairs": {
+			Type:        "array",
+			Description: "每两位成员之间的契合度，每一对只出现一次",
+			Items: &llm.Schema{
+				Type:                 "object",
+				AdditionalProperties: boolPtr(false),
+				Required:             []string{"a", "b", "score", "note"},
+				Properties: map[string]*llm.Schema{
+					"a":     {Type: "integer", Description: "第一位成员的 id"},
+					"b":     {Type: "integer", Description: "第二位成员的 id"},
+					"score": {Type: "integer", Description: "两人的契合度，0-100", Minimum: float64Ptr(0), Maximum: float64Ptr(100)},
+					"note":  {Type: "string", Description: "一句话点评两人之间的异同"},
+				},
+			},
+		},
+	},
+}
+
+// CreateGroup handles the POST /api/groups endpoint.
+func (a *App) CreateGroup(w http.ResponseWriter, r *http.Request) {
+	// Parse request body
+	var req CreateGroupRequest
+	if !a.decodeRequest(w, r, &req) {
+		return
+	}
+	if req.Size < 1 || req.Size > maxGroupSize {
+		writeValidationErrors(w, "Invalid group", FieldError{
+			Field:   "size",
+			Code:    answers.CodeInvalid,
+			Message: fmt.Sprintf("Group size must be between 1 and %d", maxGroupSize),
+		})
+		return
+	}
+
+	questionnaire, questionSetID, ok := a.resolveQuestionSet(w, req.Questionnaire, req.QuestionSetVersion)
+	if !ok {
+		return
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		name = "发起人"
+	}
+
+	// Create the session, the initiator's participant record and the tokens
+	session := models.Session{
+		Kind:            models.SessionKindGroup,
+		GroupSize:       req.Size,
+		QuestionnaireID: &questionnaire.ID,
+		QuestionSetID:   questionSetID,
+	}
+	checked, ok := a.checkAnswers(w, session, req.Answers, checkName(name)...)
+	if !ok {
+		return
+	}
+	var response CreateGroupResponse
+	err := a.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&session).Error; err != nil {
+			return err
+		}
+		initiator, err := newParticipant(session.ID, models.ParticipantRoleInitiator, name, checked, req.ShareAnswers)
+		if err != nil {
+			return err
+		}
+		if err := tx.Create(&initiator).Error; err != nil {
+			return err
+		}
+		if response.InviteToken, err = a.newSessionToken(tx, session.ID, nil, models.TokenPurposeInvite); err != nil {
+			return err
+		}
+		response.ResultsToken, err = a.newSessionToken(tx, session.ID, &initiator.ID, models.TokenPurposeResults)
+		return err
+	})
+	if err != nil {
+		apierror.Write(w, http.StatusInternalServerError, apierror.CodeInternal, "Failed to create group")
+		return
+	}
+
+	// Return response
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// JoinGroup handles the POST /api/groups/{token}/join endpoint.
+// The analysis is queued as soon as every invited member has joined.
+func (a *App) JoinGroup(w http.ResponseWriter, r *http.Request) {
+	// Parse request body
+	var req JoinGroupRequest
+	if !a.decodeRequest(w, r, &req) {
+		return
+	}
+
+	session, ok := a.findGroupSession(w, mux.Vars(r)["token"], models.TokenPurposeInvite, models.TokenPurposeLegacy)
+	if !ok {
+		return
+	}
+	if session.Status == models.SessionStatusErased {
+		apierror.Write(w, http.StatusGone, apierror.CodeDataErased, "A participant has erased their answers")
+		return
+	}
+	if session.Status != "" {
+		apierror.Write(w, http.StatusConflict, apierror.CodeAnalysisStarted, "Group analysis has already started")
+		return
+	}
+	checked, ok := a.checkAnswers(w, session, req.Answers, checkName(strings.TrimSpace(req.Name))...)
+	if !ok {
+		return
+	}
+
+	// Add the member unless the group is already full or closed, and queue
+	// the analysis once everyone has joined. The session row stays locked
+	// until then, so concurrent joins and erasures wait for each other.
+	var joined int64
+	var resultsToken string
+	var started bool
+	err := a.DB.Transaction(func(tx *gorm.DB) error {
+		var locked models.Session
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id", "status").First(&locked, session.ID).Error; err != nil {
+			return err
+		}
+		switch {
+		case locked.Status == models.SessionStatusErased:
+			return errSessionErased
+		case locked.Status != "":
+			return errGroupStarted
+		}
+		if err := tx.Model(&models.Participant{}).Where("session_id = ? AND role = ?", session.ID, models.ParticipantRoleMember).Count(&joined).Error; err != nil {
+			return err
+		}
+		if int(joined) >= session.GroupSize {
+			return errGroupFull
+		}
+
+		name := strings.TrimSpace(req.Name)
+		if name == "" {
+			name = fmt.Sprintf("成员%d", joined+1)
+		}
+		member, err := newParticipant(session.ID, models.ParticipantRoleMember, name, checked, req.ShareAnswers)
+		if err != nil {
+			return err
+		}
+		if err := tx.Create(&member).Error; err != nil {
+			return err
+		}
+		joined++
+		if resultsToken, err = a.newSessionToken(tx, session.ID, &member.ID, models.TokenPurposeResults); err != nil {
+			return err
+		}
+		if int(joined) >= session.GroupSize {
+			started, err = transitionSession(tx, session.ID, models.SessionStatusPending, "")
+		}
+		return err
+	})
+	switch {
+	case errors.Is(err, errGroupFull):
+		apierror.Write(w, http.StatusConflict, apierror.CodeGroupFull, "Group is already full")
+		return
+	case errors.Is(err, errGroupStarted):
+		apierror.Write(w, http.StatusConflict, apierror.CodeAnalysisStarted, "Group analysis has already started")
+		return
+	case errors.Is(err, errSessionErased):
+		apierror.Write(w, http.StatusGone, apierror.CodeDataErased, "A participant has erased their answers")
+		return
+	case err != nil:
+		apierror.Write(w, http.StatusInternalServerError, apierror.CodeInternal, "Failed to save member data")
+		return
+	}
+
+	status := groupStatusOpen
+	if started {
+		a.jobs.Enqueue(session.ID)
+		status = models.SessionStatusPending
+	}
+
+	// Return response
+	response := JoinGroupResponse{
+		Success:      true,
+		Status:       status,
+		Joined:       int(joined),
+		Size:         session.GroupSize,
+		ResultsToken: resultsToken,
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// AnalyzeGroup handles the POST /api/groups/{token}/analyze endpoint.
+// It starts the analysis before every invited member has joined.
+func (a *App) AnalyzeGroup(w http.ResponseWriter, r *http.Request) {
+	session, ok := a.findGroupSession(w, mux.Vars(r)["token"], models.TokenPurposeResults)
+	if !ok {
+		return
+	}
+	if session.Status == models.SessionStatusErased {
+		apierror.Write(w, http.StatusGone, apierror.CodeDataErased, "A participant has erased their answers")
+		return
+	}
+
+	var joined int64
+	if err := a.DB.Model(&models.Participant{}).Where("session_id = ? AND role = ?", session.ID, models.ParticipantRoleMember).Count(&joined).Error; err != nil {
+		apierror.Write(w, http.StatusInternalServerError, apierror.CodeInternal, "Failed to find session")
+		return
+	}
+	if joined == 0 {
+		apierror.Write(w, http.StatusConflict, apierror.CodeNoMembers, "No members have joined yet")
+		return
+	}
+
+	status, err := a.startGroupAnalysis(session.ID)
+	if err != nil {
+		apierror.Write(w, http.StatusInternalServerError, apierror.CodeInternal, "Failed to update session")
+		return
+	}
+	if status == groupStatusOpen {
+		apierror.Write(w, http.StatusConflict, apierror.CodeAnalysisStarted, "Group analysis has already started")
+		return
+	}
+
+	// Return response
+	response := JoinGroupResponse{
+		Success: true,
+		Status:  status,
+		Joined:  int(joined),
+		Size:    session.GroupSize,
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// GetGroupResults handles the GET /api/groups/{token} endpoint.
+// While members are still joining it returns the participants so far.
+func (a *App) GetGroupResults(w http.ResponseWriter, r *http.Request) {
+	session, ok := a.findGroupSession(w, mux.Vars(r)["token"], models.TokenPurposeResults, models.TokenPurposeLegacy)
+	if !ok {
+		return
+	}
+
+	// Report progress while the analysis is still queued or running
+	if session.Status == models.SessionStatusPending || session.Status == models.SessionStatusRunning {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(AnalysisStatusResponse{Status: session.Status})
+		return
+	}
+
+	if err := a.loadGroupResults(&session); err != nil {
+		apierror.Write(w, http.StatusInternalServerError, apierror.CodeInternal, "Failed to find session")
+		return
+	}
+
+	// Return response
+	response, err := buildGroupResultsResponse(session)
+	if err != nil {
+		a.Logger.Printf("Failed to build results for session %d: %v", session.ID, err)
+		apierror.Write(w, http.StatusInternalServerError, apierror.CodeInternal, "Failed to parse answers")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// StreamGroupResults handles the GET /api/groups/{token}/stream endpoint.
+// It streams the group summary like StreamResults does for pair sessions.
+func (a *App) StreamGroupResults(w http.ResponseWriter, r *http.Request) {
+	session, ok := a.findGroupSession(w, mux.Vars(r)["token"], models.TokenPurposeResults, models.TokenPurposeLegacy)
+	if !ok {
+		return
+	}
+	if session.Status == "" {
+		apierror.Write(w, http.StatusNotFound, apierror.CodeAnalysisNotStarted, "Group analysis has not started yet")
+		return
+	}
+
+	a.streamAnalysis(w, r, session.ID, func() (interface{}, error) {
+		if err := a.DB.First(&session, session.ID).Error; err != nil {
+			return nil, fmt.Errorf("failed to find session: %w", err)
+		}
+		if err := a.loadGroupResults(&session); err != nil {
+			return nil, err
+		}
+		return buildGroupResultsResponse(session)
+	})
+}
+
+// findGroupSession finds the group session a token for one of purposes
+// belongs to, writing the error response if there is none.
+func (a *App) findGroupSession(w http.ResponseWriter, token string, purposes ...string) (models.Session, bool) {
+	session, _, ok := a.findSessionToken(w, token, purposes...)
+	if ok && session.Kind != models.SessionKindGroup {
+		apierror.Write(w, http.StatusNotFound, apierror.CodeSessionNotFound, "Invalid token")
+		return session, false
+	}
+	return session, ok
+}
+
+// startGroupAnalysis queues the analysis of a group session that is still open.
+// It returns the new status, or groupStatusOpen if the analysis had already been started.
+func (a *App) startGroupAnalysis(sessionID uint) (string, error) {
+	started, err := transitionSession(a.DB, sessionID, models.SessionStatusPending, "")
+	if err != nil {
+		return "", err
+	}
+	if !started {
+		return groupStatusOpen, nil
+	}
+	a.jobs.Enqueue(sessionID)
+	return models.SessionStatusPending, nil
+}
+
+// loadGroupResults loads the participants and pair scores of a group session.
+func (a *App) loadGroupResults(session *models.Session) error {
+	if err := a.DB.Where("session_id = ?", session.ID).Order("id").Find(&session.Participants).Error; err != nil {
+		return fmt.Errorf("failed to load participants: %w", err)
+	}
+	if err := a.DB.Where("session_id = ?", session.ID).Order("participant_a_id, participant_b_id").Find(&session.PairScores).Error; err != nil {
+		return fmt.Errorf("failed to load pair scores: %w", err)
+	}
+	return nil
+}
+
+// buildGroupResultsResponse assembles the results of a group session,
+// hiding the answers of participants who chose not to share them.
+func buildGroupResultsResponse(session models.Session) (GroupResultsResponse, error) {
+	status := session.Status
+	if status == "" {
+		status = groupStatusOpen
+	}
+	response := GroupResultsResponse{
+		Status:        status,
+		Size:          session.GroupSize,
+		Compatibility: session.Compatibility,
+		Summary:       session.Summary,
+		Error:         session.ErrorCode,
+		Engine:        session.Engine,
+		Participants:  []GroupParticipant{},
+		Matrix:        [][]int{},
+		Pairs:         []PairVerdict{},
+	}
+
+	index := make(map[uint]int, len(session.Participants))
+	for i, participant := range session.Participants {
+		index[participant.ID] = i
+		if participant.Role == models.ParticipantRoleMember {
+			response.Joined++
+		}
+		item := GroupParticipant{
+			ID:     participant.ID,
+			Name:   participant.Name,
+			Role:   participant.Role,
+			Shared: participant.ShareAnswers,
+		}
+		if participant.ShareAnswers {
+			var err error
+			if item.Answers, err = answers.Parse(participant.Answers); err != nil {
+				return GroupResultsResponse{}, fmt.Errorf("failed to parse answers of participant %d: %w", participant.ID, err)
+			}
+		}
+		response.Participants = append(response.Participants, item)
+	}
+	if status == groupStatusOpen {
+		return response, nil
+	}
+
+	// Fill the matrix from the pair scores
+	for i := range session.Participants {
+		row := make([]int, len(session.Participants))
+		row[i] = 100
+		response.Matrix = append(response.Matrix, row)
+	}
+	for _, score := range session.PairScores {
+		response.Pairs = append(response.Pairs, PairVerdict{
+			A:     score.ParticipantAID,
+			B:     score.ParticipantBID,
+			Score: score.Score,
+			Note:  score.Note,
+		})
+		a, okA := index[score.ParticipantAID]
+		b, okB := index[score.ParticipantBID]
+		if okA && okB {
+			response.Matrix[a][b] = score.Score
+			response.Matrix[b][a] = score.Score
+		}
+	}
+	return response, nil
+}
+
+// processGroupAnalysis runs the analysis of a group session for
+// ProcessAnalysis, once it has marked the session running.
+func (a *App) processGroupAnalysis(ctx context.Context, session models.Session) error {
+	if err := a.DB.Where("session_id = ?", session.ID).Order("id").Find(&session.Participants).Error; err != nil {
+		return fmt.Errorf("failed to load participants: %w", err)
+	}
+	if len(session.Participants) < 2 {
+		return fmt.Errorf("group session %d has fewer than two participants", session.ID)
+	}
+
+	// Generate the pairwise scores and group summary using the LLM
+	status := models.SessionStatusDone
+	analysisError, errorCode := "", ""
+	var verdict *GroupVerdict
+	var engine string
+	members, questions, err := a.groupSessionAnswers(session)
+	if err == nil {
+		if a.Config.Scorer == scoring.Engine {
+			verdict = scoreGroupLocally(questions, members)
+			engine = scoring.Engine
+		} else {
+			verdict, engine, err = a.generateGroupScore(ctx, questions, members, func(delta string) {
+				a.jobs.Publish(session.ID, delta)
+			})
+			if err != nil && jobs.Interrupted(ctx) {
+				return a.requeueInterrupted(session)
+			}
+			if err != nil && a.Config.ScorerFallback {
+				// Fall back to the local scorer, keeping the LLM error for diagnostics
+				a.Logger.Printf("Failed to generate group compatibility scores, using local scorer: %v", err)
+				analysisError, errorCode = err.Error(), analysisErrorCode(err)
+				verdict, engine, err = scoreGroupLocally(questions, members), scoring.Engine, nil
+			}
+		}
+	}
+	if engine == scoring.Engine && err == nil {
+		// Replace any summary the model streamed before it failed
+		a.jobs.Replace(session.ID, verdict.Summary)
+	}
+	if err != nil {
+		// Record the failure instead of inventing a score
+		a.Logger.Printf("Failed to generate group compatibility scores: %v", err)
+		verdict, engine = &GroupVerdict{}, ""
+		status = models.SessionStatusFailed
+		analysisError = err.Error()
+		errorCode = analysisErrorCode(err)
+	}
+
+	// Update session with the group score, summary and pairwise matrix
+	return a.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("session_id = ?", session.ID).Delete(&models.PairScore{}).Error; err != nil {
+			return fmt.Errorf("failed to clear pair scores: %w", err)
+		}
+		scores := make([]models.PairScore, 0, len(verdict.Pairs))
+		for _, pair := range verdict.Pairs {
+			scores = append(scores, models.PairScore{
+				SessionID:      session.ID,
+				ParticipantAID: pair.A,
+				ParticipantBID: pair.B,
+				Score:          pair.Score,
+				Note:           pair.Note,
+			})
+		}
+		if len(scores) > 0 {
+			if err := tx.Create(&scores).Error; err != nil {
+				return fmt.Errorf("failed to save pair scores: %w", err)
+			}
+		}
+
+		// Update from a struct, so that the summary is encrypted
+		return tx.Model(&session).Select("compatibility", "summary", "status", "analysis_error", "error_code", "engine").Updates(&models.Session{
+			Compatibility: verdict.Compatibility,
+			Summary:       verdict.Summary,
+			Status:        status,
+			AnalysisError: analysisError,
+			ErrorCode:     errorCode,
+			Engine:        engine,
+		}).Error
+	})
+}
+
+// groupSessionAnswers parses the answers of every participant and loads the
+// questions they were given against.
+func (a *App) groupSessionAnswers(session models.Session) ([]scoring.Member, []models.Question, error) {
+	members := make([]scoring.Member, 0, len(session.Participants))
+	for _, participant := range session.Participants {
+		parsed, err := answers.Parse(participant.Answers)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to parse answers of participant %d: %w", participant.ID, err)
+		}
+		members = append(members, scoring.Member{ID: participant.ID, Name: participant.Name, Answers: parsed})
+	}
+
+	questions, err := a.sessionQuestions(session)
+	if err != nil {
+		return nil, nil, err
+	}
+	return members, questions, nil
+}
+
+// scoreGroupLocally scores every pair of members with the offline scoring engine.
+func scoreGroupLocally(questions []models.Question, members []scoring.Member) *GroupVerdict {
+	result := scoring.ScoreGroup(questions, members)
+	verdict := &GroupVerdict{
+		Compatibility: result.Compatibility,
+		Summary:       result.Summary,
+		Pairs:         []PairVerdict{},
+	}
+	for _, pair := range result.Pairs {
+		note := "两人没有共同回答的问题。"
+		if len(pair.Result.Questions) > 0 {
+			note = fmt.Sprintf("两人在%d道共同回答的问题上的契合度为%d%%。", len(pair.Result.Questions), pair.Result.Compatibility)
+		}
+		verdict.Pairs = append(verdict.Pairs, PairVerdict{
+			A:     pair.A,
+			B:     pair.B,
+			Score: pair.Result.Compatibility,
+			Note:  note,
+		})
+	}
+	return verdict
+}
+
+// generateGroupScore generates the pairwise scores and group summary using the configured LLM provider.
+// The summary is passed to onDelta piece by piece while the model generates it.
+// It also returns the engine that produced the result, as "provider/model".
+func (a *App) generateGroupScore(ctx context.Context, questions []models.Question, members []scoring.Member, onDelta func(string)) (*GroupVerdict, string, error) {
+	input := groupAnalysisInput{
+		Participants: []groupAnalysisParticipant{},
+		Questions:    groupQuestions(questions, members),
+	}
+	for _, member := range members {
+		input.Participants = append(input.Participants, groupAnalysisParticipant{ID: member.ID, Name: member.Name})
+	}
+	answersJSON, err := json.Marshal(input)
+	if err != nil {
+		return nil, "", err
+	}
+
+	provider := a.LLM
+	if provider == nil {
+		return nil, "", errNoLLM
+	}
+
+	// Prepare the request
+	request := &llm.Request{
+		System: a.Config.GroupSystemPrompt,
+		Messages: []llm.Message{
+			{
+				Role:    "user",
+				Content: string(answersJSON),
+			},
+		},
+		Temperature:    0.7,
+		MaxTokens:      4096,
+		ResponseFormat: a.verdictResponseFormat("group_verdict", groupVerdictSchema),
+	}
+
+	// Stream the response, relaying the summary as it is generated
+	extractor := &summaryExtractor{}
+	response, err := provider.Stream(ctx, request, func(content string) {
+		if delta := extractor.Write(content); delta != "" && onDelta != nil {
+			onDelta(delta)
+		}
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get response from %s: %w", provider.Name(), err)
+	}
+
+	engine := provider.Name()
+	if response.Model != "" {
+		engine += "/" + response.Model
+	}
+
+	// Validate the output, asking the model to repair it if needed
+	ids := make([]uint, 0, len(members))
+	for _, member := range members {
+		ids = append(ids, member.ID)
+	}
+	var verdict GroupVerdict
+	check := func(content string) []string {
+		return groupVerdictProblems(content, ids)
+	}
+	if err := a.parseModelOutput(ctx, provider, request, response.Content, groupVerdictSchema, check, &verdict); err != nil {
+		return nil, "", err
+	}
+
+	// Store every pair with the lower participant ID first
+	for i, pair := range verdict.Pairs {
+		if pair.A > pair.B {
+			verdict.Pairs[i].A, verdict.Pairs[i].B = pair.B, pair.A
+		}
+	}
+	sort.Slice(verdict.Pairs, func(i, j int) bool {
+		if verdict.Pairs[i].A != verdict.Pairs[j].A {
+			return verdict.Pairs[i].A < verdict.Pairs[j].A
+		}
+		return verdict.Pairs[i].B < verdict.Pairs[j].B
+	})
+	return &verdict, engine, nil
+}
+
+// groupQuestions lists every question at least two members answered, with
+// their answers. Known questions come first in question order, followed by
+// answer keys that do not match a known question.
+func groupQuestions(questions []models.Question, members []scoring.Member) []groupAnalysisQuestion {
+	known := make(map[string]bool, len(questions)*2)
+	result := []groupAnalysisQuestion{}
+	add := func(question groupAnalysisQuestion) {
+		if len(question.Answers) >= 2 {
+			result = append(result, question)
+		}
+	}
+
+	for _, question := range questions {
+		known[question.QuestionText] = true
+		known[strconv.FormatUint(uint64(question.ID), 10)] = true
+
+		item := groupAnalysisQuestion{
+			ID:                   question.ID,
+			Question:             question.QuestionText,
+			analysisQuestionType: newAnalysisQuestionType(question),
+		}
+		for _, member := range members {
+			if answer, ok := member.Answers.Lookup(question); ok {
+				item.Answers = append(item.Answers, groupAnalysisAnswer{ParticipantID: member.ID, Answer: answer})
+			}
+		}
+		add(item)
+	}
+
+	// Answers to questions that are no longer in the question bank
+	var unknown []string
+	seen := map[string]bool{}
+	for _, member := range members {
+		for key := range member.Answers {
+			if !known[key] && !seen[key] {
+				seen[key] = true
+				unknown = append(unknown, key)
+			}
+		}
+	}
+	sort.Strings(unknown)
+	for _, key := range unknown {
+		item := groupAnalysisQuestion{Question: key, analysisQuestionType: newAnalysisQuestionType(models.Question{})}
+		for _, member := range members {
+			if answer := member.Answers[key]; !answers.IsEmpty(answer) {
+				item.Answers = append(item.Answers, groupAnalysisAnswer{ParticipantID: member.ID, Answer: answer})
+			}
+		}
+		add(item)
+	}
+	return result
+}
+
+// groupVerdictProblems returns the schema violations of the model output for
+// a group session, plus pairs that are missing, repeated or name unknown participants.
+func groupVerdictProblems(content string, ids []uint) []string {
+	problems := groupVerdictSchema.Validate([]byte(content))
+	if len(problems) > 0 {
+		return problems
+	}
+
+	var verdict GroupVerdict
+	if err := json.Unmarshal([]byte(content), &verdict); err != nil {
+		return []string{fmt.Sprintf("$: %v", err)}
+	}
+	if strings.TrimSpace(verdict.Summary) == "" {
+		problems = append(problems, "$.summary: must not be empty")
+	}
+
+	known := make(map[uint]bool, len(ids))
+	for _, id := range ids {
+		known[id] = true
+	}
+	seen := map[[2]uint]bool{}
+	for i, pair := range verdict.Pairs {
+		key := [2]uint{pair.A, pair.B}
+		if pair.A > pair.B {
+			key = [2]uint{pair.B, pair.A}
+		}
+		switch {
+		case !known[pair.A] || !known[pair.B]:
+			problems = append(problems, fmt.Sprintf("$.pairs[%d]: unknown participant id", i))
+		case pair.A == pair.B:
+			problems = append(problems, fmt.Sprintf("$.pairs[%d]: a and b must be different participants", i))
+		case seen[key]:
+			problems = append(problems, fmt.Sprintf("$.pairs[%d]: pair (%d, %d) is repeated", i, key[0], key[1]))
+		}
+		seen[key] = true
+	}
+	for i := range ids {
+		for j := i + 1; j < len(ids); j++ {
+			key := [2]uint{ids[i], ids[j]}
+			if ids[i] > ids[j] {
+				key = [2]uint{ids[j], ids[i]}
+			}
+			if !seen[key] {
+				problems = append(problems, fmt.Sprintf("$.pairs: missing pair (%d, %d)", key[0], key[1]))
+			}
+		}
+	}
+	return problems
+}
@@ -2,435 +2,588 @@
 package handlers
 
 import (
-	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
 	"net/http"
-	"os"
+	"strconv"
 
-	"openai-api/pkg/database"
+	"openai-api/pkg/answers"
+	"openai-api/pkg/apierror"
 	"openai-api/pkg/models"
-	"openai-api/pkg/openai"
 
 	"github.com/gorilla/mux"
-	uuid "github.com/satori/go.uuid"
 	"gorm.io/gorm"
 )
 
 // SubmitUserARequest represents the request body for submitting User A's answers.
 type SubmitUserARequest struct {
-	Answers      map[string]string `json:"answers"`
-	ShareAnswers bool              `json:"shareAnswers"`
+	Answers            answers.Answers `json:"answers"`
+	ShareAnswers       bool            `json:"shareAnswers"`
+	Questionnaire      string          `json:"questionnaire"`      // Slug of the questionnaire, empty for the default one
+	QuestionSetVersion int             `json:"questionSetVersion"` // Zero for the latest question set
 }
 
 // SubmitUserAResponse represents the response body for submitting User A's answers.
+// InviteToken lets User B answer; ResultsToken lets User A see the results.
 type SubmitUserAResponse struct {
-	Token string `json:"token"`
+	InviteToken  string `json:"inviteToken"`
+	ResultsToken string `json:"resultsToken"`
 }
 
 // SubmitUserBRequest represents the request body for submitting User B's answers.
+// Token is the invitation token, or User B's results token to revise the answers.
 type SubmitUserBRequest struct {
-	Token        string            `json:"token"`
-	Answers      map[string]string `json:"answers"`
-	ShareAnswers bool              `json:"shareAnswers"`
+	Token        string          `json:"token"`
+	Answers      answers.Answers `json:"answers"`
+	ShareAnswers bool            `json:"shareAnswers"`
 }
 
 // SubmitUserBResponse represents the response body for submitting User B's answers.
 type SubmitUserBResponse struct {
-	Success bool `json:"success"`
+	Success  bool   `json:"success"`
+	Status   string `json:"status"`
+	Revision int    `json:"revision"` // 0 for the first submission, then the number of the revision
+
+	// ResultsToken lets User B see the results. It is only returned for the
+	// first submission.
+	ResultsToken string `json:"resultsToken,omitempty"`
 }
 
 // ResultsResponse represents the response body for getting results.
 type ResultsResponse struct {
-	Compatibility int               `json:"compatibility"`
-	Summary       string            `json:"summary"`
-	UserAShared   bool              `json:"userAShared"`
-	UserBShared   bool              `json:"userBShared"`
-	UserAAnswers  map[string]string `json:"userAAnswers"`
-	UserBAnswers  map[string]string `json:"userBAnswers"`
+	Status        string              `json:"status"`
+	Compatibility int                 `json:"compatibility"`
+	Summary       string              `json:"summary"`
+	UserAShared   bool                `json:"userAShared"`
+	UserBShared   bool                `json:"userBShared"`
+	UserAAnswers  answers.Answers     `json:"userAAnswers"`
+	UserBAnswers  answers.Answers     `json:"userBAnswers"`
+	Error         string              `json:"error,omitempty"`
+	Engine        string              `json:"engine,omitempty"`
+	Breakdown     []QuestionBreakdown `json:"breakdown"`
+}
+
+// AnalysisStatusResponse is returned by GetResults while the analysis is still in progress.
+type AnalysisStatusResponse struct {
+	Status string `json:"status"`
 }
 
-// OpenAIResponse represents the expected response structure from OpenAI.
+// OpenAIResponse represents the expected response structure from the LLM.
+// Its JSON schema is verdictSchema.
 type OpenAIResponse struct {
-	Compatibility int    `json:"compatibility"`
-	Summary       string `json:"summary"`
+	Compatibility int                 `json:"compatibility"`
+	Summary       string              `json:"summary"`
+	Breakdown     []QuestionBreakdown `json:"breakdown"`
+}
+
+// QuestionBreakdown is the verdict on a single question.
+// QuestionID is zero for answers that do not match a known question.
+type QuestionBreakdown struct {
+	QuestionID uint   `json:"questionId"`
+	Question   string `json:"question"`
+	Score      int    `json:"score"`
+	Note       string `json:"note"`
 }
 
 // QuestionUploadRequest represents the request body for uploading questions.
 type QuestionUploadRequest []QuestionItem
 
+// QuestionUploadResponse represents the response body for uploading questions.
+// Version is the question set version the questions were published as.
+type QuestionUploadResponse struct {
+	Message string `json:"message"`
+	Version int    `json:"version,omitempty"`
+}
+
 // QuestionItem represents a single question item in the upload request.
+// Type defaults to single choice if IsMultipleChoice is set and free text otherwise.
 type QuestionItem struct {
 	ID               int      `json:"id"`
 	QuestionText     string   `json:"question"`
+	Type             string   `json:"type"`
+	Required         bool     `json:"required"`
 	IsMultipleChoice bool     `json:"isMultipleChoice"`
 	Options          []string `json:"options"`
+	Min              *float64 `json:"min"`
+	Max              *float64 `json:"max"`
 }
 
 // QuestionResponse represents a question in the response.
+// IsMultipleChoice is set for single choice questions, for older clients.
 type QuestionResponse struct {
 	ID               uint     `json:"id"`
 	QuestionText     string   `json:"question"`
+	Type             string   `json:"type"`
+	Required         bool     `json:"required"`
 	IsMultipleChoice bool     `json:"isMultipleChoice"`
 	Options          []string `json:"options"`
+	Min              *float64 `json:"min,omitempty"`
+	Max              *float64 `json:"max,omitempty"`
 }
 
 // SubmitUserA handles the POST /api/submit-user-a endpoint.
-func SubmitUserA(w http.ResponseWriter, r *http.Request) {
+func (a *App) SubmitUserA(w http.ResponseWriter, r *http.Request) {
 	// Parse request body
 	var req SubmitUserARequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	if !a.decodeRequest(w, r, &req) {
 		return
 	}
 
-	// Generate a unique token (in a real application, you might want to use a more robust method)
-	token := generateToken()
-
-	// Convert answers to JSON string for storage
-	answersJSON, err := json.Marshal(req.Answers)
-	if err != nil {
-		http.Error(w, "Failed to process answers", http.StatusInternalServerError)
+	questionnaire, questionSetID, ok := a.resolveQuestionSet(w, req.Questionnaire, req.QuestionSetVersion)
+	if !ok {
 		return
 	}
 
-	// Create UserA record
-	userA := models.UserA{
-		Token:        token,
-		Answers:      string(answersJSON),
-		ShareAnswers: req.ShareAnswers,
+	session := models.Session{
+		Kind:            models.SessionKindPair,
+		QuestionnaireID: &questionnaire.ID,
+		QuestionSetID:   questionSetID,
 	}
-
-	// Save to database
-	if err := database.DB.Create(&userA).Error; err != nil {
-		http.Error(w, "Failed to save user A data", http.StatusInternalServerError)
+	checked, ok := a.checkAnswers(w, session, req.Answers)
+	if !ok {
 		return
 	}
 
-	// Create session record
-	session := models.Session{
-		Token:   token,
-		UserAID: userA.ID,
-	}
-	if err := database.DB.Create(&session).Error; err != nil {
-		http.Error(w, "Failed to create session", http.StatusInternalServerError)
+	// Create the session, User A's participant record and the tokens
+	var response SubmitUserAResponse
+	err := a.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&session).Error; err != nil {
+			return err
+		}
+		userA, err := newParticipant(session.ID, models.ParticipantRoleInitiator, "", checked, req.ShareAnswers)
+		if err != nil {
+			return err
+		}
+		if err := tx.Create(&userA).Error; err != nil {
+			return err
+		}
+		if response.InviteToken, err = a.newSessionToken(tx, session.ID, nil, models.TokenPurposeInvite); err != nil {
+			return err
+		}
+		response.ResultsToken, err = a.newSessionToken(tx, session.ID, &userA.ID, models.TokenPurposeResults)
+		return err
+	})
+	if err != nil {
+		apierror.Write(w, http.StatusInternalServerError, apierror.CodeInternal, "Failed to save user A data")
 		return
 	}
 
 	// Return response
-	response := SubmitUserAResponse{
-		Token: token,
-	}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
 
 // SubmitUserB handles the POST /api/submit-user-b endpoint.
-func SubmitUserB(w http.ResponseWriter, r *http.Request) {
+func (a *App) SubmitUserB(w http.ResponseWriter, r *http.Request) {
 	// Parse request body
 	var req SubmitUserBRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	if !a.decodeRequest(w, r, &req) {
 		return
 	}
 
-	// Find session by token
-	var session models.Session
-	if err := database.DB.Preload("UserA").Where("token = ?", req.Token).First(&session).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			http.Error(w, "Invalid token", http.StatusNotFound)
-			return
-		}
-		http.Error(w, "Failed to find session", http.StatusInternalServerError)
+	// Find session by token. Invitations, and legacy tokens like them, submit
+	// the answers the first time; only the results token issued to User B
+	// revises them, since legacy tokens do not tell who holds them.
+	session, token, ok := a.findSessionToken(w, req.Token, models.TokenPurposeInvite, models.TokenPurposeLegacy, models.TokenPurposeResults)
+	if !ok {
 		return
 	}
-
-	// Convert answers to JSON string for storage
-	answersJSON, err := json.Marshal(req.Answers)
-	if err != nil {
-		http.Error(w, "Failed to process answers", http.StatusInternalServerError)
+	if session.Kind == models.SessionKindGroup {
+		apierror.Write(w, http.StatusBadRequest, apierror.CodeWrongSessionKind, "Token belongs to a group session")
 		return
 	}
-
-	// Create UserB record
-	userB := models.UserB{
-		Token:        req.Token,
-		Answers:      string(answersJSON),
-		ShareAnswers: req.ShareAnswers,
+	if session.Status == models.SessionStatusErased {
+		apierror.Write(w, http.StatusGone, apierror.CodeDataErased, "A participant has erased their answers")
+		return
+	}
+	if token.Purpose == models.TokenPurposeResults {
+		var count int64
+		if token.ParticipantID != nil {
+			err := a.DB.Model(&models.Participant{}).Where("id = ? AND session_id = ? AND role = ?", *token.ParticipantID, session.ID, models.ParticipantRoleMember).Count(&count).Error
+			if err != nil {
+				apierror.Write(w, http.StatusInternalServerError, apierror.CodeInternal, "Failed to find session")
+				return
+			}
+		}
+		if count == 0 {
+			apierror.Write(w, http.StatusForbidden, apierror.CodeWrongTokenPurpose, "Only User B's results token can revise the answers")
+			return
+		}
 	}
 
-	// Save to database
-	if err := database.DB.Create(&userB).Error; err != nil {
-		http.Error(w, "Failed to save user B data", http.StatusInternalServerError)
+	if session.Status != "" && (a.Config.MaxAnswerRevisions == 0 || token.Purpose != models.TokenPurposeResults) {
+		apierror.Write(w, http.StatusConflict, apierror.CodeAlreadySubmitted, "User B has already submitted answers")
 		return
 	}
 
-	// Update session with UserB reference
-	session.UserBID = &userB.ID
-	if err := database.DB.Save(&session).Error; err != nil {
-		http.Error(w, "Failed to update session", http.StatusInternalServerError)
+	checked, ok := a.checkAnswers(w, session, req.Answers)
+	if !ok {
 		return
 	}
 
-	// Generate compatibility score and summary using OpenAI
-	compatibility, summary, err := generateCompatibilityScore(session.UserA, userB)
+	// Save User B's answers and queue the analysis, unless B has submitted
+	// before and may not revise the answers
+	userB, err := newParticipant(session.ID, models.ParticipantRoleMember, "", checked, req.ShareAnswers)
 	if err != nil {
-		log.Printf("Failed to generate compatibility score: %v", err)
-		// Continue without AI-generated content
-		compatibility = 85           // Default value
-		summary = "你们的答案很相似，有很好的默契！" // Default summary in Chinese
+		apierror.Write(w, http.StatusInternalServerError, apierror.CodeInternal, "Failed to process answers")
+		return
 	}
-
-	// Update session with compatibility score and summary
-	session.Compatibility = compatibility
-	session.Summary = summary
-	if err := database.DB.Save(&session).Error; err != nil {
-		log.Printf("Failed to save compatibility score: %v", err)
-		// Continue without saving AI-generated content
+	var resultsToken string
+	err = a.DB.Transaction(func(tx *gorm.DB) error {
+		if token.Purpose == models.TokenPurposeResults {
+			return a.reviseUserB(tx, session.ID, *token.ParticipantID, &userB)
+		}
+		submitted, err := transitionSession(tx, session.ID, models.SessionStatusPending, "")
+		if err != nil {
+			return err
+		}
+		if !submitted {
+			return errAlreadySubmitted
+		}
+		if err := tx.Create(&userB).Error; err != nil {
+			return err
+		}
+		resultsToken, err = a.newSessionToken(tx, session.ID, &userB.ID, models.TokenPurposeResults)
+		return err
+	})
+	switch {
+	case errors.Is(err, errAlreadySubmitted):
+		apierror.Write(w, http.StatusConflict, apierror.CodeAlreadySubmitted, "User B has already submitted answers")
+		return
+	case errors.Is(err, errAnalysisRunning):
+		apierror.Write(w, http.StatusConflict, apierror.CodeAnalysisRunning, "Answers can be revised once the analysis has finished")
+		return
+	case err != nil:
+		apierror.Write(w, http.StatusInternalServerError, apierror.CodeInternal, "Failed to save user B data")
+		return
 	}
+	a.jobs.Enqueue(session.ID)
 
 	// Return response
 	response := SubmitUserBResponse{
-		Success: true,
+		Success:  true,
+		Status:   models.SessionStatusPending,
+		Revision: userB.Revisions,
+
+		ResultsToken: resultsToken,
 	}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
 
-// GetResults handles the GET /api/results/{token} endpoint.
-func GetResults(w http.ResponseWriter, r *http.Request) {
-	// Get token from URL parameters
-	vars := mux.Vars(r)
-	token := vars["token"]
+// Errors returned by reviseUserB.
+var (
+	errAlreadySubmitted = errors.New("user B has already submitted answers")
+	errAnalysisRunning  = errors.New("analysis is running")
+)
+
+// reviseUserB replaces the answers of User B, the participant userBID who has
+// submitted before, with those of revision and requeues the analysis, if
+// Config.MaxAnswerRevisions allows another revision. It sets
+// revision.Revisions to the number of the revision. It returns
+// errAlreadySubmitted if no revision is allowed, and errAnalysisRunning while
+// the analysis runs.
+func (a *App) reviseUserB(tx *gorm.DB, sessionID, userBID uint, revision *models.Participant) error {
+	var userB models.Participant
+	err := tx.Where("session_id = ? AND role = ?", sessionID, models.ParticipantRoleMember).First(&userB, userBID).Error
+	if err != nil {
+		return err
+	}
+	if userB.Revisions >= a.Config.MaxAnswerRevisions {
+		return errAlreadySubmitted
+	}
+	requeued, err := transitionSession(tx, sessionID, models.SessionStatusPending,
+		models.SessionStatusPending, models.SessionStatusDone, models.SessionStatusFailed)
+	if err != nil {
+		return err
+	}
+	if !requeued {
+		return errAnalysisRunning
+	}
+	revision.Revisions = userB.Revisions + 1
+	return tx.Model(&userB).Select("answers", "share_answers", "revisions").Updates(revision).Error
+}
 
+// GetResults handles the GET /api/results/{token} endpoint.
+func (a *App) GetResults(w http.ResponseWriter, r *http.Request) {
 	// Find session by token
-	var session models.Session
-	if err := database.DB.Preload("UserA").Preload("UserB").Where("token = ?", token).First(&session).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			http.Error(w, "Invalid token", http.StatusNotFound)
-			return
-		}
-		http.Error(w, "Failed to find session", http.StatusInternalServerError)
+	session, ok := a.findPairSession(w, mux.Vars(r)["token"])
+	if !ok {
 		return
 	}
-
-	// Check if UserB has submitted answers
-	if session.UserB == nil {
-		http.Error(w, "User B has not submitted answers yet", http.StatusNotFound)
+	if err := a.DB.Preload("Participants", orderByID).Preload("QuestionScores", orderByID).First(&session, session.ID).Error; err != nil {
+		apierror.Write(w, http.StatusInternalServerError, apierror.CodeInternal, "Failed to find session")
 		return
 	}
 
-	// Parse UserA answers
-	var userAAnswers map[string]string
-	if err := json.Unmarshal([]byte(session.UserA.Answers), &userAAnswers); err != nil {
-		http.Error(w, "Failed to parse User A answers", http.StatusInternalServerError)
+	// Check if UserB has submitted answers, unless User A has erased theirs before
+	if _, userB := pairParticipants(session); userB == nil && session.Status != models.SessionStatusErased {
+		apierror.Write(w, http.StatusNotFound, apierror.CodeAwaitingPartner, "User B has not submitted answers yet")
 		return
 	}
 
-	// Parse UserB answers
-	var userBAnswers map[string]string
-	if err := json.Unmarshal([]byte(session.UserB.Answers), &userBAnswers); err != nil {
-		http.Error(w, "Failed to parse User B answers", http.StatusInternalServerError)
+	// Report progress while the analysis is still queued or running
+	if session.Status == models.SessionStatusPending || session.Status == models.SessionStatusRunning {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(AnalysisStatusResponse{Status: session.Status})
 		return
 	}
 
 	// Return response
-	response := ResultsResponse{
-		Compatibility: session.Compatibility,
-		Summary:       session.Summary,
-		UserAShared:   session.UserA.ShareAnswers,
-		UserBShared:   session.UserB.ShareAnswers,
-		UserAAnswers:  userAAnswers,
-		UserBAnswers:  userBAnswers,
-	}
-	if !response.UserAShared {
-		response.UserAAnswers = nil
-	}
-	if !response.UserBShared {
-		response.UserBAnswers = nil
+	response, err := buildResultsResponse(session)
+	if err != nil {
+		a.Logger.Printf("Failed to build results for session %d: %v", session.ID, err)
+		apierror.Write(w, http.StatusInternalServerError, apierror.CodeInternal, "Failed to parse answers")
+		return
 	}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
 
-// generateToken generates a simple token for identifying sessions.
-func generateToken() string {
-	// In a real application, you would use a more secure method
-	// This is just a simple example
-	return uuid.NewV4().String()
-}
-
-// generateCompatibilityScore generates a compatibility score and summary using OpenAI.
-func generateCompatibilityScore(userA models.UserA, userB models.UserB) (int, string, error) {
-	// Parse UserA answers
-	var userAAnswers map[string]string
-	if err := json.Unmarshal([]byte(userA.Answers), &userAAnswers); err != nil {
-		return 0, "", err
-	}
-
-	// Parse UserB answers
-	var userBAnswers map[string]string
-	if err := json.Unmarshal([]byte(userB.Answers), &userBAnswers); err != nil {
-		return 0, "", err
-	}
-
-	// Create a map with both users' answers
-	answers := map[string]map[string]string{
-		"userA": userAAnswers,
-		"userB": userBAnswers,
+// buildResultsResponse assembles the results of a completed pair session,
+// hiding the answers of users who chose not to share them. User B is missing
+// from sessions User A erased before B answered.
+func buildResultsResponse(session models.Session) (ResultsResponse, error) {
+	userA, userB := pairParticipants(session)
+	if userA == nil || (userB == nil && session.Status != models.SessionStatusErased) {
+		return ResultsResponse{}, fmt.Errorf("session %d is missing a participant", session.ID)
 	}
-
-	// Convert answers to JSON string
-	answersJSON, err := json.Marshal(answers)
-	if err != nil {
-		return 0, "", err
-	}
-
-	// Get system prompt from environment variable
-	systemPrompt := os.Getenv("SYSTEM_PROMPT")
-	if systemPrompt == "" {
-		systemPrompt = openai.SystemPrompt
+	if userB == nil {
+		userB = &models.Participant{}
 	}
 
-	// Create OpenAI client
-	config := openai.NewConfig().
-		WithAPIKey(os.Getenv("OPENAI_API_KEY")).
-		WithAPIBase(os.Getenv("OPENAI_API_BASE")).
-		WithSystemPrompt(systemPrompt)
-
-	client := openai.NewClient(config)
-
-	llmModel := os.Getenv("MODELS")
-
-	if llmModel == "" {
-		llmModel = "gpt-3.5-turbo"
+	response := ResultsResponse{
+		Status:        session.Status,
+		Compatibility: session.Compatibility,
+		Summary:       session.Summary,
+		Error:         session.ErrorCode,
+		Engine:        session.Engine,
+		UserAShared:   userA.ShareAnswers,
+		UserBShared:   userB.ShareAnswers,
+		Breakdown:     []QuestionBreakdown{},
+	}
+	for _, score := range session.QuestionScores {
+		response.Breakdown = append(response.Breakdown, QuestionBreakdown{
+			QuestionID: score.QuestionID,
+			Question:   score.Question,
+			Score:      score.Score,
+			Note:       score.Note,
+		})
+	}
+
+	// Only parse the answers users chose to share
+	var err error
+	if userA.ShareAnswers {
+		if response.UserAAnswers, err = answers.Parse(userA.Answers); err != nil {
+			return ResultsResponse{}, fmt.Errorf("failed to parse User A answers: %w", err)
+		}
 	}
-	// Prepare the request
-	request := &openai.ChatCompletionRequest{
-		Model: llmModel, // Default model, can be overridden
-		Messages: []openai.Message{
-			{
-				Role:    "user",
-				Content: string(answersJSON),
-			},
-		},
-		Temperature: 0.7,
-		Stream:      false,
-		MaxTokens:   4096,
+	if userB.ShareAnswers {
+		if response.UserBAnswers, err = answers.Parse(userB.Answers); err != nil {
+			return ResultsResponse{}, fmt.Errorf("failed to parse User B answers: %w", err)
+		}
 	}
+	return response, nil
+}
 
-	// Send request to OpenAI
-	ctx := context.Background()
-	response, err := client.ChatCompletion(ctx, request)
+// newParticipant builds the participant record for submitted answers.
+func newParticipant(sessionID uint, role, name string, submitted answers.Answers, shareAnswers bool) (models.Participant, error) {
+	// Convert answers to JSON string for storage
+	answersJSON, err := json.Marshal(submitted)
 	if err != nil {
-		return 0, "", fmt.Errorf("failed to get response from OpenAI: %w", err)
+		return models.Participant{}, err
 	}
+	return models.Participant{
+		SessionID:    sessionID,
+		Role:         role,
+		Name:         name,
+		Answers:      string(answersJSON),
+		ShareAnswers: shareAnswers,
+	}, nil
+}
 
-	// fmt.Printf("OpenAI response: %v\n", response)
-
-	// Check if we have a response
-	if len(response.Choices) == 0 {
-		return 0, "", fmt.Errorf("no choices in OpenAI response")
+// pairParticipants returns User A and User B of a pair session with its
+// participants loaded. userB is nil until User B has submitted.
+func pairParticipants(session models.Session) (userA, userB *models.Participant) {
+	for i := range session.Participants {
+		participant := &session.Participants[i]
+		switch {
+		case participant.Role == models.ParticipantRoleInitiator && userA == nil:
+			userA = participant
+		case participant.Role == models.ParticipantRoleMember && userB == nil:
+			userB = participant
+		}
 	}
+	return userA, userB
+}
 
-	// Extract the content from the response
-	content := response.Choices[0].Message.Content
-
-	fmt.Printf("content: %v\n", content)
-
-	// Try to parse the response as JSON
-	var openAIResponse OpenAIResponse
-	if err := json.Unmarshal([]byte(content), &openAIResponse); err != nil {
-		fmt.Printf("Failed to parse OpenAI response as JSON: %v\n", err)
-		// If JSON parsing fails, use the content as the summary and set a default compatibility
-		return 85, content, nil
-	}
+// orderByID preloads associations in the order they were created.
+func orderByID(db *gorm.DB) *gorm.DB {
+	return db.Order("id")
+}
 
-	// Validate compatibility score
-	if openAIResponse.Compatibility < 0 || openAIResponse.Compatibility > 100 {
-		// If the compatibility score is out of range, use a default value
-		openAIResponse.Compatibility = 85
+// findPairSession finds the pair session a results token belongs to, writing
+// the error response if there is none.
+func (a *App) findPairSession(w http.ResponseWriter, token string) (models.Session, bool) {
+	session, _, ok := a.findSessionToken(w, token, models.TokenPurposeResults, models.TokenPurposeLegacy)
+	if ok && session.Kind != models.SessionKindPair {
+		apierror.Write(w, http.StatusNotFound, apierror.CodeSessionNotFound, "Invalid token")
+		return session, false
 	}
-
-	return openAIResponse.Compatibility, openAIResponse.Summary, nil
+	return session, ok
 }
 
 // UploadQuestions handles the POST /api/questions/upload endpoint.
-func UploadQuestions(w http.ResponseWriter, r *http.Request) {
-	// Parse request body
-	var req QuestionUploadRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		fmt.Printf("Error decoding request body: %v\n", err)
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+// It replaces the whole question bank of the questionnaire given by the
+// questionnaire query parameter, or the default questionnaire, and publishes
+// it as a new question set. Questions whose ID is already in the bank are
+// updated in place; other IDs are ignored and the question gets a new one.
+func (a *App) UploadQuestions(w http.ResponseWriter, r *http.Request) {
+	questionnaire, ok := a.requestQuestionnaire(w, r.URL.Query().Get("questionnaire"))
+	if !ok {
 		return
 	}
 
-	// Delete all existing questions (delete-then-add approach)
-	if err := database.DB.Where("1 = 1").Delete(&models.Question{}).Error; err != nil {
-		http.Error(w, "Failed to delete existing questions", http.StatusInternalServerError)
+	// Parse request body
+	var req QuestionUploadRequest
+	if !a.decodeRequest(w, r, &req) {
 		return
 	}
 
 	// Convert request items to database models
 	var questions []models.Question
-	for _, item := range req {
-		// Convert options array to JSON string
-		optionsJSON, err := json.Marshal(item.Options)
-		if err != nil {
-			http.Error(w, "Failed to process question options", http.StatusInternalServerError)
+	for i, item := range req {
+		question, err := newQuestion(item)
+		var invalid *questionError
+		if errors.As(err, &invalid) {
+			writeValidationErrors(w, fmt.Sprintf("Question %d is invalid", i+1), invalid.fieldError(fmt.Sprintf("[%d].", i)))
 			return
 		}
-
-		question := models.Question{
-			ID:               uint(item.ID),
-			QuestionText:     item.QuestionText,
-			IsMultipleChoice: item.IsMultipleChoice,
-			Options:          string(optionsJSON),
+		if err != nil {
+			apierror.Write(w, http.StatusInternalServerError, apierror.CodeInternal, "Failed to process question options")
+			return
 		}
+		question.ID = uint(item.ID)
+		question.QuestionnaireID = questionnaire.ID
+		question.Position = i + 1
 		questions = append(questions, question)
 	}
 
-	// Save new questions to database
-	if len(questions) > 0 {
-		if err := database.DB.Create(&questions).Error; err != nil {
-			http.Error(w, "Failed to save questions", http.StatusInternalServerError)
-			return
+	var set *models.QuestionSet
+	err := a.DB.Transaction(func(tx *gorm.DB) error {
+		var existing []uint
+		if err := tx.Model(&models.Question{}).Where("questionnaire_id = ?", questionnaire.ID).Pluck("id", &existing).Error; err != nil {
+			return fmt.Errorf("failed to load existing questions: %w", err)
+		}
+		remaining := make(map[uint]bool, len(existing))
+		for _, id := range existing {
+			remaining[id] = true
+		}
+
+		for i := range questions {
+			question := &questions[i]
+			if question.ID != 0 && remaining[question.ID] {
+				delete(remaining, question.ID)
+				err := tx.Model(question).Select(append([]string{"Position"}, questionColumns...)).Updates(question).Error
+				if err != nil {
+					return fmt.Errorf("failed to update question %d: %w", question.ID, err)
+				}
+				continue
+			}
+			question.ID = 0
+			if err := tx.Create(question).Error; err != nil {
+				return fmt.Errorf("failed to save questions: %w", err)
+			}
 		}
+
+		// Delete the questions left out for good. Published question sets
+		// keep their own copies.
+		if len(remaining) > 0 {
+			ids := make([]uint, 0, len(remaining))
+			for id := range remaining {
+				ids = append(ids, id)
+			}
+			if err := tx.Unscoped().Where("id IN ?", ids).Delete(&models.Question{}).Error; err != nil {
+				return fmt.Errorf("failed to delete existing questions: %w", err)
+			}
+		}
+		if len(questions) == 0 {
+			return nil
+		}
+		var err error
+		set, _, err = a.publishQuestionSet(tx, questionnaire.ID, adminName(r))
+		return err
+	})
+	if err != nil {
+		a.Logger.Printf("Error uploading questions: %v", err)
+		apierror.Write(w, http.StatusInternalServerError, apierror.CodeInternal, "Failed to save questions")
+		return
 	}
 
 	// Return success response
+	response := QuestionUploadResponse{Message: "Questions uploaded successfully"}
+	if set != nil {
+		response.Version = set.Version
+	}
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]string{"message": "Questions uploaded successfully"})
+	json.NewEncoder(w).Encode(response)
 }
 
-// GetQuestions handles the GET /api/questions endpoint.
-func GetQuestions(w http.ResponseWriter, r *http.Request) {
-	// Get all questions from database
-	var dbQuestions []models.Question
-	if err := database.DB.Find(&dbQuestions).Error; err != nil {
-		http.Error(w, "Failed to retrieve questions", http.StatusInternalServerError)
+// GetQuestions handles the GET /api/questions endpoint. It returns the
+// questions of the version the session of the invitation or results token in
+// the token query parameter was answered against, or else of the
+// questionnaire given by the questionnaire query parameter (default: the
+// default questionnaire), in the version given by the version query parameter
+// (default: the latest). The version is returned in the X-Question-Set-Version
+// header.
+func (a *App) GetQuestions(w http.ResponseWriter, r *http.Request) {
+	var set *models.QuestionSet
+	var err error
+	if token := r.URL.Query().Get("token"); token != "" {
+		session, _, ok := a.findSessionToken(w, token, models.TokenPurposeInvite, models.TokenPurposeResults, models.TokenPurposeLegacy)
+		if !ok {
+			return
+		}
+		if session.QuestionSetID != nil {
+			set = &models.QuestionSet{}
+			err = a.DB.Preload("Questions", orderByPosition).First(set, *session.QuestionSetID).Error
+		} else if session.QuestionnaireID != nil {
+			set, err = a.findQuestionSet(*session.QuestionnaireID, 0)
+		}
+	} else {
+		questionnaire, ok := a.requestQuestionnaire(w, r.URL.Query().Get("questionnaire"))
+		if !ok {
+			return
+		}
+		version := 0
+		if rawVersion := r.URL.Query().Get("version"); rawVersion != "" {
+			version, err = strconv.Atoi(rawVersion)
+			if err != nil || version < 1 {
+				apierror.Write(w, http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid question set version")
+				return
+			}
+		}
+		set, err = a.findQuestionSet(questionnaire.ID, version)
+	}
+	if errors.Is(err, errUnknownQuestionSet) {
+		apierror.Write(w, http.StatusNotFound, apierror.CodeQuestionSetNotFound, "Question set not found")
+		return
+	}
+	if err != nil {
+		apierror.Write(w, http.StatusInternalServerError, apierror.CodeInternal, "Failed to retrieve questions")
 		return
 	}
 
-	// Convert database models to response format
-	var questions []QuestionResponse
-	for _, dbQuestion := range dbQuestions {
-		// Parse options JSON string
-		var options []string
-		if err := json.Unmarshal([]byte(dbQuestion.Options), &options); err != nil {
-			// If parsing fails, use empty array
-			options = []string{}
-		}
-
-		question := QuestionResponse{
-			ID:               dbQuestion.ID,
-			QuestionText:     dbQuestion.QuestionText,
-			IsMultipleChoice: dbQuestion.IsMultipleChoice,
-			Options:          options,
+	// Convert the published questions to response format
+	questions := []QuestionResponse{}
+	if set != nil {
+		for _, question := range questionSetQuestions(set.Questions) {
+			questions = append(questions, questionResponse(question))
 		}
-		questions = append(questions, question)
+		w.Header().Set(questionSetVersionHeader, strconv.Itoa(set.Version))
 	}
 
 	// Return response
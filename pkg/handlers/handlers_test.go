@@ -0,0 +1,349 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"openai-api/pkg/apierror"
+	"openai-api/pkg/config"
+	"openai-api/pkg/database"
+	"openai-api/pkg/encryption"
+	"openai-api/pkg/jobs"
+	"openai-api/pkg/llm"
+	"openai-api/pkg/models"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm/logger"
+)
+
+// The tests serve an App backed by an in-memory SQLite database, with the
+// analyses run by a stub LLM.
+
+// stubVerdict is the analysis the stub LLM returns for the test questions.
+const stubVerdict = `{"summary":"很合拍","compatibility":80,"breakdown":[` +
+	`{"questionId":1,"question":"颜色","score":90,"note":"相同"},` +
+	`{"questionId":2,"question":"爱好","score":70,"note":"相近"}]}`
+
+// stubProvider is an llm.Provider that returns content, or streams partial
+// and fails with err if err is set.
+type stubProvider struct {
+	content string
+	partial string
+	err     error
+}
+
+func (p *stubProvider) Name() string { return "stub" }
+
+func (p *stubProvider) Complete(ctx context.Context, request *llm.Request) (*llm.Response, error) {
+	return p.Stream(ctx, request, nil)
+}
+
+func (p *stubProvider) Stream(ctx context.Context, request *llm.Request, onDelta func(string)) (*llm.Response, error) {
+	if p.err != nil {
+		if onDelta != nil && p.partial != "" {
+			onDelta(p.partial)
+		}
+		return nil, p.err
+	}
+	if onDelta != nil {
+		onDelta(p.content)
+	}
+	return &llm.Response{Content: p.content, Model: "stub"}, nil
+}
+
+// testServer is an App together with the router serving it.
+type testServer struct {
+	app    *App
+	router *mux.Router
+}
+
+// newTestServer creates an App on a fresh in-memory database holding two
+// questions in the default questionnaire, analysing with provider.
+func newTestServer(t *testing.T, provider llm.Provider) *testServer {
+	t.Helper()
+	db, err := database.Connect("sqlite::memory:", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Logger = logger.Discard
+	// Every connection to :memory: opens a database of its own
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	var questionnaire models.Questionnaire
+	if err := db.Where("slug = ?", "default").First(&questionnaire).Error; err != nil {
+		t.Fatal(err)
+	}
+	questions := []models.Question{
+		{ID: 1, QuestionnaireID: questionnaire.ID, QuestionText: "颜色", Type: models.QuestionTypeSingleChoice, IsMultipleChoice: true, Options: `["红","蓝"]`, Required: true, Position: 1},
+		{ID: 2, QuestionnaireID: questionnaire.ID, QuestionText: "爱好", Type: models.QuestionTypeText, Position: 2},
+	}
+	if err := db.Create(&questions).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	keyring, err := encryption.NewKeyring(config.Encryption{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	settings := DefaultConfig()
+	settings.ScorerFallback = false
+	app := New(encryption.Attach(db, keyring), provider, settings, log.New(io.Discard, "", 0))
+
+	r := mux.NewRouter()
+	r.HandleFunc("/api/submit-user-a", app.Idempotent(app.SubmitUserA)).Methods("POST")
+	r.HandleFunc("/api/submit-user-b", app.Idempotent(app.SubmitUserB)).Methods("POST")
+	r.HandleFunc("/api/results/{token}", app.GetResults).Methods("GET")
+	r.HandleFunc("/api/my-data/{token}", app.ExportData).Methods("GET")
+	return &testServer{app: app, router: r}
+}
+
+// do serves a request and decodes the JSON response into out, if it is not nil.
+func (s *testServer) do(t *testing.T, method, path, body string, out interface{}) int {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	s.router.ServeHTTP(rec, req)
+	if out != nil {
+		if err := json.Unmarshal(rec.Body.Bytes(), out); err != nil {
+			t.Fatalf("%s %s: decoding %q: %v", method, path, rec.Body.String(), err)
+		}
+	}
+	return rec.Code
+}
+
+// expectError serves a request and checks its status and error code.
+func (s *testServer) expectError(t *testing.T, method, path, body string, status int, code string) {
+	t.Helper()
+	var response apierror.Response
+	if got := s.do(t, method, path, body, &response); got != status || response.Error.Code != code {
+		t.Errorf("%s %s: got %d %q, want %d %q", method, path, got, response.Error.Code, status, code)
+	}
+}
+
+// submitPair submits the answers of both users and returns their responses.
+func (s *testServer) submitPair(t *testing.T) (SubmitUserAResponse, SubmitUserBResponse) {
+	t.Helper()
+	var a SubmitUserAResponse
+	if status := s.do(t, "POST", "/api/submit-user-a", `{"answers":{"1":"红","2":"读书"}}`, &a); status != http.StatusOK {
+		t.Fatalf("submit-user-a: got %d", status)
+	}
+	var b SubmitUserBResponse
+	body := `{"token":"` + a.InviteToken + `","answers":{"1":"红","2":"跑步"},"shareAnswers":true}`
+	if status := s.do(t, "POST", "/api/submit-user-b", body, &b); status != http.StatusOK {
+		t.Fatalf("submit-user-b: got %d", status)
+	}
+	return a, b
+}
+
+// analyse runs the queued analysis of the only session.
+func (s *testServer) analyse(t *testing.T) error {
+	t.Helper()
+	var session models.Session
+	if err := s.app.DB.First(&session).Error; err != nil {
+		t.Fatal(err)
+	}
+	return s.app.ProcessAnalysis(context.Background(), session.ID)
+}
+
+func TestPairFlow(t *testing.T) {
+	s := newTestServer(t, &stubProvider{content: stubVerdict})
+
+	var a SubmitUserAResponse
+	if status := s.do(t, "POST", "/api/submit-user-a", `{"answers":{"1":"红","2":"读书"}}`, &a); status != http.StatusOK {
+		t.Fatalf("submit-user-a: got %d", status)
+	}
+	if !strings.HasPrefix(a.InviteToken, "inv_") || !strings.HasPrefix(a.ResultsToken, "res_") {
+		t.Fatalf("submit-user-a: got tokens %q and %q", a.InviteToken, a.ResultsToken)
+	}
+	s.expectError(t, "GET", "/api/results/"+a.ResultsToken, "", http.StatusNotFound, apierror.CodeAwaitingPartner)
+	s.expectError(t, "GET", "/api/results/"+a.InviteToken, "", http.StatusForbidden, apierror.CodeWrongTokenPurpose)
+
+	var b SubmitUserBResponse
+	body := `{"token":"` + a.InviteToken + `","answers":{"1":"红","2":"跑步"},"shareAnswers":true}`
+	if status := s.do(t, "POST", "/api/submit-user-b", body, &b); status != http.StatusOK {
+		t.Fatalf("submit-user-b: got %d", status)
+	}
+	if b.Status != models.SessionStatusPending || !strings.HasPrefix(b.ResultsToken, "res_") {
+		t.Fatalf("submit-user-b: got %+v", b)
+	}
+
+	var pending AnalysisStatusResponse
+	if status := s.do(t, "GET", "/api/results/"+a.ResultsToken, "", &pending); status != http.StatusAccepted || pending.Status != models.SessionStatusPending {
+		t.Fatalf("results before the analysis: got %d %+v", status, pending)
+	}
+
+	if err := s.analyse(t); err != nil {
+		t.Fatal(err)
+	}
+	for _, token := range []string{a.ResultsToken, b.ResultsToken} {
+		var results ResultsResponse
+		if status := s.do(t, "GET", "/api/results/"+token, "", &results); status != http.StatusOK {
+			t.Fatalf("results: got %d", status)
+		}
+		if results.Status != models.SessionStatusDone || results.Compatibility != 80 || results.Summary != "很合拍" || len(results.Breakdown) != 2 {
+			t.Errorf("results: got %+v", results)
+		}
+		// Only User B shared their answers
+		if len(results.UserAAnswers) != 0 || results.UserBAnswers["2"] != "跑步" {
+			t.Errorf("results: got answers %v and %v", results.UserAAnswers, results.UserBAnswers)
+		}
+	}
+}
+
+func TestSubmitUserBConflicts(t *testing.T) {
+	s := newTestServer(t, &stubProvider{content: stubVerdict})
+	a, b := s.submitPair(t)
+
+	answers := `"answers":{"1":"蓝"}`
+	s.expectError(t, "POST", "/api/submit-user-b", `{"token":"`+a.InviteToken+`",`+answers+`}`, http.StatusConflict, apierror.CodeAlreadySubmitted)
+	// Revisions are off by default
+	s.expectError(t, "POST", "/api/submit-user-b", `{"token":"`+b.ResultsToken+`",`+answers+`}`, http.StatusConflict, apierror.CodeAlreadySubmitted)
+	s.expectError(t, "POST", "/api/submit-user-b", `{"token":"`+a.ResultsToken+`",`+answers+`}`, http.StatusForbidden, apierror.CodeWrongTokenPurpose)
+
+	s.app.Config.MaxAnswerRevisions = 1
+	if err := s.analyse(t); err != nil {
+		t.Fatal(err)
+	}
+	var revised SubmitUserBResponse
+	if status := s.do(t, "POST", "/api/submit-user-b", `{"token":"`+b.ResultsToken+`",`+answers+`}`, &revised); status != http.StatusOK || revised.Revision != 1 {
+		t.Fatalf("revision: got %d %+v", status, revised)
+	}
+	s.expectError(t, "POST", "/api/submit-user-b", `{"token":"`+b.ResultsToken+`",`+answers+`}`, http.StatusConflict, apierror.CodeAlreadySubmitted)
+
+	s.app.Config.MaxAnswerRevisions = 2
+	if err := s.app.DB.Model(&models.Session{}).Where("1 = 1").Update("status", models.SessionStatusRunning).Error; err != nil {
+		t.Fatal(err)
+	}
+	s.expectError(t, "POST", "/api/submit-user-b", `{"token":"`+b.ResultsToken+`",`+answers+`}`, http.StatusConflict, apierror.CodeAnalysisRunning)
+}
+
+func TestNotFound(t *testing.T) {
+	s := newTestServer(t, &stubProvider{content: stubVerdict})
+
+	s.expectError(t, "GET", "/api/results/res_unknown", "", http.StatusNotFound, apierror.CodeSessionNotFound)
+	s.expectError(t, "GET", "/api/my-data/res_unknown", "", http.StatusNotFound, apierror.CodeSessionNotFound)
+	s.expectError(t, "POST", "/api/submit-user-b", `{"token":"inv_unknown","answers":{"1":"红"}}`, http.StatusNotFound, apierror.CodeSessionNotFound)
+	s.expectError(t, "POST", "/api/submit-user-a", `{"answers":{"1":"红"},"questionnaire":"unknown"}`, http.StatusBadRequest, apierror.CodeQuestionnaireNotFound)
+}
+
+func TestInvalidAnswers(t *testing.T) {
+	s := newTestServer(t, &stubProvider{content: stubVerdict})
+
+	for _, body := range []string{
+		`{"answers":{"2":"读书"}}`,         // required question 1 unanswered
+		`{"answers":{"1":"绿"}}`,          // not an option
+		`{"answers":{"1":"红","9":"读书"}}`, // unknown question
+	} {
+		s.expectError(t, "POST", "/api/submit-user-a", body, http.StatusBadRequest, apierror.CodeValidationFailed)
+	}
+	var count int64
+	if err := s.app.DB.Model(&models.Session{}).Count(&count).Error; err != nil || count != 0 {
+		t.Errorf("got %d sessions, %v", count, err)
+	}
+}
+
+func TestAnalysisFailure(t *testing.T) {
+	s := newTestServer(t, &stubProvider{err: errors.New("model unavailable")})
+	a, _ := s.submitPair(t)
+
+	if err := s.analyse(t); err != nil {
+		t.Fatal(err)
+	}
+	var results ResultsResponse
+	if status := s.do(t, "GET", "/api/results/"+a.ResultsToken, "", &results); status != http.StatusOK {
+		t.Fatalf("results: got %d", status)
+	}
+	if results.Status != models.SessionStatusFailed || results.Error == "" {
+		t.Errorf("results: got %+v", results)
+	}
+}
+
+func TestFallbackReplacesStreamedSummary(t *testing.T) {
+	s := newTestServer(t, &stubProvider{partial: `{"summary":"很合`, err: errors.New("connection reset")})
+	s.app.Config.ScorerFallback = true
+	s.submitPair(t)
+
+	var session models.Session
+	if err := s.app.DB.First(&session).Error; err != nil {
+		t.Fatal(err)
+	}
+	_, deltas, cancel := s.app.jobs.Subscribe(session.ID)
+	defer cancel()
+	if err := s.analyse(t); err != nil {
+		t.Fatal(err)
+	}
+	var got []jobs.Delta
+	for delta := range deltas {
+		got = append(got, delta)
+	}
+	if len(got) != 2 || got[0] != (jobs.Delta{Text: "很合"}) || !got[1].Replace || !strings.Contains(got[1].Text, "本地算法") {
+		t.Errorf("got deltas %+v, want the partial summary replaced by the local one", got)
+	}
+}
+
+func TestIdempotentReplay(t *testing.T) {
+	s := newTestServer(t, &stubProvider{content: stubVerdict})
+
+	submit := func() (SubmitUserAResponse, *httptest.ResponseRecorder) {
+		req := httptest.NewRequest("POST", "/api/submit-user-a", strings.NewReader(`{"answers":{"1":"红"}}`))
+		req.Header.Set("Idempotency-Key", "5f0c8e9a-2b1d-4c3e-9f7a-6d8b0e1c2a34")
+		rec := httptest.NewRecorder()
+		s.router.ServeHTTP(rec, req)
+		var response SubmitUserAResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+			t.Fatalf("decoding %q: %v", rec.Body.String(), err)
+		}
+		return response, rec
+	}
+	first, _ := submit()
+	replayed, rec := submit()
+	if rec.Header().Get("Idempotent-Replayed") != "true" {
+		t.Errorf("replay: missing Idempotent-Replayed header")
+	}
+	var count int64
+	if err := s.app.DB.Model(&models.Session{}).Count(&count).Error; err != nil || count != 1 {
+		t.Errorf("got %d sessions, %v", count, err)
+	}
+	if replayed.ResultsToken != first.ResultsToken || replayed.InviteToken != first.InviteToken {
+		t.Errorf("replay: got %+v, want %+v", replayed, first)
+	}
+	if err := s.app.DB.Model(&models.SessionToken{}).Count(&count).Error; err != nil || count != 2 {
+		t.Errorf("got %d tokens, %v", count, err)
+	}
+
+	// Callers with different invitations do not share keys
+	var second SubmitUserAResponse
+	if status := s.do(t, "POST", "/api/submit-user-a", `{"answers":{"1":"红"}}`, &second); status != http.StatusOK {
+		t.Fatalf("submit A: got %d", status)
+	}
+	for _, token := range []string{first.InviteToken, second.InviteToken} {
+		req := httptest.NewRequest("POST", "/api/submit-user-b", strings.NewReader(`{"token":"`+token+`","answers":{"1":"蓝"}}`))
+		req.Header.Set("Idempotency-Key", "5f0c8e9a-2b1d-4c3e-9f7a-6d8b0e1c2a34")
+		rec := httptest.NewRecorder()
+		s.router.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK || rec.Header().Get("Idempotent-Replayed") != "" {
+			t.Errorf("submit B with %s: got %d, replayed %q", token, rec.Code, rec.Header().Get("Idempotent-Replayed"))
+		}
+	}
+
+	req := httptest.NewRequest("POST", "/api/submit-user-a", strings.NewReader(`{"answers":{"1":"红"}}`))
+	req.Header.Set("Idempotency-Key", "key-1")
+	rec = httptest.NewRecorder()
+	s.router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("short key: got %d", rec.Code)
+	}
+}
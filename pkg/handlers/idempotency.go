@@ -0,0 +1,252 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"openai-api/pkg/apierror"
+	"openai-api/pkg/auth"
+	"openai-api/pkg/encryption"
+	"openai-api/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+// IdempotencyKeyHeader carries the key a client chooses for a request it may
+// retry. See App.Idempotent.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// IdempotentReplayedHeader is set to "true" on replayed responses.
+const IdempotentReplayedHeader = "Idempotent-Replayed"
+
+const (
+	// minIdempotencyKeyLength is the minimum length of an idempotency key.
+	// The key is all it takes to replay a response to submit-user-a,
+	// including the tokens in it, so it must be hard to guess.
+	minIdempotencyKeyLength = 16
+
+	// maxIdempotencyKeyLength is the maximum length of an idempotency key.
+	maxIdempotencyKeyLength = 255
+
+	// idempotencyKeyTTL is how long responses are kept for replay.
+	idempotencyKeyTTL = 24 * time.Hour
+
+	// idempotencyLockTimeout is how long a request may hold its key before
+	// it is assumed to have been abandoned, e.g. by a crashed process.
+	idempotencyLockTimeout = 2 * time.Minute
+)
+
+// Idempotent makes a handler safe to retry. A request with an
+// Idempotency-Key header runs next only the first time the caller uses the
+// key on the endpoint; retries with the same key and body get the stored
+// response instead, with the Idempotent-Replayed header set. Reusing a key for
+// a different body is an idempotency_key_reused error, and retrying while the
+// first request is still being processed a request_in_progress error.
+// Server errors are not stored, so the request can be retried. Requests
+// without the header are passed through.
+//
+// Keys are scoped to the token in the request body, if any, so callers with
+// different invitations never share a key. Only a hash of the key is stored,
+// and the response is encrypted with the key, see idempotencySecret: replays
+// return the tokens the first request created, which only the caller who
+// chose the key can read back. A replay whose tokens have been revoked since
+// is a token_revoked error.
+func (a *App) Idempotent(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(IdempotencyKeyHeader)
+		if key == "" {
+			next(w, r)
+			return
+		}
+		if len(key) < minIdempotencyKeyLength || len(key) > maxIdempotencyKeyLength {
+			apierror.Write(w, http.StatusBadRequest, apierror.CodeInvalidRequest, fmt.Sprintf("%s must be %d to %d characters", IdempotencyKeyHeader, minIdempotencyKeyLength, maxIdempotencyKeyLength))
+			return
+		}
+
+		// Read the body to tell retries from other requests reusing the key
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, a.Config.MaxRequestBytes))
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			apierror.Write(w, http.StatusRequestEntityTooLarge, apierror.CodeRequestTooLarge, fmt.Sprintf("Request body must be at most %d bytes", tooLarge.Limit))
+			return
+		}
+		if err != nil {
+			apierror.Write(w, http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid request body")
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		hash := sha256.Sum256(body)
+		secret := idempotencySecret(body, key)
+
+		// Claim the key, forgetting expired and abandoned ones first
+		now := time.Now()
+		err = a.DB.Where("created_at < ? OR (status_code = 0 AND created_at < ?)", now.Add(-idempotencyKeyTTL), now.Add(-idempotencyLockTimeout)).
+			Delete(&models.IdempotentRequest{}).Error
+		if err != nil {
+			a.Logger.Printf("Failed to delete expired idempotency keys: %v", err)
+		}
+		record := models.IdempotentRequest{
+			Endpoint:       r.Method + " " + r.URL.Path,
+			IdempotencyKey: auth.HashToken(secret),
+			RequestHash:    hex.EncodeToString(hash[:]),
+		}
+		if err := a.DB.Create(&record).Error; err != nil {
+			a.replayIdempotent(w, record, secret)
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		next(recorder, r)
+
+		// Store the response, or release the key so a failed request can be retried
+		if recorder.status >= http.StatusInternalServerError {
+			err = a.DB.Delete(&record).Error
+		} else {
+			record.StatusCode = recorder.status
+			record.SessionID, err = a.responseSession(recorder.body.Bytes())
+			if err == nil {
+				record.Response, err = encryption.SealWithSecret(secret, recorder.body.String(), record.Endpoint)
+			}
+			if err == nil {
+				err = a.DB.Model(&record).Select("status_code", "response", "session_id").Updates(&record).Error
+			}
+		}
+		if err != nil {
+			a.Logger.Printf("Failed to store response for idempotency key: %v", err)
+		}
+	}
+}
+
+// idempotencySecret returns the idempotency key scoped to the caller: the
+// key, prefixed with the token in the request body if there is one. Its hash
+// identifies the stored response, and it encrypts it.
+func idempotencySecret(body []byte, key string) string {
+	var request struct {
+		Token string `json:"token"`
+	}
+	json.Unmarshal(body, &request)
+	return request.Token + "\x00" + key
+}
+
+// replayIdempotent answers a request whose idempotency key is already in use
+// on its endpoint.
+func (a *App) replayIdempotent(w http.ResponseWriter, request models.IdempotentRequest, secret string) {
+	var stored models.IdempotentRequest
+	err := a.DB.Where("endpoint = ? AND idempotency_key = ?", request.Endpoint, request.IdempotencyKey).First(&stored).Error
+	switch {
+	case err != nil:
+		a.Logger.Printf("Failed to look up idempotency key: %v", err)
+		apierror.Write(w, http.StatusInternalServerError, apierror.CodeInternal, "Failed to check idempotency key")
+	case stored.RequestHash != request.RequestHash:
+		apierror.Write(w, http.StatusUnprocessableEntity, apierror.CodeIdempotencyKeyReused, "Idempotency key was already used for a different request")
+	case stored.StatusCode == 0:
+		apierror.Write(w, http.StatusConflict, apierror.CodeRequestInProgress, "A request with this idempotency key is still being processed")
+	default:
+		response, err := encryption.OpenWithSecret(secret, stored.Response, stored.Endpoint)
+		if err == nil {
+			err = a.checkResponseTokens([]byte(response))
+		}
+		switch {
+		case errors.Is(err, errTokenRevoked):
+			apierror.Write(w, http.StatusGone, apierror.CodeTokenRevoked, "The tokens created by the request have been revoked")
+			return
+		case err != nil:
+			a.Logger.Printf("Failed to replay response for idempotency key: %v", err)
+			apierror.Write(w, http.StatusInternalServerError, apierror.CodeInternal, "Failed to replay response")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set(IdempotentReplayedHeader, "true")
+		w.WriteHeader(stored.StatusCode)
+		io.WriteString(w, response)
+	}
+}
+
+// errTokenRevoked is returned by checkResponseTokens if a token of the stored
+// response has been revoked.
+var errTokenRevoked = errors.New("token revoked")
+
+// responseSession returns the session the session tokens among the top-level
+// fields of a JSON response belong to, if any, so the stored response can be
+// deleted with the session.
+func (a *App) responseSession(body []byte) (*uint, error) {
+	var sessionID *uint
+	for _, token := range sessionTokens(body) {
+		var record models.SessionToken
+		if err := a.DB.Where("token_hash = ?", auth.HashToken(token)).First(&record).Error; err != nil {
+			return nil, fmt.Errorf("failed to find token of response: %w", err)
+		}
+		sessionID = &record.SessionID
+	}
+	return sessionID, nil
+}
+
+// checkResponseTokens returns errTokenRevoked if one of the session tokens in
+// a stored response has been revoked since it was stored.
+func (a *App) checkResponseTokens(body []byte) error {
+	for _, token := range sessionTokens(body) {
+		var record models.SessionToken
+		err := a.DB.Unscoped().Where("token_hash = ?", auth.HashToken(token)).First(&record).Error
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			return errTokenRevoked
+		case err != nil:
+			return err
+		case record.DeletedAt.Valid:
+			return errTokenRevoked
+		}
+	}
+	return nil
+}
+
+// sessionTokens returns the invitation and results tokens among the string
+// values of the top-level fields of a JSON object whose names end in "Token",
+// e.g. inviteToken and resultsToken.
+func sessionTokens(body []byte) []string {
+	var fields map[string]json.RawMessage
+	if json.Unmarshal(body, &fields) != nil {
+		return nil
+	}
+	var tokens []string
+	for name, raw := range fields {
+		var value string
+		if !strings.HasSuffix(name, "Token") || json.Unmarshal(raw, &value) != nil {
+			continue
+		}
+		if strings.HasPrefix(value, tokenPrefixes[models.TokenPurposeInvite]) || strings.HasPrefix(value, tokenPrefixes[models.TokenPurposeResults]) {
+			tokens = append(tokens, value)
+		}
+	}
+	return tokens
+}
+
+// responseRecorder passes a response through while keeping a copy of its
+// status and body.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(data []byte) (int, error) {
+	r.body.Write(data)
+	return r.ResponseWriter.Write(data)
+}
+
+// Unwrap lets http.ResponseController reach the underlying ResponseWriter.
+func (r *responseRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
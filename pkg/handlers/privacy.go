@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"openai-api/pkg/answers"
+	"openai-api/pkg/apierror"
+	"openai-api/pkg/models"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+// erasedAnswers is stored in place of the answers of participants who erased them.
+const erasedAnswers = "{}"
+
+// DataExportResponse is everything stored about a participant, returned by
+// the GET /api/my-data/{token} endpoint. Results is nil until the analysis
+// has finished.
+type DataExportResponse struct {
+	SessionKind  string             `json:"sessionKind"`
+	Role         string             `json:"role"`
+	Name         string             `json:"name"`
+	Answers      answers.Answers    `json:"answers"`
+	ShareAnswers bool               `json:"shareAnswers"`
+	Revisions    int                `json:"revisions"`
+	SubmittedAt  time.Time          `json:"submittedAt"`
+	UpdatedAt    time.Time          `json:"updatedAt"`
+	ErasedAt     *time.Time         `json:"erasedAt"`
+	Results      *DataExportResults `json:"results"`
+}
+
+// DataExportResults are the results of the session a participant took part
+// in. Breakdown is set for pair sessions; Pairs, the participant's scores
+// with each other member, for group sessions.
+type DataExportResults struct {
+	Status        string              `json:"status"`
+	Compatibility int                 `json:"compatibility"`
+	Summary       string              `json:"summary"`
+	Engine        string              `json:"engine,omitempty"`
+	Breakdown     []QuestionBreakdown `json:"breakdown,omitempty"`
+	Pairs         []PairVerdict       `json:"pairs,omitempty"`
+}
+
+// ExportData handles the GET /api/my-data/{token} endpoint.
+// It returns everything stored about the participant the results token
+// belongs to, but none of the answers of the other participants.
+func (a *App) ExportData(w http.ResponseWriter, r *http.Request) {
+	session, participant, ok := a.findParticipant(w, mux.Vars(r)["token"])
+	if !ok {
+		return
+	}
+
+	stored, err := answers.Parse(participant.Answers)
+	if err != nil {
+		a.Logger.Printf("Failed to parse answers of participant %d: %v", participant.ID, err)
+		apierror.Write(w, http.StatusInternalServerError, apierror.CodeInternal, "Failed to parse answers")
+		return
+	}
+	response := DataExportResponse{
+		SessionKind:  session.Kind,
+		Role:         participant.Role,
+		Name:         participant.Name,
+		Answers:      stored,
+		ShareAnswers: participant.ShareAnswers,
+		Revisions:    participant.Revisions,
+		SubmittedAt:  participant.CreatedAt,
+		UpdatedAt:    participant.UpdatedAt,
+		ErasedAt:     participant.ErasedAt,
+	}
+
+	// Add the results once the analysis has finished
+	switch session.Status {
+	case models.SessionStatusDone, models.SessionStatusFailed, models.SessionStatusErased:
+		results := DataExportResults{
+			Status:        session.Status,
+			Compatibility: session.Compatibility,
+			Summary:       session.Summary,
+			Engine:        session.Engine,
+		}
+		if session.Kind == models.SessionKindGroup {
+			var scores []models.PairScore
+			err = a.DB.Where("session_id = ? AND (participant_a_id = ? OR participant_b_id = ?)", session.ID, participant.ID, participant.ID).
+				Order("participant_a_id, participant_b_id").Find(&scores).Error
+			for _, score := range scores {
+				results.Pairs = append(results.Pairs, PairVerdict{A: score.ParticipantAID, B: score.ParticipantBID, Score: score.Score, Note: score.Note})
+			}
+		} else {
+			var scores []models.QuestionScore
+			err = a.DB.Where("session_id = ?", session.ID).Order("id").Find(&scores).Error
+			for _, score := range scores {
+				results.Breakdown = append(results.Breakdown, QuestionBreakdown{QuestionID: score.QuestionID, Question: score.Question, Score: score.Score, Note: score.Note})
+			}
+		}
+		if err != nil {
+			apierror.Write(w, http.StatusInternalServerError, apierror.CodeInternal, "Failed to find results")
+			return
+		}
+		response.Results = &results
+	}
+
+	// Return response
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", `attachment; filename="cyberqa-data.json"`)
+	json.NewEncoder(w).Encode(response)
+}
+
+// EraseData handles the DELETE /api/my-data/{token} endpoint.
+// It permanently erases the answers and name of the participant the results
+// token belongs to, and redacts the summary and the notes of the analysis,
+// which may quote them. Scores are kept; the responses stored for retrying
+// submissions to the session are deleted. The session is closed: it takes no
+// more answers, revisions or members and is not analysed again. Erasing is
+// refused while the analysis is queued or running.
+func (a *App) EraseData(w http.ResponseWriter, r *http.Request) {
+	session, participant, ok := a.findParticipant(w, mux.Vars(r)["token"])
+	if !ok {
+		return
+	}
+
+	err := a.DB.Transaction(func(tx *gorm.DB) error {
+		erased, err := transitionSession(tx, session.ID, models.SessionStatusErased,
+			"", models.SessionStatusDone, models.SessionStatusFailed, models.SessionStatusErased)
+		if err != nil {
+			return err
+		}
+		if !erased {
+			return errAnalysisRunning
+		}
+
+		erasedAt := time.Now()
+		if participant.ErasedAt != nil {
+			erasedAt = *participant.ErasedAt
+		}
+		err = tx.Model(&participant).Select("answers", "name", "share_answers", "erased_at").Updates(&models.Participant{
+			Answers:  erasedAnswers,
+			ErasedAt: &erasedAt,
+		}).Error
+		if err != nil {
+			return err
+		}
+		err = tx.Model(&session).Select("summary", "analysis_error").Updates(&models.Session{}).Error
+		if err != nil {
+			return err
+		}
+		if err := tx.Model(&models.QuestionScore{}).Where("session_id = ?", session.ID).Update("note", "").Error; err != nil {
+			return err
+		}
+		if err := tx.Where("session_id = ?", session.ID).Delete(&models.IdempotentRequest{}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.PairScore{}).Where("session_id = ? AND (participant_a_id = ? OR participant_b_id = ?)", session.ID, participant.ID, participant.ID).
+			Update("note", "").Error
+	})
+	switch {
+	case errors.Is(err, errAnalysisRunning):
+		apierror.Write(w, http.StatusConflict, apierror.CodeAnalysisRunning, "Answers can be erased once the analysis has finished")
+		return
+	case err != nil:
+		apierror.Write(w, http.StatusInternalServerError, apierror.CodeInternal, "Failed to erase data")
+		return
+	}
+	a.Logger.Printf("Participant %d erased their data in session %d", participant.ID, session.ID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// findParticipant finds the participant a results token belongs to, writing
+// the error response if there is none. Legacy tokens are shared by every
+// participant of their session, so they do not identify one; their holders
+// have to ask for a link of their own.
+func (a *App) findParticipant(w http.ResponseWriter, token string) (models.Session, models.Participant, bool) {
+	var participant models.Participant
+	session, record, ok := a.findSessionToken(w, token, models.TokenPurposeResults, models.TokenPurposeLegacy)
+	if !ok {
+		return session, participant, false
+	}
+	if record.ParticipantID == nil {
+		apierror.Write(w, http.StatusForbidden, apierror.CodeWrongTokenPurpose, "Links created before the upgrade are shared by all participants; request a new link of your own to export or erase your data")
+		return session, participant, false
+	}
+	err := a.DB.Where("session_id = ?", session.ID).First(&participant, *record.ParticipantID).Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		apierror.Write(w, http.StatusNotFound, apierror.CodeSessionNotFound, "Invalid token")
+		return session, participant, false
+	case err != nil:
+		apierror.Write(w, http.StatusInternalServerError, apierror.CodeInternal, "Failed to find participant")
+		return session, participant, false
+	}
+	return session, participant, true
+}
@@ -0,0 +1,263 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"openai-api/pkg/answers"
+	"openai-api/pkg/apierror"
+	"openai-api/pkg/models"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+// errUnknownQuestionnaire is returned when a requested questionnaire does not exist.
+var errUnknownQuestionnaire = errors.New("unknown questionnaire")
+
+// slugPattern matches valid questionnaire slugs.
+var slugPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// QuestionnaireRequest represents the request body for creating or updating a questionnaire.
+// The slug cannot be changed once the questionnaire exists.
+type QuestionnaireRequest struct {
+	Slug        string `json:"slug"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Language    string `json:"language"`
+	Theme       string `json:"theme"`
+}
+
+// QuestionnaireResponse represents a questionnaire. Version is the latest
+// published question set version, omitted if none was published yet.
+// Default is set on the questionnaire used when a request names none.
+type QuestionnaireResponse struct {
+	Slug        string `json:"slug"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Language    string `json:"language"`
+	Theme       string `json:"theme"`
+	Version     *int   `json:"version,omitempty"`
+	Default     bool   `json:"default"`
+}
+
+// ListQuestionnaires handles GET /api/questionnaires. It lists the
+// questionnaires users can take, i.e. those with a published question set.
+func (a *App) ListQuestionnaires(w http.ResponseWriter, r *http.Request) {
+	a.writeQuestionnaires(w, true)
+}
+
+// ListAdminQuestionnaires handles GET /api/admin/questionnaires. It lists every questionnaire.
+func (a *App) ListAdminQuestionnaires(w http.ResponseWriter, r *http.Request) {
+	a.writeQuestionnaires(w, false)
+}
+
+// CreateQuestionnaire handles POST /api/admin/questionnaires.
+func (a *App) CreateQuestionnaire(w http.ResponseWriter, r *http.Request) {
+	var req QuestionnaireRequest
+	if !a.decodeRequest(w, r, &req) {
+		return
+	}
+	if !slugPattern.MatchString(req.Slug) || len(req.Slug) > 100 {
+		writeValidationErrors(w, "Invalid questionnaire", FieldError{
+			Field:   "slug",
+			Code:    answers.CodeInvalid,
+			Message: "Slug must be lowercase letters, digits and hyphens",
+		})
+		return
+	}
+	questionnaire := models.Questionnaire{Slug: req.Slug}
+	if !applyQuestionnaireRequest(w, &questionnaire, req) {
+		return
+	}
+
+	var existing int64
+	if err := a.DB.Unscoped().Model(&models.Questionnaire{}).Where("slug = ?", req.Slug).Count(&existing).Error; err != nil {
+		apierror.Write(w, http.StatusInternalServerError, apierror.CodeInternal, "Failed to save questionnaire")
+		return
+	}
+	if existing > 0 {
+		apierror.Write(w, http.StatusConflict, apierror.CodeSlugTaken, "A questionnaire with this slug already exists")
+		return
+	}
+	if err := a.DB.Create(&questionnaire).Error; err != nil {
+		a.Logger.Printf("Error creating questionnaire %q: %v", req.Slug, err)
+		apierror.Write(w, http.StatusInternalServerError, apierror.CodeInternal, "Failed to save questionnaire")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(a.questionnaireResponse(questionnaire, nil))
+}
+
+// UpdateQuestionnaire handles PUT /api/admin/questionnaires/{slug}.
+func (a *App) UpdateQuestionnaire(w http.ResponseWriter, r *http.Request) {
+	questionnaire, ok := a.requestQuestionnaire(w, mux.Vars(r)["slug"])
+	if !ok {
+		return
+	}
+	var req QuestionnaireRequest
+	if !a.decodeRequest(w, r, &req) {
+		return
+	}
+	if req.Slug != "" && req.Slug != questionnaire.Slug {
+		writeValidationErrors(w, "Invalid questionnaire", FieldError{
+			Field:   "slug",
+			Code:    answers.CodeInvalid,
+			Message: "The slug of a questionnaire cannot be changed",
+		})
+		return
+	}
+	if !applyQuestionnaireRequest(w, &questionnaire, req) {
+		return
+	}
+
+	err := a.DB.Model(&questionnaire).Select("Title", "Description", "Language", "Theme").Updates(&questionnaire).Error
+	if err != nil {
+		a.Logger.Printf("Error updating questionnaire %q: %v", questionnaire.Slug, err)
+		apierror.Write(w, http.StatusInternalServerError, apierror.CodeInternal, "Failed to save questionnaire")
+		return
+	}
+
+	version, err := a.latestVersion(questionnaire.ID)
+	if err != nil {
+		apierror.Write(w, http.StatusInternalServerError, apierror.CodeInternal, "Failed to retrieve question set")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(a.questionnaireResponse(questionnaire, version))
+}
+
+// writeQuestionnaires writes the list of questionnaires, ordered by slug,
+// optionally leaving out those without a published question set.
+func (a *App) writeQuestionnaires(w http.ResponseWriter, publishedOnly bool) {
+	var questionnaires []models.Questionnaire
+	if err := a.DB.Order("slug").Find(&questionnaires).Error; err != nil {
+		apierror.Write(w, http.StatusInternalServerError, apierror.CodeInternal, "Failed to retrieve questionnaires")
+		return
+	}
+	var latest []struct {
+		QuestionnaireID uint
+		Version         int
+	}
+	err := a.DB.Model(&models.QuestionSet{}).
+		Select("questionnaire_id, MAX(version) AS version").
+		Group("questionnaire_id").
+		Scan(&latest).Error
+	if err != nil {
+		apierror.Write(w, http.StatusInternalServerError, apierror.CodeInternal, "Failed to retrieve questionnaires")
+		return
+	}
+	versions := make(map[uint]int, len(latest))
+	for _, row := range latest {
+		versions[row.QuestionnaireID] = row.Version
+	}
+
+	response := []QuestionnaireResponse{}
+	for _, questionnaire := range questionnaires {
+		version, ok := versions[questionnaire.ID]
+		if publishedOnly && !ok {
+			continue
+		}
+		var versionPtr *int
+		if ok {
+			versionPtr = &version
+		}
+		response = append(response, a.questionnaireResponse(questionnaire, versionPtr))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// applyQuestionnaireRequest validates the metadata in a request and copies it
+// to a questionnaire, writing a 400 response if it is invalid.
+func applyQuestionnaireRequest(w http.ResponseWriter, questionnaire *models.Questionnaire, req QuestionnaireRequest) bool {
+	title := strings.TrimSpace(req.Title)
+	var fields []FieldError
+	if title == "" {
+		fields = append(fields, FieldError{Field: "title", Code: answers.CodeRequired, Message: "Title is required"})
+	}
+	for _, limit := range []struct {
+		field, value string
+		max          int
+	}{{"title", title, 255}, {"language", req.Language, 20}, {"theme", req.Theme, 50}} {
+		if len(limit.value) > limit.max {
+			fields = append(fields, FieldError{
+				Field:   limit.field,
+				Code:    answers.CodeTooLong,
+				Message: fmt.Sprintf("%s must be at most %d bytes", strings.ToUpper(limit.field[:1])+limit.field[1:], limit.max),
+			})
+		}
+	}
+	if len(fields) > 0 {
+		writeValidationErrors(w, "Invalid questionnaire", fields...)
+		return false
+	}
+	questionnaire.Title = title
+	questionnaire.Description = strings.TrimSpace(req.Description)
+	questionnaire.Language = strings.TrimSpace(req.Language)
+	questionnaire.Theme = strings.TrimSpace(req.Theme)
+	return true
+}
+
+// requestQuestionnaire loads a questionnaire by slug, or the default
+// questionnaire if slug is empty, writing a 404 response if it does not exist.
+func (a *App) requestQuestionnaire(w http.ResponseWriter, slug string) (models.Questionnaire, bool) {
+	questionnaire, err := a.findQuestionnaire(slug)
+	if errors.Is(err, errUnknownQuestionnaire) {
+		apierror.Write(w, http.StatusNotFound, apierror.CodeQuestionnaireNotFound, "Questionnaire not found")
+		return questionnaire, false
+	}
+	if err != nil {
+		apierror.Write(w, http.StatusInternalServerError, apierror.CodeInternal, "Failed to retrieve questionnaire")
+		return questionnaire, false
+	}
+	return questionnaire, true
+}
+
+// findQuestionnaire loads a questionnaire by slug. An empty slug means the
+// default questionnaire, Config.DefaultQuestionnaire.
+func (a *App) findQuestionnaire(slug string) (models.Questionnaire, error) {
+	if slug == "" {
+		slug = a.Config.DefaultQuestionnaire
+	}
+
+	var questionnaire models.Questionnaire
+	err := a.DB.Where("slug = ?", slug).First(&questionnaire).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return questionnaire, errUnknownQuestionnaire
+	}
+	return questionnaire, err
+}
+
+// latestVersion returns the latest published question set version of a
+// questionnaire, or nil if none was published yet.
+func (a *App) latestVersion(questionnaireID uint) (*int, error) {
+	var versions []int
+	err := a.DB.Model(&models.QuestionSet{}).
+		Where("questionnaire_id = ?", questionnaireID).
+		Order("version DESC").Limit(1).
+		Pluck("version", &versions).Error
+	if err != nil || len(versions) == 0 {
+		return nil, err
+	}
+	return &versions[0], nil
+}
+
+// questionnaireResponse converts a questionnaire to its response format.
+func (a *App) questionnaireResponse(questionnaire models.Questionnaire, version *int) QuestionnaireResponse {
+	return QuestionnaireResponse{
+		Slug:        questionnaire.Slug,
+		Title:       questionnaire.Title,
+		Description: questionnaire.Description,
+		Language:    questionnaire.Language,
+		Theme:       questionnaire.Theme,
+		Version:     version,
+		Default:     questionnaire.Slug == a.Config.DefaultQuestionnaire,
+	}
+}
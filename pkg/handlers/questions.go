@@ -0,0 +1,688 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"openai-api/pkg/answers"
+	"openai-api/pkg/apierror"
+	"openai-api/pkg/auth"
+	"openai-api/pkg/models"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+// questionSetVersionHeader tells clients of GET /api/questions which question
+// set version the questions belong to, so they can submit answers against it.
+const questionSetVersionHeader = "X-Question-Set-Version"
+
+var (
+	// errUnknownQuestionSet is returned when a requested question set version does not exist.
+	errUnknownQuestionSet = errors.New("unknown question set version")
+
+	// errEmptyQuestionBank is returned when publishing a question bank without questions.
+	errEmptyQuestionBank = errors.New("question bank is empty")
+)
+
+// questionColumns are the columns of a question set from a request.
+var questionColumns = []string{"QuestionText", "Type", "Required", "IsMultipleChoice", "Options", "Min", "Max"}
+
+// maxScalePoints is the largest number of points a scale question can have.
+const maxScalePoints = 11
+
+// questionError describes why a question in a request is invalid. Field is
+// the JSON name of the offending field.
+type questionError struct {
+	field  string
+	reason string
+}
+
+func (e *questionError) Error() string {
+	return e.reason
+}
+
+// fieldError converts the error to a FieldError, prefixing the field with
+// the path of the question in the request.
+func (e *questionError) fieldError(path string) FieldError {
+	return FieldError{
+		Field:   path + e.field,
+		Code:    answers.CodeInvalid,
+		Message: strings.ToUpper(e.reason[:1]) + e.reason[1:],
+	}
+}
+
+// BankQuestionResponse represents a question of the editable question bank.
+type BankQuestionResponse struct {
+	QuestionResponse
+	Position int `json:"position"`
+}
+
+// ReorderQuestionsRequest represents the request body for reordering the question bank.
+type ReorderQuestionsRequest struct {
+	IDs []uint `json:"ids"`
+}
+
+// QuestionSetResponse represents a published question set.
+type QuestionSetResponse struct {
+	Version       int                `json:"version"`
+	PublishedBy   string             `json:"publishedBy"`
+	PublishedAt   time.Time          `json:"publishedAt"`
+	QuestionCount int                `json:"questionCount"`
+	Questions     []QuestionResponse `json:"questions,omitempty"`
+}
+
+// ListBankQuestions handles GET /api/admin/questions. It returns the editable
+// question bank in order, including changes that have not been published.
+// Like the other question bank endpoints it works on the questionnaire given
+// by the questionnaire query parameter, or the default questionnaire.
+func (a *App) ListBankQuestions(w http.ResponseWriter, r *http.Request) {
+	questionnaire, ok := a.requestQuestionnaire(w, r.URL.Query().Get("questionnaire"))
+	if !ok {
+		return
+	}
+	var questions []models.Question
+	if err := a.DB.Where("questionnaire_id = ?", questionnaire.ID).Order("position, id").Find(&questions).Error; err != nil {
+		apierror.Write(w, http.StatusInternalServerError, apierror.CodeInternal, "Failed to retrieve questions")
+		return
+	}
+
+	response := make([]BankQuestionResponse, 0, len(questions))
+	for _, question := range questions {
+		response = append(response, bankQuestionResponse(question))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// CreateQuestion handles POST /api/admin/questions. The question is added at
+// the end of the bank.
+func (a *App) CreateQuestion(w http.ResponseWriter, r *http.Request) {
+	questionnaire, ok := a.requestQuestionnaire(w, r.URL.Query().Get("questionnaire"))
+	if !ok {
+		return
+	}
+	var req QuestionItem
+	if !a.decodeRequest(w, r, &req) {
+		return
+	}
+	question, err := newQuestion(req)
+	var invalid *questionError
+	if errors.As(err, &invalid) {
+		writeValidationErrors(w, "Invalid question", invalid.fieldError(""))
+		return
+	}
+	if err != nil {
+		apierror.Write(w, http.StatusInternalServerError, apierror.CodeInternal, "Failed to process question options")
+		return
+	}
+
+	question.QuestionnaireID = questionnaire.ID
+	err = a.DB.Transaction(func(tx *gorm.DB) error {
+		var last struct{ Position int }
+		err := tx.Model(&models.Question{}).
+			Where("questionnaire_id = ?", questionnaire.ID).
+			Select("COALESCE(MAX(position), 0) AS position").
+			Scan(&last).Error
+		if err != nil {
+			return err
+		}
+		question.Position = last.Position + 1
+		return tx.Create(&question).Error
+	})
+	if err != nil {
+		a.Logger.Printf("Error creating question: %v", err)
+		apierror.Write(w, http.StatusInternalServerError, apierror.CodeInternal, "Failed to save question")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(bankQuestionResponse(question))
+}
+
+// UpdateQuestion handles PUT /api/admin/questions/{id}.
+func (a *App) UpdateQuestion(w http.ResponseWriter, r *http.Request) {
+	existing, ok := a.findBankQuestion(w, mux.Vars(r)["id"])
+	if !ok {
+		return
+	}
+	var req QuestionItem
+	if !a.decodeRequest(w, r, &req) {
+		return
+	}
+	question, err := newQuestion(req)
+	var invalid *questionError
+	if errors.As(err, &invalid) {
+		writeValidationErrors(w, "Invalid question", invalid.fieldError(""))
+		return
+	}
+	if err != nil {
+		apierror.Write(w, http.StatusInternalServerError, apierror.CodeInternal, "Failed to process question options")
+		return
+	}
+
+	question.Model = existing.Model
+	question.ID = existing.ID
+	question.QuestionnaireID = existing.QuestionnaireID
+	question.Position = existing.Position
+	existing = question
+	err = a.DB.Model(&existing).Select(questionColumns).Updates(&existing).Error
+	if err != nil {
+		a.Logger.Printf("Error updating question %d: %v", existing.ID, err)
+		apierror.Write(w, http.StatusInternalServerError, apierror.CodeInternal, "Failed to save question")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(bankQuestionResponse(existing))
+}
+
+// DeleteQuestion handles DELETE /api/admin/questions/{id}. Published question
+// sets keep their copy of the question.
+func (a *App) DeleteQuestion(w http.ResponseWriter, r *http.Request) {
+	question, ok := a.findBankQuestion(w, mux.Vars(r)["id"])
+	if !ok {
+		return
+	}
+	if err := a.DB.Unscoped().Delete(&question).Error; err != nil {
+		a.Logger.Printf("Error deleting question %d: %v", question.ID, err)
+		apierror.Write(w, http.StatusInternalServerError, apierror.CodeInternal, "Failed to delete question")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ReorderQuestions handles PUT /api/admin/questions/order. The request lists
+// the IDs of every question in the bank in their new order.
+func (a *App) ReorderQuestions(w http.ResponseWriter, r *http.Request) {
+	questionnaire, ok := a.requestQuestionnaire(w, r.URL.Query().Get("questionnaire"))
+	if !ok {
+		return
+	}
+	var req ReorderQuestionsRequest
+	if !a.decodeRequest(w, r, &req) {
+		return
+	}
+
+	var ids []uint
+	if err := a.DB.Model(&models.Question{}).Where("questionnaire_id = ?", questionnaire.ID).Pluck("id", &ids).Error; err != nil {
+		apierror.Write(w, http.StatusInternalServerError, apierror.CodeInternal, "Failed to retrieve questions")
+		return
+	}
+	remaining := make(map[uint]bool, len(ids))
+	for _, id := range ids {
+		remaining[id] = true
+	}
+	for _, id := range req.IDs {
+		if !remaining[id] {
+			writeValidationErrors(w, "Invalid order", FieldError{
+				Field:   "ids",
+				Code:    answers.CodeInvalid,
+				Message: fmt.Sprintf("Question %d is unknown or listed twice", id),
+			})
+			return
+		}
+		delete(remaining, id)
+	}
+	if len(remaining) > 0 {
+		writeValidationErrors(w, "Invalid order", FieldError{
+			Field:   "ids",
+			Code:    answers.CodeInvalid,
+			Message: "The order must list every question in the bank",
+		})
+		return
+	}
+
+	err := a.DB.Transaction(func(tx *gorm.DB) error {
+		for i, id := range req.IDs {
+			if err := tx.Model(&models.Question{}).Where("id = ?", id).Update("position", i+1).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		a.Logger.Printf("Error reordering questions: %v", err)
+		apierror.Write(w, http.StatusInternalServerError, apierror.CodeInternal, "Failed to reorder questions")
+		return
+	}
+	a.ListBankQuestions(w, r)
+}
+
+// PublishQuestionSet handles POST /api/admin/question-sets. It publishes the
+// current question bank as a new question set version, which new sessions are
+// answered against. If the bank has not changed since the latest version, that
+// version is returned with 200 instead of publishing a new one.
+func (a *App) PublishQuestionSet(w http.ResponseWriter, r *http.Request) {
+	questionnaire, ok := a.requestQuestionnaire(w, r.URL.Query().Get("questionnaire"))
+	if !ok {
+		return
+	}
+	var set *models.QuestionSet
+	var created bool
+	err := a.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		set, created, err = a.publishQuestionSet(tx, questionnaire.ID, adminName(r))
+		return err
+	})
+	if errors.Is(err, errEmptyQuestionBank) {
+		apierror.Write(w, http.StatusConflict, apierror.CodeQuestionBankEmpty, "The question bank is empty")
+		return
+	}
+	if err != nil {
+		a.Logger.Printf("Error publishing question set: %v", err)
+		apierror.Write(w, http.StatusInternalServerError, apierror.CodeInternal, "Failed to publish question set")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if created {
+		w.WriteHeader(http.StatusCreated)
+	}
+	json.NewEncoder(w).Encode(questionSetResponse(*set, true))
+}
+
+// ListQuestionSets handles GET /api/admin/question-sets. It lists every
+// published version, newest first, without their questions.
+func (a *App) ListQuestionSets(w http.ResponseWriter, r *http.Request) {
+	questionnaire, ok := a.requestQuestionnaire(w, r.URL.Query().Get("questionnaire"))
+	if !ok {
+		return
+	}
+	var sets []models.QuestionSet
+	err := a.DB.Where("questionnaire_id = ?", questionnaire.ID).Order("version DESC").Preload("Questions").Find(&sets).Error
+	if err != nil {
+		apierror.Write(w, http.StatusInternalServerError, apierror.CodeInternal, "Failed to retrieve question sets")
+		return
+	}
+
+	response := make([]QuestionSetResponse, 0, len(sets))
+	for _, set := range sets {
+		response = append(response, questionSetResponse(set, false))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// GetQuestionSet handles GET /api/admin/question-sets/{version}.
+func (a *App) GetQuestionSet(w http.ResponseWriter, r *http.Request) {
+	questionnaire, ok := a.requestQuestionnaire(w, r.URL.Query().Get("questionnaire"))
+	if !ok {
+		return
+	}
+	version, err := strconv.Atoi(mux.Vars(r)["version"])
+	if err != nil || version < 1 {
+		apierror.Write(w, http.StatusNotFound, apierror.CodeQuestionSetNotFound, "Question set not found")
+		return
+	}
+	set, err := a.findQuestionSet(questionnaire.ID, version)
+	if errors.Is(err, errUnknownQuestionSet) {
+		apierror.Write(w, http.StatusNotFound, apierror.CodeQuestionSetNotFound, "Question set not found")
+		return
+	}
+	if err != nil {
+		apierror.Write(w, http.StatusInternalServerError, apierror.CodeInternal, "Failed to retrieve question set")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(questionSetResponse(*set, true))
+}
+
+// publishQuestionSet copies a questionnaire's question bank into a new question
+// set version. If the bank matches the latest version it returns that version and false.
+func (a *App) publishQuestionSet(tx *gorm.DB, questionnaireID uint, publishedBy string) (*models.QuestionSet, bool, error) {
+	var questions []models.Question
+	if err := tx.Where("questionnaire_id = ?", questionnaireID).Order("position, id").Find(&questions).Error; err != nil {
+		return nil, false, fmt.Errorf("failed to load questions: %w", err)
+	}
+	if len(questions) == 0 {
+		return nil, false, errEmptyQuestionBank
+	}
+
+	set := models.QuestionSet{QuestionnaireID: questionnaireID, Version: 1, PublishedBy: publishedBy}
+	for i, question := range questions {
+		set.Questions = append(set.Questions, models.QuestionSetItem{
+			QuestionID:       question.ID,
+			Position:         i + 1,
+			QuestionText:     question.QuestionText,
+			Type:             question.Type,
+			Required:         question.Required,
+			IsMultipleChoice: question.IsMultipleChoice,
+			Options:          question.Options,
+			Min:              question.Min,
+			Max:              question.Max,
+		})
+	}
+
+	var latest models.QuestionSet
+	err := tx.Where("questionnaire_id = ?", questionnaireID).
+		Order("version DESC").
+		Preload("Questions", orderByPosition).
+		First(&latest).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, false, fmt.Errorf("failed to load latest question set: %w", err)
+	}
+	if err == nil {
+		if sameQuestions(latest.Questions, set.Questions) {
+			return &latest, false, nil
+		}
+		set.Version = latest.Version + 1
+	}
+
+	if err := tx.Create(&set).Error; err != nil {
+		return nil, false, fmt.Errorf("failed to save question set: %w", err)
+	}
+	a.Logger.Printf("Published question set %d with %d questions", set.Version, len(set.Questions))
+	return &set, true, nil
+}
+
+// sameQuestions reports whether two question sets have the same questions in the same order.
+func sameQuestions(a, b []models.QuestionSetItem) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].QuestionID != b[i].QuestionID ||
+			a[i].QuestionText != b[i].QuestionText ||
+			a[i].Type != b[i].Type ||
+			a[i].Required != b[i].Required ||
+			a[i].IsMultipleChoice != b[i].IsMultipleChoice ||
+			a[i].Options != b[i].Options ||
+			!sameNumber(a[i].Min, b[i].Min) ||
+			!sameNumber(a[i].Max, b[i].Max) {
+			return false
+		}
+	}
+	return true
+}
+
+// sameNumber reports whether two optional numbers are equal.
+func sameNumber(a, b *float64) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// findQuestionSet loads a question set of a questionnaire with its questions.
+// Version zero means the latest version, and returns nil without an error if
+// none was published.
+func (a *App) findQuestionSet(questionnaireID uint, version int) (*models.QuestionSet, error) {
+	query := a.DB.Where("questionnaire_id = ?", questionnaireID).Preload("Questions", orderByPosition)
+	if version > 0 {
+		query = query.Where("version = ?", version)
+	} else {
+		query = query.Order("version DESC")
+	}
+
+	var set models.QuestionSet
+	err := query.First(&set).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		if version > 0 {
+			return nil, errUnknownQuestionSet
+		}
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &set, nil
+}
+
+// resolveQuestionSet returns the questionnaire a new session is started with,
+// by slug or the default one, and the ID of the question set version it is
+// pinned to, zero meaning the latest, or nil if no version was published yet.
+// It writes an error response if either does not exist.
+func (a *App) resolveQuestionSet(w http.ResponseWriter, slug string, version int) (models.Questionnaire, *uint, bool) {
+	if version < 0 {
+		writeValidationErrors(w, "Invalid question set version", FieldError{
+			Field:   "questionSetVersion",
+			Code:    answers.CodeInvalid,
+			Message: "Question set version must not be negative",
+		})
+		return models.Questionnaire{}, nil, false
+	}
+	questionnaire, err := a.findQuestionnaire(slug)
+	if errors.Is(err, errUnknownQuestionnaire) {
+		apierror.Write(w, http.StatusBadRequest, apierror.CodeQuestionnaireNotFound, "Unknown questionnaire")
+		return questionnaire, nil, false
+	}
+	if err != nil {
+		apierror.Write(w, http.StatusInternalServerError, apierror.CodeInternal, "Failed to retrieve questionnaire")
+		return questionnaire, nil, false
+	}
+
+	set, err := a.findQuestionSet(questionnaire.ID, version)
+	if errors.Is(err, errUnknownQuestionSet) {
+		apierror.Write(w, http.StatusBadRequest, apierror.CodeQuestionSetNotFound, "Unknown question set version")
+		return questionnaire, nil, false
+	}
+	if err != nil {
+		apierror.Write(w, http.StatusInternalServerError, apierror.CodeInternal, "Failed to retrieve question set")
+		return questionnaire, nil, false
+	}
+	if set == nil {
+		return questionnaire, nil, true
+	}
+	return questionnaire, &set.ID, true
+}
+
+// sessionQuestions returns the questions a session's answers were given
+// against. Sessions created before any question set was published use the
+// question bank of their questionnaire.
+func (a *App) sessionQuestions(session models.Session) ([]models.Question, error) {
+	if session.QuestionSetID == nil {
+		query := a.DB.Order("position, id")
+		if session.QuestionnaireID != nil {
+			query = query.Where("questionnaire_id = ?", *session.QuestionnaireID)
+		}
+		var questions []models.Question
+		if err := query.Find(&questions).Error; err != nil {
+			return nil, fmt.Errorf("failed to load questions: %w", err)
+		}
+		return questions, nil
+	}
+
+	var items []models.QuestionSetItem
+	if err := a.DB.Where("question_set_id = ?", *session.QuestionSetID).Order("position").Find(&items).Error; err != nil {
+		return nil, fmt.Errorf("failed to load question set %d: %w", *session.QuestionSetID, err)
+	}
+	return questionSetQuestions(items), nil
+}
+
+// questionSetQuestions converts the questions of a question set to questions
+// of the bank, keeping their bank IDs.
+func questionSetQuestions(items []models.QuestionSetItem) []models.Question {
+	questions := make([]models.Question, 0, len(items))
+	for _, item := range items {
+		questions = append(questions, models.Question{
+			ID:               item.QuestionID,
+			QuestionText:     item.QuestionText,
+			Type:             item.Type,
+			Required:         item.Required,
+			IsMultipleChoice: item.IsMultipleChoice,
+			Options:          item.Options,
+			Min:              item.Min,
+			Max:              item.Max,
+			Position:         item.Position,
+		})
+	}
+	return questions
+}
+
+// newQuestion validates a question from a request and converts it to a
+// database model, returning a *questionError if it is invalid. The ID in the
+// request is ignored. Options, minimum and maximum are dropped from question
+// types that do not use them.
+func newQuestion(item QuestionItem) (models.Question, error) {
+	text := strings.TrimSpace(item.QuestionText)
+	if text == "" {
+		return models.Question{}, &questionError{"question", "question text is required"}
+	}
+	questionType := item.Type
+	if questionType == "" {
+		questionType = models.QuestionTypeText
+		if item.IsMultipleChoice {
+			questionType = models.QuestionTypeSingleChoice
+		}
+	}
+
+	options := make([]string, 0, len(item.Options))
+	seen := make(map[string]bool, len(item.Options))
+	for _, option := range item.Options {
+		option = strings.TrimSpace(option)
+		if option == "" {
+			return models.Question{}, &questionError{"options", "options must not be empty"}
+		}
+		if seen[option] {
+			return models.Question{}, &questionError{"options", "options must not repeat"}
+		}
+		seen[option] = true
+		options = append(options, option)
+	}
+	low, high := item.Min, item.Max
+	if low != nil && high != nil && *low > *high {
+		return models.Question{}, &questionError{"min", "min must not be greater than max"}
+	}
+
+	switch questionType {
+	case models.QuestionTypeSingleChoice, models.QuestionTypeRanking:
+		if len(options) < 2 {
+			return models.Question{}, &questionError{"options", "choice and ranking questions need at least 2 options"}
+		}
+		low, high = nil, nil
+	case models.QuestionTypeMultiSelect:
+		if len(options) < 2 {
+			return models.Question{}, &questionError{"options", "multi-select questions need at least 2 options"}
+		}
+		for _, bound := range []*float64{low, high} {
+			if bound != nil && (*bound != math.Trunc(*bound) || *bound < 0 || *bound > float64(len(options))) {
+				return models.Question{}, &questionError{"min", "min and max of a multi-select question must be whole numbers of options"}
+			}
+		}
+	case models.QuestionTypeScale:
+		if len(options) > 0 {
+			if len(options) < 2 || len(options) > maxScalePoints || low != nil || high != nil {
+				return models.Question{}, &questionError{"options", fmt.Sprintf("scale questions need 2 to %d labels, or min and max instead", maxScalePoints)}
+			}
+			break
+		}
+		scale := models.Question{Min: low, Max: high}
+		from, to := answers.ScaleRange(scale)
+		if from != math.Trunc(from) || to != math.Trunc(to) || to <= from || to-from+1 > maxScalePoints {
+			return models.Question{}, &questionError{"min", fmt.Sprintf("scale questions need 2 to %d whole number points", maxScalePoints)}
+		}
+	case models.QuestionTypeNumber, models.QuestionTypeText:
+		options = []string{}
+		if questionType == models.QuestionTypeText {
+			low, high = nil, nil
+		}
+	default:
+		return models.Question{}, &questionError{"type", fmt.Sprintf("unknown question type %q", questionType)}
+	}
+
+	optionsJSON, err := json.Marshal(options)
+	if err != nil {
+		return models.Question{}, err
+	}
+	return models.Question{
+		QuestionText:     text,
+		Type:             questionType,
+		Required:         item.Required,
+		IsMultipleChoice: questionType == models.QuestionTypeSingleChoice,
+		Options:          string(optionsJSON),
+		Min:              low,
+		Max:              high,
+	}, nil
+}
+
+// findBankQuestion loads a question of the bank by the ID in the URL, writing
+// a 404 response if it does not exist.
+func (a *App) findBankQuestion(w http.ResponseWriter, rawID string) (models.Question, bool) {
+	var question models.Question
+	id, err := strconv.ParseUint(rawID, 10, 64)
+	if err != nil {
+		apierror.Write(w, http.StatusNotFound, apierror.CodeQuestionNotFound, "Question not found")
+		return question, false
+	}
+	err = a.DB.First(&question, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		apierror.Write(w, http.StatusNotFound, apierror.CodeQuestionNotFound, "Question not found")
+		return question, false
+	}
+	if err != nil {
+		apierror.Write(w, http.StatusInternalServerError, apierror.CodeInternal, "Failed to retrieve question")
+		return question, false
+	}
+	return question, true
+}
+
+// questionResponse converts a question to its response format.
+func questionResponse(question models.Question) QuestionResponse {
+	// Parse options JSON string
+	var options []string
+	if err := json.Unmarshal([]byte(question.Options), &options); err != nil {
+		// If parsing fails, use empty array
+		options = []string{}
+	}
+	response := QuestionResponse{
+		ID:               question.ID,
+		QuestionText:     question.QuestionText,
+		Type:             answers.Type(question),
+		Required:         question.Required,
+		IsMultipleChoice: question.IsMultipleChoice,
+		Options:          options,
+		Min:              question.Min,
+		Max:              question.Max,
+	}
+	// Spell out the range of scales, which may come from their labels or the defaults
+	if response.Type == models.QuestionTypeScale {
+		low, high := answers.ScaleRange(question)
+		response.Min, response.Max = &low, &high
+	}
+	return response
+}
+
+// bankQuestionResponse converts a question of the bank to its response format.
+func bankQuestionResponse(question models.Question) BankQuestionResponse {
+	return BankQuestionResponse{QuestionResponse: questionResponse(question), Position: question.Position}
+}
+
+// questionSetResponse converts a question set to its response format,
+// including its questions if withQuestions is set.
+func questionSetResponse(set models.QuestionSet, withQuestions bool) QuestionSetResponse {
+	response := QuestionSetResponse{
+		Version:       set.Version,
+		PublishedBy:   set.PublishedBy,
+		PublishedAt:   set.CreatedAt,
+		QuestionCount: len(set.Questions),
+	}
+	if withQuestions {
+		response.Questions = []QuestionResponse{}
+		for _, question := range questionSetQuestions(set.Questions) {
+			response.Questions = append(response.Questions, questionResponse(question))
+		}
+	}
+	return response
+}
+
+// adminName returns the username of the administrator making the request.
+func adminName(r *http.Request) string {
+	if admin, ok := auth.AdminFromContext(r.Context()); ok {
+		return admin.Username
+	}
+	return ""
+}
+
+// orderByPosition orders preloaded question set items by their position.
+func orderByPosition(db *gorm.DB) *gorm.DB {
+	return db.Order("position")
+}
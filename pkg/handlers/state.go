@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+
+	"openai-api/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+// transitionSession moves a session to status to if its status is one of
+// from, and reports whether it did. The check and the update are a single
+// statement, so of concurrent requests making the same transition only one
+// succeeds. It panics if models.CanTransition forbids one of the transitions.
+func transitionSession(db *gorm.DB, sessionID uint, to string, from ...string) (bool, error) {
+	query := db.Model(&models.Session{}).Where("id = ?", sessionID)
+	var conditions []string
+	var args []interface{}
+	for _, status := range from {
+		if !models.CanTransition(status, to) {
+			panic(fmt.Sprintf("invalid session transition from %q to %q", status, to))
+		}
+		if status == "" {
+			conditions = append(conditions, "status = '' OR status IS NULL")
+		} else {
+			conditions = append(conditions, "status = ?")
+			args = append(args, status)
+		}
+	}
+	result := query.Where("("+strings.Join(conditions, " OR ")+")", args...).Update("status", to)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
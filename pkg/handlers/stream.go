@@ -0,0 +1,254 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"openai-api/pkg/apierror"
+	"openai-api/pkg/models"
+
+	"github.com/gorilla/mux"
+)
+
+// StreamDeltaEvent is the payload of a "delta" or "replace" server-sent event.
+type StreamDeltaEvent struct {
+	Content string `json:"content"`
+}
+
+// StreamResults handles the GET /api/results/{token}/stream endpoint.
+// It relays the summary as server-sent "delta" events while the analysis runs
+// and finishes with a single "result" event carrying the ResultsResponse. A
+// "replace" event carries text that replaces the summary relayed so far, e.g.
+// when the local scorer takes over from a model that failed part way.
+func (a *App) StreamResults(w http.ResponseWriter, r *http.Request) {
+	// Find session by token
+	session, ok := a.findPairSession(w, mux.Vars(r)["token"])
+	if !ok {
+		return
+	}
+
+	// Check if UserB has submitted answers
+	if session.Status == "" {
+		apierror.Write(w, http.StatusNotFound, apierror.CodeAwaitingPartner, "User B has not submitted answers yet")
+		return
+	}
+
+	a.streamAnalysis(w, r, session.ID, func() (interface{}, error) {
+		if err := a.DB.Preload("Participants", orderByID).Preload("QuestionScores", orderByID).First(&session, session.ID).Error; err != nil {
+			return nil, fmt.Errorf("failed to find session: %w", err)
+		}
+		return buildResultsResponse(session)
+	})
+}
+
+// streamAnalysis relays a session's summary as server-sent "delta" and
+// "replace" events while its analysis runs, then sends the value returned by
+// result as a single "result" event.
+func (a *App) streamAnalysis(w http.ResponseWriter, r *http.Request, sessionID uint, result func() (interface{}, error)) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		apierror.Write(w, http.StatusInternalServerError, apierror.CodeInternal, "Streaming not supported")
+		return
+	}
+
+	// Streams outlive the server's write timeout
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		a.Logger.Printf("Failed to clear write deadline of stream: %v", err)
+	}
+
+	// Subscribe before checking the status so no delta is missed in between
+	text, deltas, cancel := a.jobs.Subscribe(sessionID)
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	if running, err := a.analysisInProgress(sessionID); err != nil {
+		a.writeErrorEvent(w, "Failed to find session")
+		flusher.Flush()
+		return
+	} else if running {
+		if text != "" {
+			a.writeEvent(w, "delta", StreamDeltaEvent{Content: text})
+		}
+		flusher.Flush()
+
+		heartbeat := time.NewTicker(15 * time.Second)
+		defer heartbeat.Stop()
+	relay:
+		for {
+			select {
+			case delta, ok := <-deltas:
+				if !ok {
+					break relay
+				}
+				event := "delta"
+				if delta.Replace {
+					event = "replace"
+				}
+				a.writeEvent(w, event, StreamDeltaEvent{Content: delta.Text})
+				flusher.Flush()
+			case <-heartbeat.C:
+				fmt.Fprint(w, ": keep-alive\n\n")
+				flusher.Flush()
+			case <-r.Context().Done():
+				return
+			}
+		}
+	}
+
+	// Send the final result
+	response, err := result()
+	if err != nil {
+		a.Logger.Printf("Failed to build results for session %d: %v", sessionID, err)
+		a.writeErrorEvent(w, "Failed to load results")
+		flusher.Flush()
+		return
+	}
+	a.writeEvent(w, "result", response)
+	flusher.Flush()
+}
+
+// analysisInProgress reports whether a session's analysis is queued or running.
+func (a *App) analysisInProgress(sessionID uint) (bool, error) {
+	var session models.Session
+	if err := a.DB.Select("status").First(&session, sessionID).Error; err != nil {
+		return false, err
+	}
+	return session.Status == models.SessionStatusPending || session.Status == models.SessionStatusRunning, nil
+}
+
+// writeEvent writes a single server-sent event with a JSON payload.
+func (a *App) writeEvent(w http.ResponseWriter, event string, payload interface{}) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		a.Logger.Printf("Failed to marshal %s event: %v", event, err)
+		return
+	}
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+}
+
+// writeErrorEvent writes an "error" server-sent event, carrying the same
+// fields as the body of an error response.
+func (a *App) writeErrorEvent(w http.ResponseWriter, message string) {
+	a.writeEvent(w, "error", apierror.Body{
+		Code:      apierror.CodeInternal,
+		Message:   message,
+		RequestID: w.Header().Get(apierror.RequestIDHeader),
+	})
+}
+
+// summaryExtractor pulls the value of the "summary" field out of a JSON
+// object that arrives in arbitrary fragments, so it can be relayed before the
+// whole object has been generated.
+type summaryExtractor struct {
+	// buf holds input that has not been consumed yet.
+	buf string
+
+	// state is 0 while looking for the field, 1 inside its string value and 2 once it has ended.
+	state int
+}
+
+// Write feeds the next fragment and returns the newly decoded summary text.
+func (e *summaryExtractor) Write(fragment string) string {
+	e.buf += fragment
+
+	if e.state == 0 {
+		idx := strings.Index(e.buf, `"summary"`)
+		if idx < 0 {
+			return ""
+		}
+		rest := strings.TrimLeft(e.buf[idx+len(`"summary"`):], " \t\r\n")
+		if rest == "" || (rest[0] == ':' && strings.TrimLeft(rest[1:], " \t\r\n") == "") {
+			return ""
+		}
+		if rest[0] != ':' {
+			// Not a key, e.g. the word appears inside another value
+			e.buf = e.buf[idx+len(`"summary"`):]
+			return e.Write("")
+		}
+		rest = strings.TrimLeft(rest[1:], " \t\r\n")
+		if rest[0] != '"' {
+			e.state = 2
+			return ""
+		}
+		e.buf = rest[1:]
+		e.state = 1
+	}
+	if e.state != 1 {
+		return ""
+	}
+
+	var out strings.Builder
+	for len(e.buf) > 0 {
+		c := e.buf[0]
+		switch {
+		case c == '"':
+			e.state = 2
+			e.buf = ""
+			return out.String()
+		case c != '\\':
+			_, size := utf8.DecodeRuneInString(e.buf)
+			if size == 1 && c >= utf8.RuneSelf && !utf8.FullRuneInString(e.buf) {
+				// Wait for the rest of a split multi-byte character
+				return out.String()
+			}
+			out.WriteString(e.buf[:size])
+			e.buf = e.buf[size:]
+		case len(e.buf) < 2:
+			return out.String()
+		case e.buf[1] == 'u':
+			if len(e.buf) < 6 {
+				return out.String()
+			}
+			code, err := strconv.ParseUint(e.buf[2:6], 16, 32)
+			r := rune(code)
+			if err == nil && utf16.IsSurrogate(r) {
+				// Surrogate pairs arrive as two consecutive escapes
+				if len(e.buf) < 12 {
+					return out.String()
+				}
+				if low, err := strconv.ParseUint(e.buf[8:12], 16, 32); err == nil && e.buf[6:8] == `\u` {
+					out.WriteRune(utf16.DecodeRune(r, rune(low)))
+					e.buf = e.buf[12:]
+					continue
+				}
+			}
+			if err == nil {
+				out.WriteRune(r)
+			}
+			e.buf = e.buf[6:]
+		default:
+			out.WriteString(unescapeJSON(e.buf[1]))
+			e.buf = e.buf[2:]
+		}
+	}
+	return out.String()
+}
+
+// unescapeJSON returns the character represented by a JSON escape sequence.
+func unescapeJSON(c byte) string {
+	switch c {
+	case 'n':
+		return "\n"
+	case 't':
+		return "\t"
+	case 'r':
+		return "\r"
+	case 'b':
+		return "\b"
+	case 'f':
+		return "\f"
+	default:
+		return string(c)
+	}
+}
@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"openai-api/pkg/apierror"
+	"openai-api/pkg/auth"
+	"openai-api/pkg/models"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+// tokenPrefixes start the tokens of each purpose, so users can tell an
+// invitation from a results link.
+var tokenPrefixes = map[string]string{
+	models.TokenPurposeInvite:  "inv_",
+	models.TokenPurposeResults: "res_",
+}
+
+// newSessionToken creates a token for purpose granting access to a session,
+// and returns it. Results tokens show the results of participantID. The token
+// expires after Config.InviteTokenDuration or Config.ResultsTokenDuration, if
+// set. The returned token is only available now; the database keeps its hash.
+func (a *App) newSessionToken(tx *gorm.DB, sessionID uint, participantID *uint, purpose string) (string, error) {
+	random, err := auth.RandomToken()
+	if err != nil {
+		return "", err
+	}
+	token := tokenPrefixes[purpose] + random
+	record := models.SessionToken{
+		SessionID:     sessionID,
+		ParticipantID: participantID,
+		Purpose:       purpose,
+		TokenHash:     auth.HashToken(token),
+	}
+	duration := a.Config.InviteTokenDuration
+	if purpose == models.TokenPurposeResults {
+		duration = a.Config.ResultsTokenDuration
+	}
+	if duration > 0 {
+		expiresAt := time.Now().Add(duration)
+		record.ExpiresAt = &expiresAt
+	}
+	if err := tx.Create(&record).Error; err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// findSessionToken finds the session a token grants access to, writing the
+// error response if the token is unknown, expired or revoked, or if it is
+// not one of purposes. Legacy tokens are only accepted if purposes include
+// TokenPurposeLegacy: they let whoever holds them answer the invitation and
+// view the results, but nothing else, and only for Config.LegacyTokenDuration
+// after migration 8 created them.
+func (a *App) findSessionToken(w http.ResponseWriter, token string, purposes ...string) (models.Session, models.SessionToken, bool) {
+	var session models.Session
+	var record models.SessionToken
+	err := a.DB.Unscoped().Where("token_hash = ?", auth.HashToken(token)).First(&record).Error
+	if err == nil && !record.DeletedAt.Valid {
+		err = a.DB.First(&session, record.SessionID).Error
+	}
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		apierror.Write(w, http.StatusNotFound, apierror.CodeSessionNotFound, "Invalid token")
+		return session, record, false
+	case err != nil:
+		apierror.Write(w, http.StatusInternalServerError, apierror.CodeInternal, "Failed to find session")
+		return session, record, false
+	case record.DeletedAt.Valid:
+		apierror.Write(w, http.StatusGone, apierror.CodeTokenRevoked, "Token has been revoked")
+		return session, record, false
+	case record.ExpiresAt != nil && !record.ExpiresAt.After(time.Now()):
+		apierror.Write(w, http.StatusGone, apierror.CodeTokenExpired, "Token has expired")
+		return session, record, false
+	case record.Purpose == models.TokenPurposeLegacy && !record.CreatedAt.Add(a.Config.LegacyTokenDuration).After(time.Now()):
+		apierror.Write(w, http.StatusGone, apierror.CodeTokenExpired, "Links created before the upgrade have expired; request a new link of your own")
+		return session, record, false
+	}
+
+	for _, purpose := range purposes {
+		if record.Purpose == purpose {
+			return session, record, true
+		}
+	}
+	switch record.Purpose {
+	case models.TokenPurposeInvite:
+		apierror.Write(w, http.StatusForbidden, apierror.CodeWrongTokenPurpose, "Invitation tokens only allow answering")
+	case models.TokenPurposeLegacy:
+		apierror.Write(w, http.StatusForbidden, apierror.CodeWrongTokenPurpose, "Links created before the upgrade only allow answering and viewing the results")
+	default:
+		apierror.Write(w, http.StatusForbidden, apierror.CodeWrongTokenPurpose, "Results tokens only allow viewing the results")
+	}
+	return session, record, false
+}
+
+// IssueResultsToken creates a results token for a participant of the session
+// a legacy token belongs to, and returns it. Legacy tokens are shared by every
+// participant, so administrators issue these to the participants who ask,
+// once they have made sure who is asking. participant is the participant's
+// ID, "initiator", or "member" in pair sessions.
+func (a *App) IssueResultsToken(legacyToken, participant string) (models.Participant, string, error) {
+	var record models.SessionToken
+	var found models.Participant
+	err := a.DB.Where("token_hash = ? AND purpose = ?", auth.HashToken(legacyToken), models.TokenPurposeLegacy).First(&record).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return found, "", errors.New("no such link created before the upgrade")
+	} else if err != nil {
+		return found, "", err
+	}
+
+	// Leave out the summary and answers, which the CLI reads without a keyring
+	var session models.Session
+	if err := a.DB.Select("id", "kind").First(&session, record.SessionID).Error; err != nil {
+		return found, "", err
+	}
+	query := a.DB.Select("id", "session_id", "role").Where("session_id = ?", session.ID)
+	if participant == models.ParticipantRoleInitiator || (session.Kind == models.SessionKindPair && participant == models.ParticipantRoleMember) {
+		query = query.Where("role = ?", participant).Order("id")
+	} else if id, err := strconv.ParseUint(participant, 10, 0); err == nil {
+		query = query.Where("id = ?", id)
+	} else {
+		return found, "", fmt.Errorf("participant must be an ID, initiator, or member in pair sessions, not %q", participant)
+	}
+	err = query.First(&found).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return found, "", fmt.Errorf("session %d has no participant %s", session.ID, participant)
+	} else if err != nil {
+		return found, "", err
+	}
+
+	token, err := a.newSessionToken(a.DB, session.ID, &found.ID, models.TokenPurposeResults)
+	return found, token, err
+}
+
+// RevokeToken handles the DELETE /api/tokens/{token} endpoint.
+// Anyone holding a token can revoke it, e.g. after sharing it by mistake.
+func (a *App) RevokeToken(w http.ResponseWriter, r *http.Request) {
+	hash := auth.HashToken(mux.Vars(r)["token"])
+	result := a.DB.Where("token_hash = ?", hash).Delete(&models.SessionToken{})
+	if result.Error != nil {
+		apierror.Write(w, http.StatusInternalServerError, apierror.CodeInternal, "Failed to revoke token")
+		return
+	}
+	if result.RowsAffected == 0 {
+		apierror.Write(w, http.StatusNotFound, apierror.CodeSessionNotFound, "Invalid token")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
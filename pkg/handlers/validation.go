@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"openai-api/pkg/answers"
+	"openai-api/pkg/apierror"
+	"openai-api/pkg/models"
+)
+
+// maxNameLength is the maximum number of characters of a participant name.
+const maxNameLength = 100
+
+// ValidationDetails are the details of a validation_failed error. Fields
+// lists every problem, not just the first one.
+type ValidationDetails struct {
+	Fields []FieldError `json:"fields"`
+}
+
+// FieldError describes one invalid field. Field is the JSON path of the
+// field, e.g. "answers.<question>" for an answer or "answers" for problems
+// with the answers as a whole. QuestionID is set for answers to known questions.
+type FieldError struct {
+	Field      string `json:"field"`
+	QuestionID uint   `json:"questionId,omitempty"`
+	Code       string `json:"code"`
+	Message    string `json:"message"`
+}
+
+// decodeRequest decodes a JSON request body, writing a 400 response if it is
+// invalid or a 413 response if it is larger than Config.MaxRequestBytes.
+func (a *App) decodeRequest(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	r.Body = http.MaxBytesReader(w, r.Body, a.Config.MaxRequestBytes)
+	err := json.NewDecoder(r.Body).Decode(v)
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		apierror.Write(w, http.StatusRequestEntityTooLarge, apierror.CodeRequestTooLarge, fmt.Sprintf("Request body must be at most %d bytes", tooLarge.Limit))
+		return false
+	}
+	if err != nil {
+		apierror.Write(w, http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid request body")
+		return false
+	}
+	return true
+}
+
+// checkAnswers validates submitted answers against the questions of a
+// session and returns them in canonical form. It writes a validation_failed
+// error, adding the given field errors, if they are invalid or fields is not
+// empty.
+func (a *App) checkAnswers(w http.ResponseWriter, session models.Session, submitted answers.Answers, fields ...FieldError) (answers.Answers, bool) {
+	questions, err := a.sessionQuestions(session)
+	if err != nil {
+		apierror.Write(w, http.StatusInternalServerError, apierror.CodeInternal, "Failed to retrieve questions")
+		return nil, false
+	}
+	checked, err := answers.Check(questions, submitted, a.Config.MaxAnswerLength)
+	var invalid answers.Errors
+	if errors.As(err, &invalid) {
+		for _, answerErr := range invalid {
+			field := FieldError{Field: "answers", Code: answerErr.Code, Message: strings.ToUpper(answerErr.Reason[:1]) + answerErr.Reason[1:]}
+			if answerErr.Key != "" {
+				field.QuestionID = answerErr.QuestionID
+				field.Field = "answers." + answerErr.Key
+				field.Message = "Answer " + answerErr.Reason
+			}
+			fields = append(fields, field)
+		}
+	} else if err != nil {
+		apierror.Write(w, http.StatusInternalServerError, apierror.CodeInternal, "Failed to check answers")
+		return nil, false
+	}
+	if len(fields) > 0 {
+		writeValidationErrors(w, "Invalid answers", fields...)
+		return nil, false
+	}
+	return checked, true
+}
+
+// checkName returns a field error if a participant name is too long.
+func checkName(name string) []FieldError {
+	if utf8.RuneCountInString(name) <= maxNameLength {
+		return nil
+	}
+	return []FieldError{{
+		Field:   "name",
+		Code:    answers.CodeTooLong,
+		Message: fmt.Sprintf("Name must be at most %d characters", maxNameLength),
+	}}
+}
+
+// writeValidationErrors writes a 400 validation_failed error listing the
+// invalid fields.
+func writeValidationErrors(w http.ResponseWriter, message string, fields ...FieldError) {
+	apierror.WriteDetails(w, http.StatusBadRequest, apierror.CodeValidationFailed, message, ValidationDetails{Fields: fields})
+}
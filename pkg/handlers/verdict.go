@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"openai-api/pkg/llm"
+)
+
+// errInvalidVerdict is returned when the model keeps producing output that
+// does not match the expected schema after all repair attempts.
+var errInvalidVerdict = errors.New("model output does not match the verdict schema")
+
+// verdictSchema is the JSON schema of OpenAIResponse.
+var verdictSchema = &llm.Schema{
+	Type:                 "object",
+	AdditionalProperties: boolPtr(false),
+	Required:             []string{"summary", "compatibility", "breakdown"},
+	Properties: map[string]*llm.Schema{
+		"summary": {
+			Type:        "string",
+			Description: "对两人关系的总结与详细分析",
+		},
+		"compatibility": {
+			Type:        "integer",
+			Description: "两人的契合度，0-100",
+			Minimum:     float64Ptr(0),
+			Maximum:     float64Ptr(100),
+		},
+		"breakdown": {
+			Type:        "array",
+			Description: "每个问题上两人答案的契合度",
+			Items: &llm.Schema{
+				Type:                 "object",
+				AdditionalProperties: boolPtr(false),
+				Required:             []string{"questionId", "question", "score", "note"},
+				Properties: map[string]*llm.Schema{
+					"questionId": {Type: "integer", Description: "输入中该问题的 id"},
+					"question":   {Type: "string", Description: "问题原文"},
+					"score":      {Type: "integer", Description: "该问题上的契合度，0-100", Minimum: float64Ptr(0), Maximum: float64Ptr(100)},
+					"note":       {Type: "string", Description: "一句话点评两人在该问题上的异同"},
+				},
+			},
+		},
+	},
+}
+
+// verdictResponseFormat returns the response format requested from the model.
+// Config.ResponseFormat selects "json_object" (the default, supported by most
+// OpenAI-compatible APIs), "json_schema" or "none". The output is validated
+// against the schema in every mode.
+func (a *App) verdictResponseFormat(name string, schema *llm.Schema) *llm.ResponseFormat {
+	formatType := a.Config.ResponseFormat
+	switch formatType {
+	case "none":
+		return nil
+	case llm.FormatJSONSchema:
+	default:
+		formatType = llm.FormatJSONObject
+	}
+	return &llm.ResponseFormat{
+		Type:   formatType,
+		Name:   name,
+		Schema: schema,
+	}
+}
+
+// parseVerdict validates the model output against verdictSchema and decodes it.
+func (a *App) parseVerdict(ctx context.Context, provider llm.Provider, request *llm.Request, content string) (*OpenAIResponse, error) {
+	var verdict OpenAIResponse
+	if err := a.parseModelOutput(ctx, provider, request, content, verdictSchema, verdictProblems, &verdict); err != nil {
+		return nil, err
+	}
+	return &verdict, nil
+}
+
+// parseModelOutput validates the model output with check and decodes it into out.
+// Invalid output is sent back to the model with the list of problems, up to
+// Config.MaxRepairs times, before giving up with errInvalidVerdict.
+func (a *App) parseModelOutput(ctx context.Context, provider llm.Provider, request *llm.Request, content string, schema *llm.Schema, check func(string) []string, out interface{}) error {
+	for attempt := 0; ; attempt++ {
+		content = stripCodeFences(content)
+		problems := check(content)
+		if len(problems) == 0 {
+			if err := json.Unmarshal([]byte(content), out); err != nil {
+				return fmt.Errorf("failed to decode verdict: %w", err)
+			}
+			return nil
+		}
+		if attempt >= a.Config.MaxRepairs {
+			return fmt.Errorf("%w: %s", errInvalidVerdict, strings.Join(problems, "; "))
+		}
+
+		// Ask the model to fix its own output
+		a.Logger.Printf("Model output failed validation (attempt %d), asking for a repair: %s", attempt+1, strings.Join(problems, "; "))
+		request.Messages = append(request.Messages,
+			llm.Message{Role: "assistant", Content: content},
+			llm.Message{Role: "user", Content: repairPrompt(schema, problems)},
+		)
+		response, err := provider.Complete(ctx, request)
+		if err != nil {
+			return fmt.Errorf("failed to get repaired response from %s: %w", provider.Name(), err)
+		}
+		content = response.Content
+	}
+}
+
+// verdictProblems returns the schema violations of the model output,
+// plus checks the schema cannot express portably.
+func verdictProblems(content string) []string {
+	problems := verdictSchema.Validate([]byte(content))
+	if len(problems) > 0 {
+		return problems
+	}
+
+	var verdict OpenAIResponse
+	if err := json.Unmarshal([]byte(content), &verdict); err != nil {
+		return []string{fmt.Sprintf("$: %v", err)}
+	}
+	if strings.TrimSpace(verdict.Summary) == "" {
+		problems = append(problems, "$.summary: must not be empty")
+	}
+	return problems
+}
+
+// repairPrompt builds the message asking the model to fix invalid output.
+func repairPrompt(schema *llm.Schema, problems []string) string {
+	encoded, _ := json.Marshal(schema)
+	return fmt.Sprintf("你的上一次输出不符合要求：\n- %s\n请修正以上问题，只输出一个符合以下 JSON Schema 的 JSON 对象，不要包含任何其他文字或代码块标记：\n%s",
+		strings.Join(problems, "\n- "), encoded)
+}
+
+// stripCodeFences removes a markdown code fence wrapped around the whole output.
+// Fences inside the JSON, e.g. in the summary text, are left alone.
+func stripCodeFences(content string) string {
+	content = strings.TrimSpace(content)
+	if !strings.HasPrefix(content, "```") {
+		return content
+	}
+	// Drop the opening fence line, e.g. ```json
+	newline := strings.Index(content, "\n")
+	if newline < 0 {
+		return content
+	}
+	content = strings.TrimSpace(content[newline+1:])
+	return strings.TrimSpace(strings.TrimSuffix(content, "```"))
+}
+
+// boolPtr returns a pointer to b.
+func boolPtr(b bool) *bool {
+	return &b
+}
+
+// float64Ptr returns a pointer to f.
+func float64Ptr(f float64) *float64 {
+	return &f
+}
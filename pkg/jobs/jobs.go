@@ -0,0 +1,188 @@
+// Package jobs runs compatibility analyses in background worker goroutines.
+package jobs
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"openai-api/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+// Handler processes the analysis job for a single session.
+type Handler func(ctx context.Context, sessionID uint) error
+
+// ErrShutdown is the cause of the cancellation of jobs still running when
+// Shutdown gives up waiting for them. See Interrupted.
+var ErrShutdown = errors.New("analysis queue shut down")
+
+// Interrupted reports whether ctx, the context of a job, was cancelled
+// because the queue shut down. Handlers should then leave the session
+// pending, so that the next process runs the job again.
+func Interrupted(ctx context.Context) bool {
+	return errors.Is(context.Cause(ctx), ErrShutdown)
+}
+
+// Queue is an in-memory queue of session IDs waiting for analysis.
+// The session's Status column in the database is the source of truth, so
+// jobs lost on restart are recovered by Start.
+type Queue struct {
+	mu      sync.Mutex
+	pending []uint
+	notify  chan struct{}
+	handler Handler
+	timeout time.Duration
+
+	// ctx is the parent of every job's context. It is cancelled with
+	// ErrShutdown when Shutdown times out.
+	ctx    context.Context
+	cancel context.CancelCauseFunc
+
+	// stop is closed by Shutdown to stop the workers taking new jobs.
+	stop     chan struct{}
+	stopOnce sync.Once
+	workers  sync.WaitGroup
+
+	progressMu sync.Mutex
+	inProgress map[uint]*progress
+	finished   bool // set once Shutdown has closed every subscription
+}
+
+// NewQueue creates a queue that runs handler for every job with the given timeout.
+// Jobs wait in the queue until Start launches its workers.
+func NewQueue(handler Handler, timeout time.Duration) *Queue {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	return &Queue{
+		notify:     make(chan struct{}, 1),
+		handler:    handler,
+		timeout:    timeout,
+		ctx:        ctx,
+		cancel:     cancel,
+		stop:       make(chan struct{}),
+		inProgress: map[uint]*progress{},
+	}
+}
+
+// Start launches the given number of workers and re-enqueues any sessions in
+// db left pending or running by a previous process. Running sessions are
+// reset to pending first, since handlers only run pending jobs.
+func (q *Queue) Start(db *gorm.DB, workers int) {
+	for i := 0; i < workers; i++ {
+		q.workers.Add(1)
+		go q.work()
+	}
+
+	// Recover unfinished jobs
+	err := db.Model(&models.Session{}).
+		Where("status = ?", models.SessionStatusRunning).
+		Update("status", models.SessionStatusPending).Error
+	if err != nil {
+		log.Printf("Failed to reset interrupted analysis jobs: %v", err)
+		return
+	}
+	var sessions []models.Session
+	err = db.
+		Where("status = ?", models.SessionStatusPending).
+		Find(&sessions).Error
+	if err != nil {
+		log.Printf("Failed to load unfinished analysis jobs: %v", err)
+		return
+	}
+	for _, session := range sessions {
+		q.Enqueue(session.ID)
+	}
+	if len(sessions) > 0 {
+		log.Printf("Re-enqueued %d unfinished analysis jobs", len(sessions))
+	}
+}
+
+// Enqueue adds a session to the queue. It never blocks.
+func (q *Queue) Enqueue(sessionID uint) {
+	q.mu.Lock()
+	q.pending = append(q.pending, sessionID)
+	q.mu.Unlock()
+
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+}
+
+// Shutdown stops the workers taking new jobs and waits for the running jobs
+// to finish. If ctx is done first, it cancels their contexts with
+// ErrShutdown, waits for the handlers to return and returns ctx.Err().
+// Jobs still waiting in the queue stay pending in the database and are
+// recovered by the next Start. Once the workers have stopped, every progress
+// subscription is closed.
+func (q *Queue) Shutdown(ctx context.Context) error {
+	q.stopOnce.Do(func() { close(q.stop) })
+	done := make(chan struct{})
+	go func() {
+		q.workers.Wait()
+		close(done)
+	}()
+
+	var err error
+	select {
+	case <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+		q.cancel(ErrShutdown)
+		<-done
+	}
+	q.finishAll()
+	return err
+}
+
+// next pops the oldest job, blocking until one is available. It returns
+// false once Shutdown has been called.
+func (q *Queue) next() (uint, bool) {
+	for {
+		select {
+		case <-q.stop:
+			return 0, false
+		default:
+		}
+
+		q.mu.Lock()
+		if len(q.pending) > 0 {
+			id := q.pending[0]
+			q.pending = q.pending[1:]
+			more := len(q.pending) > 0
+			q.mu.Unlock()
+			if more {
+				// Wake another worker for the remaining jobs
+				select {
+				case q.notify <- struct{}{}:
+				default:
+				}
+			}
+			return id, true
+		}
+		q.mu.Unlock()
+		select {
+		case <-q.notify:
+		case <-q.stop:
+		}
+	}
+}
+
+// work processes jobs until Shutdown is called.
+func (q *Queue) work() {
+	defer q.workers.Done()
+	for {
+		id, ok := q.next()
+		if !ok {
+			return
+		}
+		ctx, cancel := context.WithTimeout(q.ctx, q.timeout)
+		if err := q.handler(ctx, id); err != nil {
+			log.Printf("Analysis job for session %d failed: %v", id, err)
+		}
+		cancel()
+	}
+}
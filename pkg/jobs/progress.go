@@ -0,0 +1,137 @@
+package jobs
+
+// Delta is a change to the text generated for a session, as sent to
+// subscribers.
+type Delta struct {
+	// Text follows the text generated so far, or replaces it if Replace is set.
+	Text string
+
+	// Replace reports that the text generated so far was discarded, e.g.
+	// because the model failed part way and the local scorer took over.
+	Replace bool
+}
+
+// then returns the delta that has the effect of d followed by next.
+func (d Delta) then(next Delta) Delta {
+	if next.Replace {
+		return next
+	}
+	return Delta{Text: d.Text + next.Text, Replace: d.Replace}
+}
+
+// progress relays partial analysis output to subscribers while a job runs.
+// It keeps the text generated so far so late subscribers can catch up.
+type progress struct {
+	text string
+
+	// subscribers maps each subscription to the delta it has not been sent
+	// yet because its buffer was full.
+	subscribers map[chan Delta]Delta
+}
+
+// Publish appends a piece of generated text to a session's progress and
+// forwards it to every subscriber. Deltas for a subscriber whose buffer is
+// full are held back rather than blocking the worker, and sent along with the
+// next delta that fits. Text still held back when the job finishes is part of
+// the result subscribers fetch then.
+func (q *Queue) Publish(sessionID uint, delta string) {
+	q.publish(sessionID, Delta{Text: delta})
+}
+
+// Replace discards the text generated so far for a session in favour of
+// text, and tells every subscriber to do the same.
+func (q *Queue) Replace(sessionID uint, text string) {
+	q.publish(sessionID, Delta{Text: text, Replace: true})
+}
+
+func (q *Queue) publish(sessionID uint, delta Delta) {
+	q.progressMu.Lock()
+	defer q.progressMu.Unlock()
+
+	p := q.inProgress[sessionID]
+	if p == nil {
+		p = &progress{subscribers: map[chan Delta]Delta{}}
+		q.inProgress[sessionID] = p
+	}
+	p.text = Delta{Text: p.text}.then(delta).Text
+	for ch, missed := range p.subscribers {
+		select {
+		case ch <- missed.then(delta):
+			p.subscribers[ch] = Delta{}
+		default:
+			p.subscribers[ch] = missed.then(delta)
+		}
+	}
+}
+
+// Finish closes every subscription for a session and forgets its progress.
+// Workers call it once the analysis result has been saved.
+func (q *Queue) Finish(sessionID uint) {
+	q.progressMu.Lock()
+	defer q.progressMu.Unlock()
+
+	p := q.inProgress[sessionID]
+	if p == nil {
+		return
+	}
+	for ch := range p.subscribers {
+		close(ch)
+	}
+	delete(q.inProgress, sessionID)
+}
+
+// finishAll closes every subscription, e.g. of sessions whose jobs will not
+// run before the process exits.
+func (q *Queue) finishAll() {
+	q.progressMu.Lock()
+	defer q.progressMu.Unlock()
+
+	q.finished = true
+	for sessionID, p := range q.inProgress {
+		for ch := range p.subscribers {
+			close(ch)
+		}
+		delete(q.inProgress, sessionID)
+	}
+}
+
+// Subscribe returns the text generated so far for a session and a channel of
+// subsequent deltas, which add up to the rest of the text unless the job
+// finishes with some of them held back; a delta with Replace set starts the
+// text over. The channel is closed when the job finishes, or at once if the
+// queue has shut down. The returned cancel function must be called when the
+// subscriber goes away.
+func (q *Queue) Subscribe(sessionID uint) (string, <-chan Delta, func()) {
+	q.progressMu.Lock()
+	defer q.progressMu.Unlock()
+
+	if q.finished {
+		ch := make(chan Delta)
+		close(ch)
+		return "", ch, func() {}
+	}
+
+	p := q.inProgress[sessionID]
+	if p == nil {
+		p = &progress{subscribers: map[chan Delta]Delta{}}
+		q.inProgress[sessionID] = p
+	}
+	ch := make(chan Delta, 64)
+	p.subscribers[ch] = Delta{}
+
+	cancel := func() {
+		q.progressMu.Lock()
+		defer q.progressMu.Unlock()
+		if p, ok := q.inProgress[sessionID]; ok {
+			if _, ok := p.subscribers[ch]; ok {
+				delete(p.subscribers, ch)
+				close(ch)
+			}
+			// Drop entries created by a subscriber that arrived after the job finished
+			if len(p.subscribers) == 0 && p.text == "" {
+				delete(q.inProgress, sessionID)
+			}
+		}
+	}
+	return p.text, ch, cancel
+}
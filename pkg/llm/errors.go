@@ -0,0 +1,119 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Sentinel errors classifying provider failures.
+// Use errors.Is to test an error returned by a Provider against them.
+var (
+	// ErrRateLimit is returned for HTTP 429 responses.
+	ErrRateLimit = errors.New("llm: rate limit exceeded")
+
+	// ErrAuthentication is returned for HTTP 401 and 403 responses.
+	ErrAuthentication = errors.New("llm: authentication failed")
+
+	// ErrBadRequest is returned for other HTTP 4xx responses.
+	ErrBadRequest = errors.New("llm: bad request")
+
+	// ErrServer is returned for HTTP 5xx responses.
+	ErrServer = errors.New("llm: server error")
+
+	// ErrTimeout is returned when the request deadline is exceeded or the connection times out.
+	ErrTimeout = errors.New("llm: request timed out")
+)
+
+// APIError is an error response returned by a provider's API.
+// It unwraps to one of the sentinel errors according to its status code.
+type APIError struct {
+	// Provider is the name of the provider that returned the error.
+	Provider string
+
+	// StatusCode is the HTTP status code of the response.
+	StatusCode int
+
+	// Type is the error type reported by the provider (e.g., "invalid_request_error").
+	Type string
+
+	// Code is the provider specific error code, if any.
+	Code string
+
+	// Param is the request parameter the error relates to, if any.
+	Param string
+
+	// Message is the human readable error message.
+	Message string
+
+	// RetryAfter is the delay requested by the Retry-After header, or zero if absent.
+	RetryAfter time.Duration
+}
+
+// Error implements the error interface.
+func (e *APIError) Error() string {
+	msg := fmt.Sprintf("%s API request failed with status %d", e.Provider, e.StatusCode)
+	if e.Type != "" {
+		msg += " (" + e.Type + ")"
+	}
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	return msg
+}
+
+// Unwrap returns the sentinel error matching the status code.
+func (e *APIError) Unwrap() error {
+	switch {
+	case e.StatusCode == http.StatusTooManyRequests:
+		return ErrRateLimit
+	case e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden:
+		return ErrAuthentication
+	case e.StatusCode == http.StatusRequestTimeout || e.StatusCode == http.StatusGatewayTimeout:
+		return ErrTimeout
+	case e.StatusCode >= 500:
+		return ErrServer
+	default:
+		return ErrBadRequest
+	}
+}
+
+// Retryable reports whether an error is worth retrying: rate limits,
+// server errors and timeouts are, everything else is not.
+func Retryable(err error) bool {
+	return errors.Is(err, ErrRateLimit) || errors.Is(err, ErrServer) || errors.Is(err, ErrTimeout)
+}
+
+// ClassifyTransportError wraps errors from the HTTP client, marking timeouts with ErrTimeout.
+func ClassifyTransportError(err error) error {
+	if IsTimeout(err) {
+		return fmt.Errorf("%w: %w", ErrTimeout, err)
+	}
+	return fmt.Errorf("failed to send request: %w", err)
+}
+
+// IsTimeout reports whether err is a deadline or network timeout.
+func IsTimeout(err error) bool {
+	var netErr net.Error
+	return errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout())
+}
+
+// ParseRetryAfter parses a Retry-After header given either in seconds or as an HTTP date.
+func ParseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		if delay := time.Until(date); delay > 0 {
+			return delay
+		}
+	}
+	return 0
+}
@@ -0,0 +1,62 @@
+// Package llm defines the provider-neutral interface used to talk to large language models.
+package llm
+
+import (
+	"context"
+)
+
+// Provider generates chat completions from an LLM backend.
+// Implementations must be safe for concurrent use by multiple goroutines.
+type Provider interface {
+	// Name returns the identifier of the provider, e.g. "openai".
+	Name() string
+
+	// Complete sends the request and returns the whole completion at once.
+	Complete(ctx context.Context, request *Request) (*Response, error)
+
+	// Stream sends the request and calls onDelta with each piece of text as
+	// it is generated. It returns the complete response once the stream ends.
+	Stream(ctx context.Context, request *Request, onDelta func(string)) (*Response, error)
+}
+
+// Request is a provider-neutral chat completion request.
+type Request struct {
+	// Model overrides the provider's configured model if set.
+	Model string
+
+	// System is the system prompt. Providers place it wherever their API expects it.
+	System string
+
+	// Messages is the conversation so far, without the system prompt.
+	Messages []Message
+
+	// Temperature controls the randomness of the output.
+	Temperature float64
+
+	// MaxTokens is the maximum number of tokens to generate.
+	MaxTokens int
+
+	// ResponseFormat constrains the output to JSON if set.
+	ResponseFormat *ResponseFormat
+}
+
+// Message is a single message in a conversation.
+type Message struct {
+	// Role is the role of the message sender, either "user" or "assistant".
+	Role string
+
+	// Content is the text of the message.
+	Content string
+}
+
+// Response is a provider-neutral chat completion response.
+type Response struct {
+	// Content is the generated text.
+	Content string
+
+	// Model is the model that generated the response, as reported by the provider.
+	Model string
+
+	// FinishReason is the provider's reason for stopping generation, if reported.
+	FinishReason string
+}
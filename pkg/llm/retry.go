@@ -0,0 +1,105 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"math"
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+// RetryPolicy describes how requests failing with a rate limit, server error
+// or timeout are retried. Delays grow exponentially from InitialBackoff up to
+// MaxBackoff, unless the server asks for a specific delay with Retry-After.
+type RetryPolicy struct {
+	// MaxRetries is the number of retries after the first attempt.
+	MaxRetries int
+
+	// InitialBackoff is the delay before the first retry.
+	InitialBackoff time.Duration
+
+	// MaxBackoff caps the delay between retries, including Retry-After delays.
+	MaxBackoff time.Duration
+
+	// Multiplier is the factor the delay grows by after each retry.
+	Multiplier float64
+}
+
+// DefaultRetryPolicy returns the default retry policy:
+// 3 retries starting at 1 second, doubling up to 30 seconds.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxRetries:     3,
+		InitialBackoff: time.Second,
+		MaxBackoff:     30 * time.Second,
+		Multiplier:     2,
+	}
+}
+
+// Backoff returns the delay before retrying a request that failed with err
+// on the given zero-based attempt, and whether it should be retried at all.
+func (p RetryPolicy) Backoff(attempt int, err error) (time.Duration, bool) {
+	if attempt >= p.MaxRetries || !Retryable(err) {
+		return 0, false
+	}
+
+	var delay time.Duration
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.RetryAfter > 0 {
+		delay = apiErr.RetryAfter
+	} else {
+		multiplier := p.Multiplier
+		if multiplier < 1 {
+			multiplier = 1
+		}
+		delay = time.Duration(float64(p.InitialBackoff) * math.Pow(multiplier, float64(attempt)))
+		// Add up to 20% jitter so concurrent workers do not retry in lockstep
+		delay += time.Duration(rand.Int63n(int64(delay)/5 + 1))
+	}
+	if p.MaxBackoff > 0 && delay > p.MaxBackoff {
+		delay = p.MaxBackoff
+	}
+	return delay, true
+}
+
+// Send performs an HTTP request built by newRequest, retrying according to
+// the policy. Non-200 responses are turned into errors by parseError, which
+// should return an *APIError. The caller must close the returned response body.
+func Send(ctx context.Context, client *http.Client, policy RetryPolicy, newRequest func() (*http.Request, error), parseError func(*http.Response, []byte) error) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		req, err := newRequest()
+		if err != nil {
+			return nil, err
+		}
+
+		// Send the request
+		resp, err := client.Do(req)
+		if err != nil {
+			err = ClassifyTransportError(err)
+		} else if resp.StatusCode != http.StatusOK {
+			// Check for HTTP errors
+			respBody, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			err = parseError(resp, respBody)
+		} else {
+			return resp, nil
+		}
+
+		delay, retry := policy.Backoff(attempt, err)
+		if !retry || ctx.Err() != nil {
+			return nil, err
+		}
+		log.Printf("LLM request failed (attempt %d), retrying in %s: %v", attempt+1, delay, err)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ClassifyTransportError(ctx.Err())
+		}
+	}
+}
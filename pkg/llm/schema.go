@@ -0,0 +1,152 @@
+package llm
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"unicode/utf8"
+)
+
+// Response format types for Request.ResponseFormat.
+const (
+	// FormatJSONObject asks the model for any valid JSON object.
+	FormatJSONObject = "json_object"
+
+	// FormatJSONSchema asks the model for JSON matching ResponseFormat.Schema.
+	FormatJSONSchema = "json_schema"
+)
+
+// ResponseFormat constrains the output of the model.
+// Providers without native support fall back to instructions in the system prompt.
+type ResponseFormat struct {
+	// Type is FormatJSONObject or FormatJSONSchema.
+	Type string
+
+	// Name identifies the schema, as required by some APIs.
+	Name string
+
+	// Schema is the JSON schema the output must match.
+	// It is always set so output can be validated even in json_object mode.
+	Schema *Schema
+}
+
+// Schema is the subset of JSON Schema used to describe and validate model output.
+// It supports objects, arrays, strings, integers, numbers and booleans with
+// required properties, closed objects, numeric ranges and string lengths.
+type Schema struct {
+	Type                 string             `json:"type"`
+	Description          string             `json:"description,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *bool              `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+}
+
+// Validate decodes data and checks it against the schema.
+// It returns one message per problem found, each prefixed with a JSON path,
+// or nil if the data is valid.
+func (s *Schema) Validate(data []byte) []string {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return []string{fmt.Sprintf("$: invalid JSON: %v", err)}
+	}
+	if decoder.More() {
+		return []string{"$: unexpected data after the JSON value"}
+	}
+
+	var problems []string
+	s.validate("$", value, &problems)
+	return problems
+}
+
+// validate checks a decoded value, appending problems found at path.
+func (s *Schema) validate(path string, value interface{}, problems *[]string) {
+	report := func(format string, args ...interface{}) {
+		*problems = append(*problems, path+": "+fmt.Sprintf(format, args...))
+	}
+
+	switch s.Type {
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			report("expected an object")
+			return
+		}
+		for _, name := range s.Required {
+			if _, ok := object[name]; !ok {
+				report("missing required property %q", name)
+			}
+		}
+		// Sort keys so problems are reported in a stable order
+		keys := make([]string, 0, len(object))
+		for key := range object {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if property, ok := s.Properties[key]; ok {
+				property.validate(path+"."+key, object[key], problems)
+			} else if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+				report("unexpected property %q", key)
+			}
+		}
+	case "array":
+		array, ok := value.([]interface{})
+		if !ok {
+			report("expected an array")
+			return
+		}
+		if s.Items != nil {
+			for i, item := range array {
+				s.Items.validate(fmt.Sprintf("%s[%d]", path, i), item, problems)
+			}
+		}
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			report("expected a string")
+			return
+		}
+		length := utf8.RuneCountInString(str)
+		if s.MinLength != nil && length < *s.MinLength {
+			report("must be at least %d characters", *s.MinLength)
+		}
+		if s.MaxLength != nil && length > *s.MaxLength {
+			report("must be at most %d characters", *s.MaxLength)
+		}
+	case "integer", "number":
+		number, ok := value.(json.Number)
+		if !ok {
+			report("expected a %s", s.Type)
+			return
+		}
+		if s.Type == "integer" {
+			if _, err := number.Int64(); err != nil {
+				report("expected an integer")
+				return
+			}
+		}
+		f, err := number.Float64()
+		if err != nil {
+			report("expected a number")
+			return
+		}
+		if s.Minimum != nil && f < *s.Minimum {
+			report("must be at least %v", *s.Minimum)
+		}
+		if s.Maximum != nil && f > *s.Maximum {
+			report("must be at most %v", *s.Maximum)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			report("expected a boolean")
+		}
+	}
+}
@@ -2,42 +2,260 @@
 package models
 
 import (
+	"time"
+
 	"gorm.io/gorm"
 )
 
-// UserA represents User A's answers in the database.
-type UserA struct {
+// Session analysis statuses. A session has no status until it is ready to be
+// analysed, i.e. User B has submitted or the group is complete, after which it
+// moves through pending -> running -> done (or failed). A session in which a
+// participant erased their data is erased for good. See CanTransition.
+const (
+	SessionStatusPending = "pending"
+	SessionStatusRunning = "running"
+	SessionStatusDone    = "done"
+	SessionStatusFailed  = "failed"
+	SessionStatusErased  = "erased"
+)
+
+// sessionTransitions lists the statuses a session may move to from each status.
+var sessionTransitions = map[string][]string{
+	// User B submitted or the group is complete, or a participant erased their data
+	"": {SessionStatusPending, SessionStatusErased},
+	// A worker claimed the analysis, or User B revised their answers before it did
+	SessionStatusPending: {SessionStatusRunning, SessionStatusPending},
+	// The analysis finished, or was interrupted and waits for the next process
+	SessionStatusRunning: {SessionStatusDone, SessionStatusFailed, SessionStatusPending},
+	// User B revised their answers, or a participant erased their data
+	SessionStatusDone:   {SessionStatusPending, SessionStatusErased},
+	SessionStatusFailed: {SessionStatusPending, SessionStatusErased},
+	// Another participant erased their data
+	SessionStatusErased: {SessionStatusErased},
+}
+
+// CanTransition reports whether a session may move from status from to status to.
+func CanTransition(from, to string) bool {
+	for _, status := range sessionTransitions[from] {
+		if status == to {
+			return true
+		}
+	}
+	return false
+}
+
+// Session kinds.
+const (
+	SessionKindPair  = "pair"
+	SessionKindGroup = "group"
+)
+
+// Participant roles. In a pair session User A is the initiator and User B the
+// only member.
+const (
+	ParticipantRoleInitiator = "initiator"
+	ParticipantRoleMember    = "member"
+)
+
+// Capability token purposes. Invitation tokens let someone answer a session
+// and results tokens let a participant see its results. Legacy tokens are the
+// single token sessions had before tokens were split: they allow answering,
+// until someone has, and seeing the results, but not revising answers.
+const (
+	TokenPurposeInvite  = "invite"
+	TokenPurposeResults = "results"
+	TokenPurposeLegacy  = "legacy"
+)
+
+// Question types. Answers to choice and text questions are strings, answers
+// to scale and number questions are numbers, and answers to multi-select and
+// ranking questions are lists of options.
+const (
+	QuestionTypeSingleChoice = "single_choice"
+	QuestionTypeMultiSelect  = "multi_select"
+	QuestionTypeScale        = "scale"
+	QuestionTypeNumber       = "number"
+	QuestionTypeRanking      = "ranking"
+	QuestionTypeText         = "text"
+)
+
+// Session represents a Q&A session between two users, or a group session
+// between an initiator and the members they invited. Both kinds keep their
+// answers in Participants.
+type Session struct {
 	gorm.Model
-	Token        string `gorm:"uniqueIndex;size:255"`
-	Answers      string `gorm:"type:text"` // JSON string of answers
-	ShareAnswers bool
+	Kind            string          `gorm:"size:20"` // Session kind, see SessionKind*
+	GroupSize       int             // Number of members the initiator invited, group sessions only
+	Compatibility   int             // Compatibility score (0-100)
+	Summary         string          `gorm:"type:text;serializer:encrypted"` // AI-generated summary, encrypted at rest
+	Status          string          `gorm:"size:20;index"`                  // Analysis status, see SessionStatus*
+	AnalysisError   string          `gorm:"type:text"`                      // Last analysis error, if any
+	ErrorCode       string          `gorm:"size:50"`                        // Classification of AnalysisError shown to users
+	Engine          string          `gorm:"size:100"`                       // Engine that produced the result, "local" or "provider/model"
+	QuestionnaireID *uint           `gorm:"index"`                          // Questionnaire the session was started with
+	QuestionSetID   *uint           `gorm:"index"`                          // Question set the answers were given against, nil if none was published yet
+	QuestionScores  []QuestionScore // Per-question breakdown of the analysis
+	Participants    []Participant   // Everyone who answered, initiator first
+	PairScores      []PairScore     // Pairwise compatibility matrix, group sessions only
 }
 
-// UserB represents User B's answers in the database.
-type UserB struct {
+// Participant represents one person's answers in a session.
+type Participant struct {
 	gorm.Model
-	Token        string `gorm:"uniqueIndex;size:255"`
-	Answers      string `gorm:"type:text"` // JSON string of answers
+	SessionID    uint   `gorm:"index"`
+	Role         string `gorm:"size:20"`                        // Participant role, see ParticipantRole*
+	Name         string `gorm:"size:100"`                       // Display name shown in the group results, empty in pair sessions
+	Answers      string `gorm:"type:text;serializer:encrypted"` // JSON string of answers, encrypted at rest
 	ShareAnswers bool
+	Revisions    int        // Number of times the answers were revised after submitting them
+	ErasedAt     *time.Time // When the participant erased their answers, nil if never
 }
 
-// Session represents a Q&A session between two users.
-type Session struct {
+// PairScore is the compatibility of two participants in a group session.
+type PairScore struct {
+	gorm.Model
+	SessionID      uint   `gorm:"index"`
+	ParticipantAID uint   // The participant with the lower ID
+	ParticipantBID uint   // The participant with the higher ID
+	Score          int    // Compatibility of the two participants (0-100)
+	Note           string `gorm:"type:text"` // Short note on where the two agree or differ
+}
+
+// QuestionScore is the compatibility of a session's two users on a single question.
+type QuestionScore struct {
+	gorm.Model
+	SessionID  uint   `gorm:"index"`
+	QuestionID uint   // Zero if the answer did not match a known question
+	Question   string `gorm:"type:text"` // Question text at the time of the analysis
+	Score      int    // Compatibility on this question (0-100)
+	Note       string `gorm:"type:text"` // Short note on where the answers agree or differ
+}
+
+// Questionnaire is a named quiz, such as a friendship or a couple quiz, with
+// its own question bank and question sets.
+type Questionnaire struct {
 	gorm.Model
-	Token         string `gorm:"uniqueIndex;size:255"`
-	UserAID       uint
-	UserA         UserA
-	UserBID       *uint // Nullable since UserB might not have submitted yet
-	UserB         *UserB
-	Compatibility int    // Compatibility score (0-100)
-	Summary       string `gorm:"type:text"` // AI-generated summary
+	Slug        string `gorm:"uniqueIndex;size:100"` // Identifies the questionnaire in URLs and requests
+	Title       string `gorm:"size:255"`
+	Description string `gorm:"type:text"`
+	Language    string `gorm:"size:20"` // BCP 47 language tag of the questions, e.g. zh-CN
+	Theme       string `gorm:"size:50"` // Name of the frontend theme to show the questionnaire with
 }
 
-// Question represents a question in the Q&A application.
+// Question represents a question in a questionnaire's question bank.
+// Administrators edit the bank freely; users answer the questions of a
+// published QuestionSet. Questions are deleted for good, the question sets
+// keep their text.
 type Question struct {
 	gorm.Model
-	ID               uint   `json:"id" gorm:"primaryKey"`
-	QuestionText     string `json:"question" gorm:"type:text"`
-	IsMultipleChoice bool   `json:"isMultipleChoice"`
-	Options          string `json:"options" gorm:"type:text"` // JSON string of options
+	ID               uint     `json:"id" gorm:"primaryKey"`
+	QuestionnaireID  uint     `json:"-" gorm:"index"`
+	QuestionText     string   `json:"question" gorm:"type:text"`
+	Type             string   `json:"type" gorm:"size:20"` // Question type, see QuestionType*
+	Required         bool     `json:"required"`
+	IsMultipleChoice bool     `json:"isMultipleChoice"`         // Same as Type == QuestionTypeSingleChoice, kept for older clients
+	Options          string   `json:"options" gorm:"type:text"` // JSON string of options; the labels of each point for scale questions
+	Min              *float64 `json:"min"`                      // Lowest scale point or number, fewest selected options for multi-select
+	Max              *float64 `json:"max"`                      // Highest scale point or number, most selected options for multi-select
+	Position         int      `json:"position"`                 // Order of the question in the bank
+}
+
+// QuestionSet is an immutable version of a questionnaire's question bank,
+// published by an administrator. Sessions are pinned to the version their
+// answers were given against, so later edits to the bank do not change what
+// old answers mean.
+type QuestionSet struct {
+	gorm.Model
+	QuestionnaireID uint              `gorm:"uniqueIndex:idx_question_sets_questionnaire_version,priority:1"`
+	Version         int               `gorm:"uniqueIndex:idx_question_sets_questionnaire_version,priority:2"` // Numbered from 1 per questionnaire
+	PublishedBy     string            `gorm:"size:100"`                                                       // Username of the administrator who published it
+	Questions       []QuestionSetItem // Copies of the questions, in order
+}
+
+// QuestionSetItem is a copy of a question as it was when its QuestionSet was published.
+type QuestionSetItem struct {
+	ID               uint `gorm:"primaryKey"`
+	QuestionSetID    uint `gorm:"index"`
+	QuestionID       uint // ID of the question in the bank
+	Position         int
+	QuestionText     string `gorm:"type:text"`
+	Type             string `gorm:"size:20"`
+	Required         bool
+	IsMultipleChoice bool
+	Options          string `gorm:"type:text"` // JSON string of options
+	Min              *float64
+	Max              *float64
+}
+
+// AdminUser is an administrator allowed to manage the question bank.
+type AdminUser struct {
+	gorm.Model
+	Username     string `gorm:"uniqueIndex;size:100"`
+	PasswordHash string `gorm:"size:255"` // bcrypt hash of the password
+}
+
+// APIKey is a key an administrator uses to call the management endpoints from
+// scripts. Only a hash of the key is stored; deleting the row revokes it.
+type APIKey struct {
+	gorm.Model
+	AdminUserID uint       `gorm:"index"`
+	AdminUser   AdminUser  // The administrator the key acts as
+	Name        string     `gorm:"size:100"`            // Description of what the key is used for
+	Prefix      string     `gorm:"size:20"`             // First characters of the key, to tell keys apart
+	KeyHash     string     `gorm:"uniqueIndex;size:64"` // SHA-256 of the key, hex encoded
+	LastUsedAt  *time.Time // When the key was last used, nil if never
+}
+
+// AdminSession is a browser login of an administrator. Only a hash of the
+// session token is stored; deleting the row logs the session out.
+type AdminSession struct {
+	gorm.Model
+	AdminUserID uint      `gorm:"index"`
+	AdminUser   AdminUser // The administrator who logged in
+	TokenHash   string    `gorm:"uniqueIndex;size:64"` // SHA-256 of the session token, hex encoded
+	ExpiresAt   time.Time `gorm:"index"`
+}
+
+// IdempotentRequest is the response to a request sent with an
+// Idempotency-Key header, replayed when the request is retried with the same
+// key. StatusCode is zero while the request is being processed.
+type IdempotentRequest struct {
+	ID             uint   `gorm:"primaryKey"`
+	Endpoint       string `gorm:"uniqueIndex:idx_idempotent_requests_endpoint_key,priority:1;size:100"` // Method and path, e.g. "POST /api/submit-user-b"
+	IdempotencyKey string `gorm:"uniqueIndex:idx_idempotent_requests_endpoint_key,priority:2;size:255"` // SHA-256 of the key scoped to the caller, hex encoded
+	RequestHash    string `gorm:"size:64"`                                                              // SHA-256 of the request body, hex encoded
+	StatusCode     int
+	Response       string    `gorm:"type:text"` // Response body, encrypted with the key scoped to the caller
+	SessionID      *uint     `gorm:"index"`     // Session the tokens in the response belong to, nil if there are none
+	CreatedAt      time.Time `gorm:"index"`
+}
+
+// SessionToken is a capability token granting access to a session. Only a
+// hash of the token is stored; deleting the row revokes it.
+type SessionToken struct {
+	gorm.Model
+	SessionID     uint       `gorm:"index"`
+	ParticipantID *uint      // Participant whose results the token shows, nil for invitation and legacy tokens
+	Purpose       string     `gorm:"size:20"`             // Token purpose, see TokenPurpose*
+	TokenHash     string     `gorm:"uniqueIndex;size:64"` // SHA-256 of the token, hex encoded
+	ExpiresAt     *time.Time // When the token stops working, nil if never
+}
+
+// Reasons for purging a session under the retention policy.
+const (
+	PurgeReasonInviteExpired  = "invite_expired"  // Nobody answered the invitation in time
+	PurgeReasonResultsExpired = "results_expired" // The results were kept as long as allowed
+)
+
+// PurgeRecord is the audit record of a session deleted under the retention
+// policy. It keeps none of the answers or results, only which session was
+// deleted, when and why.
+type PurgeRecord struct {
+	ID               uint      `gorm:"primaryKey"`
+	CreatedAt        time.Time `gorm:"index"` // When the session was deleted
+	SessionID        uint      `gorm:"index"`
+	SessionKind      string    `gorm:"size:20"`
+	SessionCreatedAt time.Time
+	Reason           string `gorm:"size:30"` // Why the session was deleted, see PurgeReason*
+	Trigger          string `gorm:"size:20"` // What deleted it, "sweeper" or "cli"
 }
@@ -0,0 +1,201 @@
+// Package ollama provides a client for the Ollama chat API served by local model runners.
+package ollama
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"openai-api/pkg/llm"
+)
+
+// Client is an Ollama API client.
+// It is safe for concurrent use by multiple goroutines.
+type Client struct {
+	// config holds the client's configuration.
+	config *Config
+
+	// httpClient is the HTTP client used to send requests.
+	httpClient *http.Client
+}
+
+// Client implements llm.Provider.
+var _ llm.Provider = (*Client)(nil)
+
+// NewClient creates a new Ollama API client with the given config.
+func NewClient(config *Config) *Client {
+	return &Client{
+		config:     config,
+		httpClient: &http.Client{},
+	}
+}
+
+// Name returns "ollama".
+func (c *Client) Name() string {
+	return "ollama"
+}
+
+// Chat sends a non-streaming request to /api/chat.
+func (c *Client) Chat(ctx context.Context, request *ChatRequest) (*ChatResponse, error) {
+	request.Stream = false
+	resp, err := c.send(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var response ChatResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+	return &response, nil
+}
+
+// Complete sends a provider-neutral request to /api/chat.
+func (c *Client) Complete(ctx context.Context, request *llm.Request) (*llm.Response, error) {
+	response, err := c.Chat(ctx, c.toChatRequest(request))
+	if err != nil {
+		return nil, err
+	}
+	return &llm.Response{
+		Content:      response.Message.Content,
+		Model:        response.Model,
+		FinishReason: response.DoneReason,
+	}, nil
+}
+
+// Stream sends a provider-neutral request to /api/chat and relays each piece of text to onDelta.
+func (c *Client) Stream(ctx context.Context, request *llm.Request, onDelta func(string)) (*llm.Response, error) {
+	chatRequest := c.toChatRequest(request)
+	chatRequest.Stream = true
+	resp, err := c.send(ctx, chatRequest)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var builder strings.Builder
+	response := &llm.Response{}
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var chunk ChatResponse
+		if err := json.Unmarshal(line, &chunk); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal stream chunk: %w", err)
+		}
+		if chunk.Error != "" {
+			return nil, &llm.APIError{Provider: "ollama", StatusCode: http.StatusInternalServerError, Message: chunk.Error}
+		}
+
+		response.Model = chunk.Model
+		builder.WriteString(chunk.Message.Content)
+		if chunk.Message.Content != "" && onDelta != nil {
+			onDelta(chunk.Message.Content)
+		}
+		if chunk.Done {
+			response.FinishReason = chunk.DoneReason
+			break
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		if llm.IsTimeout(err) {
+			return nil, fmt.Errorf("%w: %w", llm.ErrTimeout, err)
+		}
+		return nil, fmt.Errorf("failed to read Ollama stream: %w", err)
+	}
+
+	response.Content = builder.String()
+	return response, nil
+}
+
+// send posts a chat request, retrying according to the retry policy.
+func (c *Client) send(ctx context.Context, request *ChatRequest) (*http.Response, error) {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	// Determine the API endpoint
+	apiBase := c.config.APIBase
+	if apiBase == "" {
+		apiBase = "http://localhost:11434"
+	}
+	url := fmt.Sprintf("%s/api/chat", strings.TrimSuffix(apiBase, "/"))
+
+	return llm.Send(ctx, c.httpClient, c.config.RetryPolicy, func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+
+		// Set headers
+		req.Header.Set("Content-Type", "application/json")
+		if c.config.APIKey != "" {
+			req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.config.APIKey))
+		}
+		return req, nil
+	}, newAPIError)
+}
+
+// toChatRequest converts a provider-neutral request to the Ollama format.
+func (c *Client) toChatRequest(request *llm.Request) *ChatRequest {
+	model := request.Model
+	if model == "" {
+		model = c.config.Model
+	}
+
+	var messages []Message
+	if request.System != "" {
+		messages = append(messages, Message{Role: "system", Content: request.System})
+	}
+	for _, message := range request.Messages {
+		messages = append(messages, Message{Role: message.Role, Content: message.Content})
+	}
+
+	chatRequest := &ChatRequest{
+		Model:    model,
+		Messages: messages,
+	}
+	if request.Temperature != 0 || request.MaxTokens != 0 {
+		chatRequest.Options = &Options{
+			Temperature: request.Temperature,
+			NumPredict:  request.MaxTokens,
+		}
+	}
+	if format := request.ResponseFormat; format != nil {
+		if format.Type == llm.FormatJSONSchema && format.Schema != nil {
+			chatRequest.Format = format.Schema
+		} else {
+			chatRequest.Format = "json"
+		}
+	}
+	return chatRequest
+}
+
+// newAPIError builds an llm.APIError from a non-200 response and its body.
+func newAPIError(resp *http.Response, body []byte) error {
+	apiErr := &llm.APIError{
+		Provider:   "ollama",
+		StatusCode: resp.StatusCode,
+		RetryAfter: llm.ParseRetryAfter(resp.Header.Get("Retry-After")),
+	}
+
+	var parsed struct {
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(body, &parsed); err == nil && parsed.Error != "" {
+		apiErr.Message = parsed.Error
+	} else {
+		apiErr.Message = strings.TrimSpace(string(body))
+	}
+	return apiErr
+}
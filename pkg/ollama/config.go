@@ -0,0 +1,57 @@
+// Package ollama provides a client for the Ollama chat API served by local model runners.
+package ollama
+
+import (
+	"openai-api/pkg/llm"
+)
+
+// Config holds the configuration for the Ollama client.
+type Config struct {
+	// APIBase is the base URL of the Ollama server.
+	// If not set, it defaults to "http://localhost:11434".
+	APIBase string
+
+	// APIKey is sent as a bearer token if set, for servers behind an authenticating proxy.
+	APIKey string
+
+	// Model is the default model used when a request does not name one.
+	Model string
+
+	// RetryPolicy controls how failed requests are retried.
+	// The zero value disables retries.
+	RetryPolicy llm.RetryPolicy
+}
+
+// NewConfig creates a new Config with default values: the local server
+// without a bearer token and llm.DefaultRetryPolicy.
+func NewConfig() *Config {
+	return &Config{
+		RetryPolicy: llm.DefaultRetryPolicy(),
+	}
+}
+
+// WithAPIBase sets the base URL of the Ollama server.
+// If not set, it defaults to "http://localhost:11434".
+func (c *Config) WithAPIBase(apiBase string) *Config {
+	c.APIBase = apiBase
+	return c
+}
+
+// WithAPIKey sets the bearer token sent to the Ollama server.
+func (c *Config) WithAPIKey(apiKey string) *Config {
+	c.APIKey = apiKey
+	return c
+}
+
+// WithModel sets the default model for the Config.
+func (c *Config) WithModel(model string) *Config {
+	c.Model = model
+	return c
+}
+
+// WithRetryPolicy sets the retry policy for the Config.
+// Pass llm.RetryPolicy{} to disable retries.
+func (c *Config) WithRetryPolicy(policy llm.RetryPolicy) *Config {
+	c.RetryPolicy = policy
+	return c
+}
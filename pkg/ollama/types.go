@@ -0,0 +1,62 @@
+// Package ollama provides a client for the Ollama chat API served by local model runners.
+package ollama
+
+// ChatRequest represents a request to the /api/chat endpoint.
+type ChatRequest struct {
+	// Model is the name of the model to use.
+	Model string `json:"model"`
+
+	// Messages is the conversation so far, including the system prompt.
+	Messages []Message `json:"messages"`
+
+	// Stream indicates whether to stream the response as newline-delimited JSON.
+	// The server streams by default, so it is always sent.
+	Stream bool `json:"stream"`
+
+	// Options holds model parameters such as the temperature.
+	Options *Options `json:"options,omitempty"`
+
+	// Format is either the string "json" or a JSON schema object the output must match.
+	Format interface{} `json:"format,omitempty"`
+}
+
+// Message represents a single message in a conversation.
+type Message struct {
+	// Role is "system", "user" or "assistant".
+	Role string `json:"role"`
+
+	// Content is the text of the message.
+	Content string `json:"content"`
+}
+
+// Options holds model parameters.
+type Options struct {
+	// Temperature controls the randomness of the output.
+	Temperature float64 `json:"temperature,omitempty"`
+
+	// NumPredict is the maximum number of tokens to generate.
+	NumPredict int `json:"num_predict,omitempty"`
+}
+
+// ChatResponse represents a response from /api/chat.
+// When streaming, every line of the body is a ChatResponse carrying the next
+// piece of the message, and the last one has Done set.
+type ChatResponse struct {
+	// Model is the name of the model that generated the response.
+	Model string `json:"model"`
+
+	// CreatedAt is the time the response was generated.
+	CreatedAt string `json:"created_at"`
+
+	// Message is the generated message, or the next piece of it when streaming.
+	Message Message `json:"message"`
+
+	// Done is true on the final response.
+	Done bool `json:"done"`
+
+	// DoneReason is the reason generation stopped, e.g. "stop" or "length".
+	DoneReason string `json:"done_reason,omitempty"`
+
+	// Error is set if the server failed after the stream started.
+	Error string `json:"error,omitempty"`
+}
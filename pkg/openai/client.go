@@ -8,10 +8,9 @@ import (
 	"fmt"
 	"io"
 	"net/http"
-	"os"
-)
 
-var SystemPrompt string = loadSystemPrompt()
+	"openai-api/pkg/llm"
+)
 
 // Client is an OpenAI API client.
 // It is safe for concurrent use by multiple goroutines.
@@ -32,27 +31,49 @@ func NewClient(config *Config) *Client {
 	}
 }
 
-func loadSystemPrompt() string {
-	// 使用os.ReadFile读取整个文件内容
-	content, err := os.ReadFile("system_prompt.txt")
-	if err != nil {
-		panic(err)
-	}
-	return string(content)
-}
-
 // ChatCompletion sends a chat completion request to the OpenAI API.
 // It takes a context for request cancellation and a ChatCompletionRequest.
 // It returns a ChatCompletionResponse and an error if the request fails.
-// The method automatically adds the system prompt if configured.
+// The method adds the configured system prompt unless the request already
+// starts with a system message.
 func (c *Client) ChatCompletion(ctx context.Context, request *ChatCompletionRequest) (*ChatCompletionResponse, error) {
-	// Add system prompt if configured
-	if c.config.SystemPrompt != "" {
+	// Send the request
+	resp, err := c.send(ctx, request, "application/json")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	// Read the response body
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	// Parse the response
+	var response ChatCompletionResponse
+	if err := json.Unmarshal(respBody, &response); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+
+	return &response, nil
+}
+
+// send posts a chat completion request and returns the successful response.
+// It prepends the configured system prompt to the messages, unless they
+// already start with a system message such as llm.Request.System, and retries
+// rate limits, server errors and timeouts according to the retry policy.
+// Errors are classified with the sentinel errors of the llm package.
+func (c *Client) send(ctx context.Context, request *ChatCompletionRequest, accept string) (*http.Response, error) {
+	// Add system prompt if configured, leaving the caller's request as it is
+	if c.config.SystemPrompt != "" && (len(request.Messages) == 0 || request.Messages[0].Role != "system") {
 		systemMessage := Message{
 			Role:    "system",
 			Content: c.config.SystemPrompt,
 		}
-		request.Messages = append([]Message{systemMessage}, request.Messages...)
+		withSystem := *request
+		withSystem.Messages = append([]Message{systemMessage}, request.Messages...)
+		request = &withSystem
 	}
 
 	// Prepare the request body
@@ -69,44 +90,17 @@ func (c *Client) ChatCompletion(ctx context.Context, request *ChatCompletionRequ
 
 	url := fmt.Sprintf("%s/chat/completions", apiBase)
 
-	// Create the HTTP request
-	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(body))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-
-	// Set headers
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.config.APIKey))
-
-	fmt.Printf("Sending request to %s with body: %s\n", url, string(body))
-	// Send the request
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	// Read the response body
-	respBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %w", err)
-	}
-
-	// Check for HTTP errors
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(respBody))
-	}
-
-	if bytes.Contains(respBody, []byte("```")) {
-		respBody = bytes.ReplaceAll(respBody, []byte("```json"), []byte(""))
-		respBody = bytes.ReplaceAll(respBody, []byte("```"), []byte(""))
-	}
-	// Parse the response
-	var response ChatCompletionResponse
-	if err := json.Unmarshal(respBody, &response); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
-	}
+	return llm.Send(ctx, c.httpClient, c.config.RetryPolicy, func() (*http.Request, error) {
+		// Create the HTTP request
+		req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
 
-	return &response, nil
+		// Set headers
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept", accept)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.config.APIKey))
+		return req, nil
+	}, newAPIError)
 }
@@ -0,0 +1,87 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"openai-api/pkg/llm"
+)
+
+// newTestClient returns a client of a server that answers every chat
+// completion with "ok", recording the messages of each request in sent.
+func newTestClient(t *testing.T, systemPrompt string, sent *[][]Message) *Client {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request ChatCompletionRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("decoding request: %v", err)
+		}
+		*sent = append(*sent, request.Messages)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"model":"test","choices":[{"index":0,"message":{"role":"assistant","content":"ok"},"finish_reason":"stop"}]}`))
+	}))
+	t.Cleanup(server.Close)
+	return NewClient(NewConfig().WithAPIBase(server.URL).WithSystemPrompt(systemPrompt))
+}
+
+func TestSystemPrompt(t *testing.T) {
+	tests := []struct {
+		name         string
+		systemPrompt string
+		request      llm.Request
+		want         []Message
+	}{
+		{
+			name:         "configured prompt",
+			systemPrompt: "configured",
+			request:      llm.Request{Messages: []llm.Message{{Role: "user", Content: "hi"}}},
+			want:         []Message{{Role: "system", Content: "configured"}, {Role: "user", Content: "hi"}},
+		},
+		{
+			name:         "request prompt takes precedence",
+			systemPrompt: "configured",
+			request:      llm.Request{System: "request", Messages: []llm.Message{{Role: "user", Content: "hi"}}},
+			want:         []Message{{Role: "system", Content: "request"}, {Role: "user", Content: "hi"}},
+		},
+		{
+			name:    "no prompt",
+			request: llm.Request{Messages: []llm.Message{{Role: "user", Content: "hi"}}},
+			want:    []Message{{Role: "user", Content: "hi"}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var sent [][]Message
+			client := newTestClient(t, test.systemPrompt, &sent)
+			if _, err := client.Complete(context.Background(), &test.request); err != nil {
+				t.Fatal(err)
+			}
+			if len(sent) != 1 || !reflect.DeepEqual(sent[0], test.want) {
+				t.Errorf("sent %+v, want %+v", sent, test.want)
+			}
+		})
+	}
+}
+
+func TestSystemPromptLeavesRequest(t *testing.T) {
+	var sent [][]Message
+	client := newTestClient(t, "configured", &sent)
+	request := &ChatCompletionRequest{Messages: []Message{{Role: "user", Content: "hi"}}}
+	for i := 0; i < 2; i++ {
+		if _, err := client.ChatCompletion(context.Background(), request); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(request.Messages) != 1 {
+		t.Errorf("request has %d messages after sending it, want 1", len(request.Messages))
+	}
+	for i, messages := range sent {
+		if len(messages) != 2 {
+			t.Errorf("request %d sent %d messages, want 2", i+1, len(messages))
+		}
+	}
+}
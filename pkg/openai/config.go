@@ -2,7 +2,7 @@
 package openai
 
 import (
-	"os"
+	"openai-api/pkg/llm"
 )
 
 // Config holds the configuration for the OpenAI client.
@@ -17,20 +17,24 @@ type Config struct {
 	APIBase string
 
 	// SystemPrompt is the system prompt to use for chat completions.
-	// If set, it will be prepended to the messages list as a system message.
+	// If set, it will be prepended to the messages list as a system message,
+	// unless the list already starts with one.
 	SystemPrompt string
+
+	// Model is the default model used when a request does not name one.
+	Model string
+
+	// RetryPolicy controls how failed requests are retried.
+	// The zero value disables retries.
+	RetryPolicy llm.RetryPolicy
 }
 
-// NewConfig creates a new Config with default values.
-// It reads API_KEY and API_BASE from environment variables if available.
-// The environment variables are:
-// - OPENAI_API_KEY for the API key
-// - OPENAI_API_BASE for the base URL
+// NewConfig creates a new Config with default values: no API key, the
+// default base URL and llm.DefaultRetryPolicy.
+// Set the API key and other settings with the With methods.
 func NewConfig() *Config {
 	return &Config{
-		APIKey:  os.Getenv("OPENAI_API_KEY"),
-		APIBase: os.Getenv("OPENAI_API_BASE"),
-		// SystemPrompt can be set later or via environment variable if needed.
+		RetryPolicy: llm.DefaultRetryPolicy(),
 	}
 }
 
@@ -50,8 +54,23 @@ func (c *Config) WithAPIBase(apiBase string) *Config {
 }
 
 // WithSystemPrompt sets the system prompt for the Config.
-// This prompt will be prepended to the messages list as a system message.
+// This prompt will be prepended to the messages list as a system message,
+// unless the list already starts with one.
 func (c *Config) WithSystemPrompt(systemPrompt string) *Config {
 	c.SystemPrompt = systemPrompt
 	return c
 }
+
+// WithModel sets the default model for the Config.
+// It is used by Complete and Stream when the request does not name a model.
+func (c *Config) WithModel(model string) *Config {
+	c.Model = model
+	return c
+}
+
+// WithRetryPolicy sets the retry policy for the Config.
+// Pass llm.RetryPolicy{} to disable retries.
+func (c *Config) WithRetryPolicy(policy llm.RetryPolicy) *Config {
+	c.RetryPolicy = policy
+	return c
+}
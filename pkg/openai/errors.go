@@ -0,0 +1,44 @@
+// Package openai provides an OpenAI-compatible API interface.
+package openai
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"openai-api/pkg/llm"
+)
+
+// errorResponse is the error body returned by OpenAI-compatible APIs.
+type errorResponse struct {
+	Error struct {
+		Message string          `json:"message"`
+		Type    string          `json:"type"`
+		Param   string          `json:"param"`
+		Code    json.RawMessage `json:"code"`
+	} `json:"error"`
+}
+
+// newAPIError builds an llm.APIError from a non-200 response and its body.
+func newAPIError(resp *http.Response, body []byte) error {
+	apiErr := &llm.APIError{
+		Provider:   "openai",
+		StatusCode: resp.StatusCode,
+		RetryAfter: llm.ParseRetryAfter(resp.Header.Get("Retry-After")),
+	}
+
+	var parsed errorResponse
+	if err := json.Unmarshal(body, &parsed); err == nil && parsed.Error.Message != "" {
+		apiErr.Type = parsed.Error.Type
+		apiErr.Param = parsed.Error.Param
+		apiErr.Message = parsed.Error.Message
+		// The code is a string in OpenAI responses but a number in some compatible APIs
+		apiErr.Code = strings.Trim(string(parsed.Error.Code), `"`)
+		if apiErr.Code == "null" {
+			apiErr.Code = ""
+		}
+	} else {
+		apiErr.Message = strings.TrimSpace(string(body))
+	}
+	return apiErr
+}
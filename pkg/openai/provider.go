@@ -0,0 +1,114 @@
+// Package openai provides an OpenAI-compatible API interface.
+package openai
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"openai-api/pkg/llm"
+)
+
+// Client implements llm.Provider for OpenAI-compatible chat completion APIs.
+var _ llm.Provider = (*Client)(nil)
+
+// Name returns "openai".
+func (c *Client) Name() string {
+	return "openai"
+}
+
+// Complete sends a provider-neutral request as a chat completion.
+func (c *Client) Complete(ctx context.Context, request *llm.Request) (*llm.Response, error) {
+	response, err := c.ChatCompletion(ctx, c.toChatCompletionRequest(request))
+	if err != nil {
+		return nil, err
+	}
+
+	// Check if we have a response
+	if len(response.Choices) == 0 {
+		return nil, fmt.Errorf("no choices in OpenAI response")
+	}
+	return &llm.Response{
+		Content:      response.Choices[0].Message.Content,
+		Model:        response.Model,
+		FinishReason: response.Choices[0].FinishReason,
+	}, nil
+}
+
+// Stream sends a provider-neutral request as a streamed chat completion.
+func (c *Client) Stream(ctx context.Context, request *llm.Request, onDelta func(string)) (*llm.Response, error) {
+	stream, err := c.ChatCompletionStream(ctx, c.toChatCompletionRequest(request))
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Close()
+
+	var builder strings.Builder
+	response := &llm.Response{}
+	received := false
+	for {
+		chunk, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read OpenAI stream: %w", err)
+		}
+		response.Model = chunk.Model
+		for _, choice := range chunk.Choices {
+			if choice.Index != 0 {
+				continue
+			}
+			received = true
+			builder.WriteString(choice.Delta.Content)
+			if choice.Delta.Content != "" && onDelta != nil {
+				onDelta(choice.Delta.Content)
+			}
+			if choice.FinishReason != "" {
+				response.FinishReason = choice.FinishReason
+			}
+		}
+	}
+
+	// Check if we have a response
+	if !received {
+		return nil, fmt.Errorf("no choices in OpenAI response")
+	}
+	response.Content = builder.String()
+	return response, nil
+}
+
+// toChatCompletionRequest converts a provider-neutral request to the OpenAI format.
+func (c *Client) toChatCompletionRequest(request *llm.Request) *ChatCompletionRequest {
+	model := request.Model
+	if model == "" {
+		model = c.config.Model
+	}
+
+	var messages []Message
+	if request.System != "" {
+		messages = append(messages, Message{Role: "system", Content: request.System})
+	}
+	for _, message := range request.Messages {
+		messages = append(messages, Message{Role: message.Role, Content: message.Content})
+	}
+
+	chatRequest := &ChatCompletionRequest{
+		Model:       model,
+		Messages:    messages,
+		Temperature: request.Temperature,
+		MaxTokens:   request.MaxTokens,
+	}
+	if format := request.ResponseFormat; format != nil {
+		chatRequest.ResponseFormat = &ResponseFormat{Type: format.Type}
+		if format.Type == llm.FormatJSONSchema {
+			chatRequest.ResponseFormat.JSONSchema = &JSONSchema{
+				Name:   format.Name,
+				Schema: format.Schema,
+				Strict: true,
+			}
+		}
+	}
+	return chatRequest
+}
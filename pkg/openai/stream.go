@@ -0,0 +1,79 @@
+// Package openai provides an OpenAI-compatible API interface.
+package openai
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"openai-api/pkg/llm"
+)
+
+// ChatCompletionStream reads the server-sent events of a streamed chat completion.
+// It is not safe for concurrent use.
+type ChatCompletionStream struct {
+	// body is the HTTP response body carrying the event stream.
+	body io.ReadCloser
+
+	// reader reads the event stream line by line.
+	reader *bufio.Reader
+}
+
+// ChatCompletionStream sends a streaming chat completion request to the OpenAI API.
+// The request's Stream field is forced to true.
+// Call Recv on the returned stream until it returns io.EOF, then Close it.
+func (c *Client) ChatCompletionStream(ctx context.Context, request *ChatCompletionRequest) (*ChatCompletionStream, error) {
+	request.Stream = true
+
+	// Send the request
+	resp, err := c.send(ctx, request, "text/event-stream")
+	if err != nil {
+		return nil, err
+	}
+
+	return &ChatCompletionStream{
+		body:   resp.Body,
+		reader: bufio.NewReader(resp.Body),
+	}, nil
+}
+
+// Recv returns the next chunk of the stream.
+// It returns io.EOF once the server sends the [DONE] marker or closes the stream.
+func (s *ChatCompletionStream) Recv() (*ChatCompletionChunk, error) {
+	for {
+		line, err := s.reader.ReadBytes('\n')
+		if err != nil && (err != io.EOF || len(line) == 0) {
+			if llm.IsTimeout(err) {
+				return nil, fmt.Errorf("%w: %w", llm.ErrTimeout, err)
+			}
+			return nil, err
+		}
+
+		// Only "data:" fields carry payloads; skip comments, event names and blank lines
+		line = bytes.TrimSpace(line)
+		if !bytes.HasPrefix(line, []byte("data:")) {
+			if err == io.EOF {
+				return nil, io.EOF
+			}
+			continue
+		}
+		data := bytes.TrimSpace(bytes.TrimPrefix(line, []byte("data:")))
+		if bytes.Equal(data, []byte("[DONE]")) {
+			return nil, io.EOF
+		}
+
+		var chunk ChatCompletionChunk
+		if err := json.Unmarshal(data, &chunk); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal stream chunk: %w", err)
+		}
+		return &chunk, nil
+	}
+}
+
+// Close releases the underlying HTTP connection.
+func (s *ChatCompletionStream) Close() error {
+	return s.body.Close()
+}
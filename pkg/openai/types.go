@@ -41,6 +41,34 @@ type ChatCompletionRequest struct {
 
 	// User is a unique identifier representing your end-user, which can help OpenAI to monitor and detect abuse.
 	User string `json:"user,omitempty"`
+
+	// ResponseFormat forces the model to output JSON, optionally matching a schema.
+	ResponseFormat *ResponseFormat `json:"response_format,omitempty"`
+}
+
+// ResponseFormat specifies the format the model must output.
+type ResponseFormat struct {
+	// Type is "text", "json_object" or "json_schema".
+	Type string `json:"type"`
+
+	// JSONSchema describes the expected output when Type is "json_schema".
+	JSONSchema *JSONSchema `json:"json_schema,omitempty"`
+}
+
+// JSONSchema is a named JSON schema for structured outputs.
+type JSONSchema struct {
+	// Name identifies the schema.
+	Name string `json:"name"`
+
+	// Description tells the model what the output is for.
+	Description string `json:"description,omitempty"`
+
+	// Schema is the JSON schema object.
+	Schema interface{} `json:"schema"`
+
+	// Strict enables strict schema adherence.
+	// It requires every property to be required and objects to be closed.
+	Strict bool `json:"strict,omitempty"`
 }
 
 // Message represents a single message in a chat conversation.
@@ -99,3 +127,43 @@ type Usage struct {
 	// TotalTokens is the total number of tokens used (prompt + completion).
 	TotalTokens int `json:"total_tokens"`
 }
+
+// ChatCompletionChunk represents a single server-sent event chunk of a streamed chat completion.
+type ChatCompletionChunk struct {
+	// ID is a unique identifier for the chat completion. Every chunk has the same ID.
+	ID string `json:"id"`
+
+	// Object is the type of object returned (e.g., "chat.completion.chunk").
+	Object string `json:"object"`
+
+	// Created is the Unix timestamp (in seconds) of when the chat completion was created.
+	Created int64 `json:"created"`
+
+	// Model is the name of the model used to generate the completion.
+	Model string `json:"model"`
+
+	// Choices is a list of chat completion choice deltas.
+	Choices []ChunkChoice `json:"choices"`
+}
+
+// ChunkChoice represents a single choice delta in a streamed chat completion chunk.
+type ChunkChoice struct {
+	// Index is the index of the choice in the list of choices.
+	Index int `json:"index"`
+
+	// Delta is the part of the message generated since the previous chunk.
+	Delta MessageDelta `json:"delta"`
+
+	// FinishReason is the reason the model stopped generating tokens.
+	// It is empty on every chunk except the last one for this choice.
+	FinishReason string `json:"finish_reason"`
+}
+
+// MessageDelta represents an incremental update to a message in a streamed response.
+type MessageDelta struct {
+	// Role is only set on the first chunk of a message.
+	Role string `json:"role,omitempty"`
+
+	// Content is the newly generated text.
+	Content string `json:"content,omitempty"`
+}
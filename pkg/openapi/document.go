@@ -0,0 +1,133 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// document is the part of an OpenAPI document that the client generator and
+// the drift tests use. Paths, operations and properties keep the order of
+// the document so generated code is stable.
+type document struct {
+	Paths      ordered[ordered[*operation]] `json:"paths"`
+	Components struct {
+		Schemas ordered[*schema] `json:"schemas"`
+	} `json:"components"`
+}
+
+// operation describes one method of a path.
+type operation struct {
+	OperationID string                `json:"operationId"`
+	Summary     string                `json:"summary"`
+	Description string                `json:"description"`
+	Parameters  []parameter           `json:"parameters"`
+	RequestBody *requestBody          `json:"requestBody"`
+	Responses   ordered[*response]    `json:"responses"`
+	Security    []map[string][]string `json:"security"`
+}
+
+// parameter is a path or query parameter.
+type parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Required    bool    `json:"required"`
+	Description string  `json:"description"`
+	Schema      *schema `json:"schema"`
+}
+
+type requestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]mediaType `json:"content"`
+}
+
+type response struct {
+	Description string               `json:"description"`
+	Content     map[string]mediaType `json:"content"`
+}
+
+type mediaType struct {
+	Schema *schema `json:"schema"`
+}
+
+// schema is a JSON schema. GoType names the Go type a component schema
+// mirrors, as package.Type.
+type schema struct {
+	Ref                  string           `json:"$ref"`
+	Type                 string           `json:"type"`
+	Format               string           `json:"format"`
+	Description          string           `json:"description"`
+	Nullable             bool             `json:"nullable"`
+	Enum                 []string         `json:"enum"`
+	Items                *schema          `json:"items"`
+	Properties           ordered[*schema] `json:"properties"`
+	Required             []string         `json:"required"`
+	AdditionalProperties *schema          `json:"additionalProperties"`
+	OneOf                []*schema        `json:"oneOf"`
+	GoType               string           `json:"x-go-type"`
+}
+
+// refName returns the name of the component schema s refers to, or "".
+func (s *schema) refName() string {
+	return strings.TrimPrefix(s.Ref, "#/components/schemas/")
+}
+
+// isRequired reports whether name is a required property of s.
+func (s *schema) isRequired(name string) bool {
+	for _, required := range s.Required {
+		if required == name {
+			return true
+		}
+	}
+	return false
+}
+
+// parseDocument decodes an OpenAPI document.
+func parseDocument(spec []byte) (*document, error) {
+	var doc document
+	if err := json.Unmarshal(spec, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse OpenAPI document: %w", err)
+	}
+	return &doc, nil
+}
+
+// ordered is a JSON object decoded into a list of its members, in order.
+type ordered[T any] []member[T]
+
+type member[T any] struct {
+	Key   string
+	Value T
+}
+
+// get returns the value of a member.
+func (o ordered[T]) get(key string) (T, bool) {
+	for _, m := range o {
+		if m.Key == key {
+			return m.Value, true
+		}
+	}
+	var zero T
+	return zero, false
+}
+
+func (o *ordered[T]) UnmarshalJSON(data []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return fmt.Errorf("expected a JSON object")
+	}
+	*o = nil
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		var value T
+		if err := dec.Decode(&value); err != nil {
+			return err
+		}
+		*o = append(*o, member[T]{Key: tok.(string), Value: value})
+	}
+	_, err := dec.Token()
+	return err
+}
@@ -0,0 +1,25 @@
+// Command genclient generates the Go client in pkg/client from the OpenAPI
+// document. Run it with "go generate ./pkg/client".
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	"openai-api/pkg/openapi"
+)
+
+func main() {
+	out := flag.String("o", "client_gen.go", "file to write the generated code to")
+	pkg := flag.String("package", "client", "package name of the generated code")
+	flag.Parse()
+
+	code, err := openapi.GenerateClient(openapi.Spec, *pkg)
+	if err != nil {
+		log.Fatalf("Failed to generate client: %v", err)
+	}
+	if err := os.WriteFile(*out, code, 0o644); err != nil {
+		log.Fatalf("Failed to write client: %v", err)
+	}
+}
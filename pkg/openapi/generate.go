@@ -0,0 +1,305 @@
+package openapi
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// GenerateClient generates the types and methods of the Go client in
+// pkg/client from an OpenAPI document. Each component schema becomes a type
+// and each operation a method of Client, which is written by hand together
+// with its do and stream helpers.
+func GenerateClient(spec []byte, packageName string) ([]byte, error) {
+	doc, err := parseDocument(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	var body bytes.Buffer
+	for _, m := range doc.Components.Schemas {
+		writeType(&body, m.Key, m.Value)
+	}
+	for _, path := range doc.Paths {
+		for _, op := range path.Value {
+			if err := writeMethod(&body, path.Key, strings.ToUpper(op.Key), op.Value); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "// Code generated by openai-api/pkg/openapi/genclient from pkg/openapi/openapi.json. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&out, "package %s\n\nimport (\n", packageName)
+	for _, pkg := range []string{"context", "net/http", "net/url", "strconv", "time"} {
+		name := pkg[strings.LastIndex(pkg, "/")+1:]
+		if regexp.MustCompile(`\b` + name + `\.[A-Z]`).Match(body.Bytes()) {
+			fmt.Fprintf(&out, "\t%q\n", pkg)
+		}
+	}
+	out.WriteString(")\n")
+	out.Write(body.Bytes())
+	return format.Source(out.Bytes())
+}
+
+// writeType writes the type of a component schema.
+func writeType(w *bytes.Buffer, name string, s *schema) {
+	fmt.Fprintf(w, "\n// %s mirrors the %s schema.\n", name, name)
+	writeComment(w, "", s.Description)
+	if len(s.Properties) == 0 {
+		fmt.Fprintf(w, "type %s %s\n", name, goType(s))
+		return
+	}
+	fmt.Fprintf(w, "type %s struct {\n", name)
+	for _, property := range s.Properties {
+		writeComment(w, "\t", property.Value.Description)
+		tag := property.Key
+		if !s.isRequired(property.Key) {
+			tag += ",omitempty"
+		}
+		fmt.Fprintf(w, "\t%s %s `json:%q`\n", goName(property.Key), goType(property.Value), tag)
+	}
+	w.WriteString("}\n")
+}
+
+// writeMethod writes the Client method calling an operation.
+func writeMethod(w *bytes.Buffer, path, method string, op *operation) error {
+	name := goName(op.OperationID)
+
+	// Arguments: path parameters, then a struct of query and header
+	// parameters, then the body
+	args := []string{"ctx context.Context"}
+	var query, header []parameter
+	for _, param := range op.Parameters {
+		switch param.In {
+		case "path":
+			args = append(args, fmt.Sprintf("%s %s", param.Name, goType(param.Schema)))
+		case "query":
+			query = append(query, param)
+		case "header":
+			header = append(header, param)
+		default:
+			return fmt.Errorf("%s: unsupported %s parameter %q", op.OperationID, param.In, param.Name)
+		}
+	}
+	if len(query) > 0 || len(header) > 0 {
+		kinds := "query"
+		switch {
+		case len(query) == 0:
+			kinds = "header"
+		case len(header) > 0:
+			kinds = "query and header"
+		}
+		w.WriteString("\n")
+		writeComment(w, "", fmt.Sprintf("%sParams are the %s parameters of %s. Zero values are left out.", name, kinds, name))
+		fmt.Fprintf(w, "type %sParams struct {\n", name)
+		for _, param := range append(query, header...) {
+			writeComment(w, "\t", param.Description)
+			fmt.Fprintf(w, "\t%s %s\n", goName(param.Name), goType(param.Schema))
+		}
+		w.WriteString("}\n")
+		args = append(args, fmt.Sprintf("params %sParams", name))
+	}
+	bodyArg := "nil"
+	if op.RequestBody != nil {
+		media, ok := op.RequestBody.Content["application/json"]
+		if !ok {
+			return fmt.Errorf("%s: request body is not JSON", op.OperationID)
+		}
+		args = append(args, "body "+goType(media.Schema))
+		bodyArg = "body"
+	}
+
+	// Result: the first successful response with content
+	var result *schema
+	stream := false
+	successes := 0
+	for _, m := range op.Responses {
+		if !strings.HasPrefix(m.Key, "2") || len(m.Value.Content) == 0 {
+			continue
+		}
+		successes++
+		if result != nil || stream {
+			continue
+		}
+		if media, ok := m.Value.Content["application/json"]; ok {
+			result = media.Schema
+		} else if _, ok := m.Value.Content["text/event-stream"]; ok {
+			stream = true
+		} else {
+			return fmt.Errorf("%s: unsupported response content", op.OperationID)
+		}
+	}
+
+	w.WriteString("\n")
+	writeComment(w, "", fmt.Sprintf("%s calls %s %s: %s.", name, method, path, op.Summary))
+	writeComment(w, "", op.Description)
+	if successes > 1 {
+		w.WriteString("// Every successful response is decoded into the result.\n")
+	}
+	if stream {
+		w.WriteString("// The caller must close the body of the returned event stream.\n")
+	}
+	returns := "error"
+	switch {
+	case stream:
+		returns = "(*http.Response, error)"
+	case result != nil && result.Type == "array" || result != nil && result.Type == "object":
+		returns = fmt.Sprintf("(%s, error)", goType(result))
+	case result != nil:
+		returns = fmt.Sprintf("(*%s, error)", goType(result))
+	}
+	fmt.Fprintf(w, "func (c *Client) %s(%s) %s {\n", name, strings.Join(args, ", "), returns)
+
+	queryArg := "nil"
+	if len(query) > 0 {
+		queryArg = "query"
+		w.WriteString("\tquery := url.Values{}\n")
+		writeParams(w, "query", query)
+	}
+	headerArg := "nil"
+	if len(header) > 0 {
+		headerArg = "header"
+		w.WriteString("\theader := http.Header{}\n")
+		writeParams(w, "header", header)
+	}
+
+	pathExpr := pathExpression(path, op.Parameters)
+	switch {
+	case stream:
+		fmt.Fprintf(w, "\treturn c.stream(ctx, %q, %s, %s, %s)\n", method, pathExpr, queryArg, headerArg)
+	case result == nil:
+		fmt.Fprintf(w, "\treturn c.do(ctx, %q, %s, %s, %s, %s, nil)\n", method, pathExpr, queryArg, headerArg, bodyArg)
+	case result.Type == "array" || result.Type == "object":
+		fmt.Fprintf(w, "\tvar out %s\n", goType(result))
+		fmt.Fprintf(w, "\tif err := c.do(ctx, %q, %s, %s, %s, %s, &out); err != nil {\n\t\treturn nil, err\n\t}\n", method, pathExpr, queryArg, headerArg, bodyArg)
+		w.WriteString("\treturn out, nil\n")
+	default:
+		fmt.Fprintf(w, "\tvar out %s\n", goType(result))
+		fmt.Fprintf(w, "\tif err := c.do(ctx, %q, %s, %s, %s, %s, &out); err != nil {\n\t\treturn nil, err\n\t}\n", method, pathExpr, queryArg, headerArg, bodyArg)
+		w.WriteString("\treturn &out, nil\n")
+	}
+	w.WriteString("}\n")
+	return nil
+}
+
+// writeParams writes code setting the non-zero parameters in the url.Values
+// or http.Header named target.
+func writeParams(w *bytes.Buffer, target string, params []parameter) {
+	for _, param := range params {
+		field := "params." + goName(param.Name)
+		value := field
+		zero := `""`
+		if param.Schema.Type == "integer" {
+			value = fmt.Sprintf("strconv.Itoa(%s)", field)
+			zero = "0"
+		}
+		fmt.Fprintf(w, "\tif %s != %s {\n\t\t%s.Set(%q, %s)\n\t}\n", field, zero, target, param.Name, value)
+	}
+}
+
+// pathExpression returns a Go expression building a path, escaping its
+// parameters.
+func pathExpression(path string, params []parameter) string {
+	var parts []string
+	for path != "" {
+		start := strings.Index(path, "{")
+		if start < 0 {
+			parts = append(parts, fmt.Sprintf("%q", path))
+			break
+		}
+		end := strings.Index(path, "}")
+		if start > 0 {
+			parts = append(parts, fmt.Sprintf("%q", path[:start]))
+		}
+		name := path[start+1 : end]
+		value := name
+		for _, param := range params {
+			if param.Name == name && param.Schema.Type == "integer" {
+				value = fmt.Sprintf("strconv.Itoa(%s)", name)
+			}
+		}
+		parts = append(parts, fmt.Sprintf("url.PathEscape(%s)", value))
+		path = path[end+1:]
+	}
+	return strings.Join(parts, " + ")
+}
+
+// goType returns the Go type of a schema. Nullable scalars are pointers.
+func goType(s *schema) string {
+	if s.Ref != "" {
+		return s.refName()
+	}
+	var t string
+	switch s.Type {
+	case "string":
+		t = "string"
+		if s.Format == "date-time" {
+			t = "time.Time"
+		}
+	case "integer":
+		t = "int"
+	case "number":
+		t = "float64"
+	case "boolean":
+		t = "bool"
+	case "array":
+		return "[]" + goType(s.Items)
+	case "object":
+		return "map[string]interface{}"
+	default:
+		return "interface{}"
+	}
+	if s.Nullable {
+		return "*" + t
+	}
+	return t
+}
+
+// initialisms are spelled in capitals in Go names.
+var initialisms = map[string]string{"Id": "ID", "Ids": "IDs", "Url": "URL", "Api": "API"}
+
+// goName converts a camel case JSON name or a dashed header name to an
+// exported Go name.
+func goName(name string) string {
+	var words []string
+	start := 0
+	for i, r := range name {
+		switch {
+		case r == '-':
+			words = append(words, name[start:i])
+			start = i + 1
+		case i > start && unicode.IsUpper(r):
+			words = append(words, name[start:i])
+			start = i
+		}
+	}
+	words = append(words, name[start:])
+	for i, word := range words {
+		word = strings.ToUpper(word[:1]) + word[1:]
+		if initialism, ok := initialisms[word]; ok {
+			word = initialism
+		}
+		words[i] = word
+	}
+	return strings.Join(words, "")
+}
+
+// writeComment writes text as a comment wrapped at 80 columns.
+func writeComment(w *bytes.Buffer, indent, text string) {
+	if text == "" {
+		return
+	}
+	line := indent + "//"
+	for _, word := range strings.Fields(text) {
+		if len(line)+1+len(word) > 80 && line != indent+"//" {
+			w.WriteString(line + "\n")
+			line = indent + "//"
+		}
+		line += " " + word
+	}
+	w.WriteString(line + "\n")
+}
@@ -0,0 +1,24 @@
+// Package openapi holds the OpenAPI 3 document describing the HTTP API and
+// generates the Go client in pkg/client from it.
+//
+// The document is written by hand next to the handlers it describes. Its
+// tests fail when it drifts from the routes in pkg/server or from the request
+// and response types in pkg/handlers, and when pkg/client was not
+// regenerated with "go generate ./pkg/client" after changing it.
+package openapi
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+// Spec is the OpenAPI document, as JSON.
+//
+//go:embed openapi.json
+var Spec []byte
+
+// GetSpec handles the GET /api/openapi.json endpoint.
+func GetSpec(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(Spec)
+}
@@ -0,0 +1,598 @@
+package openapi
+
+import (
+	"bytes"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// The tests read the sources of the packages the document describes rather
+// than importing them, to see how handlers are routed and what they encode.
+
+// internalTypes are exported types of pkg/handlers that are not part of the
+// HTTP API.
+var internalTypes = map[string]bool{
+	"handlers.OpenAIResponse": true, // LLM output
+	"handlers.GroupVerdict":   true, // LLM output
+}
+
+// TestClientUpToDate fails if pkg/client was not regenerated after the
+// document changed.
+func TestClientUpToDate(t *testing.T) {
+	want, err := GenerateClient(Spec, "client")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile("../client/client_gen.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Error("pkg/client/client_gen.go is out of date, run: go generate ./pkg/client")
+	}
+}
+
+// TestRoutes checks that the document describes exactly the routes of
+// pkg/server, with operation IDs named after their handlers, security on the
+// routes that require an administrator and an Idempotency-Key parameter on
+// the idempotent routes.
+func TestRoutes(t *testing.T) {
+	doc := loadDocument(t)
+	operations := make(map[string]*operation)
+	for _, path := range doc.Paths {
+		for _, op := range path.Value {
+			operations[strings.ToUpper(op.Key)+" "+path.Key] = op.Value
+		}
+	}
+
+	routes := serverRoutes(t)
+	for key, route := range routes {
+		op, ok := operations[key]
+		if !ok {
+			t.Errorf("%s is not in the document", key)
+			continue
+		}
+		if want := lowerFirst(route.handler); op.OperationID != want {
+			t.Errorf("%s: operationId is %q, want %q", key, op.OperationID, want)
+		}
+		if route.admin != (len(op.Security) > 0) {
+			t.Errorf("%s: security does not match whether it requires an administrator", key)
+		}
+		hasKey := false
+		for _, param := range op.Parameters {
+			hasKey = hasKey || param.In == "header" && param.Name == "Idempotency-Key"
+		}
+		if route.idempotent != hasKey {
+			t.Errorf("%s: Idempotency-Key parameter does not match whether it is idempotent", key)
+		}
+	}
+	for key := range operations {
+		if _, ok := routes[key]; !ok {
+			t.Errorf("%s is in the document but not routed", key)
+		}
+	}
+}
+
+// TestSchemas checks that every component schema has the properties and
+// types of the Go type it mirrors, and that every exported type of
+// pkg/handlers with JSON fields has a schema.
+func TestSchemas(t *testing.T) {
+	doc := loadDocument(t)
+	types := loadTypes(t, "handlers", "apierror", "answers")
+	requests := requestSchemas(doc)
+
+	described := make(map[string]bool)
+	for _, m := range doc.Components.Schemas {
+		name, s := m.Key, m.Value
+		if s.GoType == "" {
+			t.Errorf("schema %s has no x-go-type", name)
+			continue
+		}
+		described[s.GoType] = true
+		decl, ok := types[s.GoType]
+		if !ok {
+			t.Errorf("schema %s: type %s does not exist", name, s.GoType)
+			continue
+		}
+		pkg := strings.Split(s.GoType, ".")[0]
+		if _, isStruct := decl.Type.(*ast.StructType); !isStruct {
+			if err := checkType(doc, pkg, decl.Type, s); err != "" {
+				t.Errorf("schema %s: %s", name, err)
+			}
+			continue
+		}
+
+		fields := structFields(types, pkg, decl)
+		var want, got []string
+		for _, field := range fields {
+			want = append(want, field.name)
+		}
+		for _, property := range s.Properties {
+			got = append(got, property.Key)
+		}
+		sort.Strings(want)
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("schema %s has properties %v, %s has %v", name, got, s.GoType, want)
+			continue
+		}
+		for _, field := range fields {
+			property, _ := s.Properties.get(field.name)
+			if err := checkType(doc, field.pkg, field.typ, property); err != "" {
+				t.Errorf("schema %s, property %s: %s", name, field.name, err)
+			}
+			// Whether a request field is required is up to the handler
+			if !requests[name] && s.isRequired(field.name) == field.omitempty {
+				t.Errorf("schema %s, property %s: required must be %v", name, field.name, !field.omitempty)
+			}
+		}
+	}
+
+	for name, decl := range types {
+		if !strings.HasPrefix(name, "handlers.") || !ast.IsExported(decl.Name.Name) || internalTypes[name] || described[name] {
+			continue
+		}
+		if structType, ok := decl.Type.(*ast.StructType); ok && strings.Contains(sourceOf(structType), "json:") {
+			t.Errorf("%s has no schema", name)
+		}
+	}
+}
+
+// TestHandlers checks that each handler decodes the request body and encodes
+// the responses the document gives for its operation.
+func TestHandlers(t *testing.T) {
+	doc := loadDocument(t)
+	funcs := loadFuncs(t, "handlers")
+
+	for key, route := range serverRoutes(t) {
+		if route.pkg != "handlers" {
+			continue
+		}
+		fn, ok := funcs[route.handler]
+		if !ok {
+			t.Errorf("%s: handler %s does not exist", key, route.handler)
+			continue
+		}
+		method, path, _ := strings.Cut(key, " ")
+		item, _ := doc.Paths.get(path)
+		op, ok := item.get(strings.ToLower(method))
+		if !ok {
+			continue // reported by TestRoutes
+		}
+
+		// Request body
+		var wantRequest string
+		if op.RequestBody != nil {
+			wantRequest = schemaType(doc, op.RequestBody.Content["application/json"].Schema)
+		}
+		if got := requestType(funcs, fn); got != wantRequest {
+			t.Errorf("%s: %s decodes %q, the document says %q", key, route.handler, got, wantRequest)
+		}
+
+		// Response bodies
+		want := make(map[string]bool)
+		for _, m := range op.Responses {
+			if media, ok := m.Value.Content["application/json"]; ok && strings.HasPrefix(m.Key, "2") {
+				want[schemaType(doc, media.Schema)] = true
+			}
+		}
+		got := make(map[string]bool)
+		for _, encoded := range responseTypes(t, funcs, fn, make(map[string]bool)) {
+			got[encoded] = true
+			if !want[encoded] {
+				t.Errorf("%s: %s encodes %s, which is not a successful response in the document", key, route.handler, encoded)
+			}
+		}
+		for response := range want {
+			if !got[response] {
+				t.Errorf("%s: the document says it returns %s, %s never encodes it", key, response, route.handler)
+			}
+		}
+	}
+}
+
+func loadDocument(t *testing.T) *document {
+	t.Helper()
+	doc, err := parseDocument(Spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+// route is an API route of pkg/server.
+type route struct {
+	pkg, handler string
+	admin        bool
+	idempotent   bool
+}
+
+// routeVariable matches path variables with a pattern, e.g. {id:[0-9]+}.
+var routeVariable = regexp.MustCompile(`\{(\w+):[^}]*\}`)
+
+// serverRoutes returns the routes of the api subrouter, keyed by method and
+// path, e.g. "GET /results/{token}".
+func serverRoutes(t *testing.T) map[string]route {
+	t.Helper()
+	file, err := parser.ParseFile(token.NewFileSet(), "../server/server.go", nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	routes := make(map[string]route)
+	ast.Inspect(file, func(n ast.Node) bool {
+		// api.HandleFunc(path, handler).Methods(methods...), where handler is
+		// app.Handler, requireAdmin(app.Handler), idempotent(app.Handler) or
+		// package.Handler
+		methods, ok := n.(*ast.CallExpr)
+		if !ok || !isSelector(methods.Fun, "", "Methods") {
+			return true
+		}
+		handle, ok := methods.Fun.(*ast.SelectorExpr).X.(*ast.CallExpr)
+		if !ok || !isSelector(handle.Fun, "api", "HandleFunc") || len(handle.Args) != 2 {
+			return true
+		}
+		path := routeVariable.ReplaceAllString(stringLit(handle.Args[0]), "{$1}")
+		var r route
+		handler := handle.Args[1]
+		if call, ok := handler.(*ast.CallExpr); ok && isIdent(call.Fun, "requireAdmin") {
+			r.admin = true
+			handler = call.Args[0]
+		}
+		if call, ok := handler.(*ast.CallExpr); ok && isIdent(call.Fun, "idempotent") {
+			r.idempotent = true
+			handler = call.Args[0]
+		}
+		if sel, ok := handler.(*ast.SelectorExpr); ok {
+			r.pkg, r.handler = sel.X.(*ast.Ident).Name, sel.Sel.Name
+			if r.pkg == "app" {
+				r.pkg = "handlers"
+			}
+		}
+		for _, arg := range methods.Args {
+			routes[stringLit(arg)+" "+path] = r
+		}
+		return false
+	})
+	if len(routes) == 0 {
+		t.Fatal("found no routes in pkg/server")
+	}
+	return routes
+}
+
+// loadTypes parses packages and returns their type declarations, keyed by
+// package and name, e.g. "handlers.ResultsResponse".
+func loadTypes(t *testing.T, pkgs ...string) map[string]*ast.TypeSpec {
+	t.Helper()
+	types := make(map[string]*ast.TypeSpec)
+	for _, pkg := range pkgs {
+		for _, file := range parsePackage(t, pkg) {
+			ast.Inspect(file, func(n ast.Node) bool {
+				if spec, ok := n.(*ast.TypeSpec); ok {
+					types[pkg+"."+spec.Name.Name] = spec
+				}
+				return true
+			})
+		}
+	}
+	return types
+}
+
+// loadFuncs parses a package and returns its functions and methods by name.
+func loadFuncs(t *testing.T, pkg string) map[string]*ast.FuncDecl {
+	t.Helper()
+	funcs := make(map[string]*ast.FuncDecl)
+	for _, file := range parsePackage(t, pkg) {
+		for _, decl := range file.Decls {
+			if fn, ok := decl.(*ast.FuncDecl); ok {
+				funcs[fn.Name.Name] = fn
+			}
+		}
+	}
+	return funcs
+}
+
+func parsePackage(t *testing.T, pkg string) []*ast.File {
+	t.Helper()
+	paths, err := filepath.Glob(filepath.Join("..", pkg, "*.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var files []*ast.File
+	for _, path := range paths {
+		if strings.HasSuffix(path, "_test.go") {
+			continue
+		}
+		file, err := parser.ParseFile(token.NewFileSet(), path, nil, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, file)
+	}
+	return files
+}
+
+// field is a JSON field of a struct.
+type field struct {
+	name      string
+	pkg       string
+	typ       ast.Expr
+	omitempty bool
+}
+
+// structFields returns the JSON fields of a struct type, including those of
+// embedded structs.
+func structFields(types map[string]*ast.TypeSpec, pkg string, decl *ast.TypeSpec) []field {
+	var fields []field
+	for _, f := range decl.Type.(*ast.StructType).Fields.List {
+		var tag string
+		if f.Tag != nil {
+			raw, _ := strconv.Unquote(f.Tag.Value)
+			tag = reflect.StructTag(raw).Get("json")
+		}
+		name, options, _ := strings.Cut(tag, ",")
+		if len(f.Names) == 0 && name == "" {
+			if ident, ok := f.Type.(*ast.Ident); ok {
+				fields = append(fields, structFields(types, pkg, types[pkg+"."+ident.Name])...)
+			}
+			continue
+		}
+		if name == "-" || (name == "" && !ast.IsExported(f.Names[0].Name)) {
+			continue
+		}
+		if name == "" {
+			name = f.Names[0].Name
+		}
+		fields = append(fields, field{name: name, pkg: pkg, typ: f.Type, omitempty: strings.Contains(options, "omitempty")})
+	}
+	return fields
+}
+
+// checkType compares a Go type with a schema, returning what differs.
+func checkType(doc *document, pkg string, expr ast.Expr, s *schema) string {
+	if star, ok := expr.(*ast.StarExpr); ok {
+		if !s.Nullable {
+			return "pointer must be nullable"
+		}
+		expr = star.X
+	} else if s.Nullable {
+		return "only pointers are nullable"
+	}
+	got := goTypeName(pkg, expr)
+	want := schemaType(doc, s)
+	if s.Ref == "" && s.Type == "string" && s.Format == "date-time" {
+		want = "time.Time"
+	}
+	if got != want {
+		return "Go type " + got + " does not match schema type " + want
+	}
+	return ""
+}
+
+// goTypeName returns the type an expression denotes, qualifying named types
+// with their package and naming basic types after the JSON type they encode to.
+func goTypeName(pkg string, expr ast.Expr) string {
+	switch expr := expr.(type) {
+	case *ast.Ident:
+		switch expr.Name {
+		case "string", "bool":
+			return map[string]string{"string": "string", "bool": "boolean"}[expr.Name]
+		case "int", "int64", "uint", "uint64":
+			return "integer"
+		case "float64":
+			return "number"
+		}
+		return pkg + "." + expr.Name
+	case *ast.SelectorExpr:
+		return expr.X.(*ast.Ident).Name + "." + expr.Sel.Name
+	case *ast.ArrayType:
+		return "[]" + goTypeName(pkg, expr.Elt)
+	case *ast.StarExpr:
+		return goTypeName(pkg, expr.X)
+	case *ast.MapType:
+		return "object"
+	case *ast.InterfaceType:
+		return "any"
+	}
+	return sourceOf(expr)
+}
+
+// schemaType returns the type a schema denotes, in the form of goTypeName.
+func schemaType(doc *document, s *schema) string {
+	if s.Ref != "" {
+		if component, ok := doc.Components.Schemas.get(s.refName()); ok {
+			return component.GoType
+		}
+		return s.Ref
+	}
+	switch s.Type {
+	case "array":
+		return "[]" + schemaType(doc, s.Items)
+	case "":
+		return "any"
+	}
+	return s.Type
+}
+
+// requestSchemas returns the names of the schemas used for request bodies.
+func requestSchemas(doc *document) map[string]bool {
+	requests := make(map[string]bool)
+	for _, path := range doc.Paths {
+		for _, op := range path.Value {
+			if op.Value.RequestBody == nil {
+				continue
+			}
+			for s := op.Value.RequestBody.Content["application/json"].Schema; s != nil; s = s.Items {
+				if s.Ref != "" {
+					requests[s.refName()] = true
+					if component, ok := doc.Components.Schemas.get(s.refName()); ok && component.Items != nil {
+						requests[component.Items.refName()] = true
+					}
+					break
+				}
+			}
+		}
+	}
+	return requests
+}
+
+// requestType returns the type a handler decodes the request body into with
+// a.decodeRequest, or "".
+func requestType(funcs map[string]*ast.FuncDecl, fn *ast.FuncDecl) string {
+	vars := localTypes(funcs, fn)
+	var decoded string
+	ast.Inspect(fn.Body, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok || !isSelector(call.Fun, "a", "decodeRequest") || len(call.Args) != 3 {
+			return true
+		}
+		if unary, ok := call.Args[2].(*ast.UnaryExpr); ok {
+			decoded = vars[unary.X.(*ast.Ident).Name]
+		}
+		return false
+	})
+	return decoded
+}
+
+// responseTypes returns the types a handler encodes as JSON responses,
+// following calls to functions of the package that are passed the response
+// writer.
+func responseTypes(t *testing.T, funcs map[string]*ast.FuncDecl, fn *ast.FuncDecl, seen map[string]bool) []string {
+	if seen[fn.Name.Name] {
+		return nil
+	}
+	seen[fn.Name.Name] = true
+	vars := localTypes(funcs, fn)
+	var encoded []string
+	ast.Inspect(fn.Body, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+		// json.NewEncoder(w).Encode(value)
+		if sel, ok := call.Fun.(*ast.SelectorExpr); ok && sel.Sel.Name == "Encode" && len(call.Args) == 1 {
+			if inner, ok := sel.X.(*ast.CallExpr); ok && isSelector(inner.Fun, "json", "NewEncoder") {
+				typ := expressionType(funcs, vars, call.Args[0])
+				if typ == "" {
+					t.Errorf("%s: cannot tell the type of %s", fn.Name.Name, sourceOf(call.Args[0]))
+				}
+				encoded = append(encoded, typ)
+				return false
+			}
+		}
+		// A function or method of the package writing to w
+		if callee := calledFunc(funcs, call); callee != nil {
+			for _, arg := range call.Args {
+				if isIdent(arg, "w") {
+					encoded = append(encoded, responseTypes(t, funcs, callee, seen)...)
+					break
+				}
+			}
+		}
+		return true
+	})
+	return encoded
+}
+
+// localTypes returns the types of the variables a function declares with
+// var or :=, where they can be told from the declaration.
+func localTypes(funcs map[string]*ast.FuncDecl, fn *ast.FuncDecl) map[string]string {
+	vars := make(map[string]string)
+	ast.Inspect(fn.Body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.ValueSpec:
+			if n.Type != nil {
+				for _, name := range n.Names {
+					vars[name.Name] = goTypeName("handlers", n.Type)
+				}
+			}
+		case *ast.AssignStmt:
+			// x := value and x, err := f(...)
+			if ident, ok := n.Lhs[0].(*ast.Ident); ok && n.Tok == token.DEFINE && len(n.Rhs) == 1 {
+				if typ := expressionType(funcs, vars, n.Rhs[0]); typ != "" {
+					vars[ident.Name] = typ
+				}
+			}
+		}
+		return true
+	})
+	return vars
+}
+
+// expressionType returns the type of a composite literal, make call, known
+// variable or call of a package function, or "".
+func expressionType(funcs map[string]*ast.FuncDecl, vars map[string]string, expr ast.Expr) string {
+	switch expr := expr.(type) {
+	case *ast.CompositeLit:
+		return goTypeName("handlers", expr.Type)
+	case *ast.UnaryExpr:
+		return expressionType(funcs, vars, expr.X)
+	case *ast.Ident:
+		return vars[expr.Name]
+	case *ast.CallExpr:
+		if isIdent(expr.Fun, "make") {
+			return goTypeName("handlers", expr.Args[0])
+		}
+		if fn := calledFunc(funcs, expr); fn != nil && fn.Type.Results != nil {
+			return goTypeName("handlers", fn.Type.Results.List[0].Type)
+		}
+	}
+	return ""
+}
+
+// calledFunc returns the function, or the method of a receiver named a, that
+// a call calls, or nil if it is not one of funcs.
+func calledFunc(funcs map[string]*ast.FuncDecl, call *ast.CallExpr) *ast.FuncDecl {
+	switch fun := call.Fun.(type) {
+	case *ast.Ident:
+		if fn := funcs[fun.Name]; fn != nil && fn.Recv == nil {
+			return fn
+		}
+	case *ast.SelectorExpr:
+		if fn := funcs[fun.Sel.Name]; fn != nil && fn.Recv != nil && isIdent(fun.X, "a") {
+			return fn
+		}
+	}
+	return nil
+}
+
+func isSelector(expr ast.Expr, pkg, name string) bool {
+	sel, ok := expr.(*ast.SelectorExpr)
+	if !ok || sel.Sel.Name != name {
+		return false
+	}
+	return pkg == "" || isIdent(sel.X, pkg)
+}
+
+func isIdent(expr ast.Expr, name string) bool {
+	ident, ok := expr.(*ast.Ident)
+	return ok && ident.Name == name
+}
+
+func stringLit(expr ast.Expr) string {
+	lit, ok := expr.(*ast.BasicLit)
+	if !ok {
+		return ""
+	}
+	value, _ := strconv.Unquote(lit.Value)
+	return value
+}
+
+func sourceOf(node ast.Node) string {
+	var buf bytes.Buffer
+	printer.Fprint(&buf, token.NewFileSet(), node)
+	return buf.String()
+}
+
+func lowerFirst(name string) string {
+	return strings.ToLower(name[:1]) + name[1:]
+}
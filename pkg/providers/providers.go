@@ -0,0 +1,66 @@
+// Package providers selects the LLM provider configured for the deployment.
+package providers
+
+import (
+	"fmt"
+	"strings"
+
+	"openai-api/pkg/anthropic"
+	"openai-api/pkg/config"
+	"openai-api/pkg/llm"
+	"openai-api/pkg/ollama"
+	"openai-api/pkg/openai"
+)
+
+// Default models used when no model is configured.
+const (
+	DefaultOpenAIModel    = "gpt-3.5-turbo"
+	DefaultAnthropicModel = "claude-3-5-haiku-latest"
+	DefaultOllamaModel    = "llama3.1"
+)
+
+// New creates the provider named by c.Provider: "openai" (the default, for
+// any OpenAI-compatible API), "anthropic" or "ollama", with its connection
+// settings from c. An empty model selects the provider's default.
+func New(c config.LLM) (llm.Provider, error) {
+	model := c.Model
+	switch strings.ToLower(strings.TrimSpace(c.Provider)) {
+	case "", "openai":
+		if model == "" {
+			model = DefaultOpenAIModel
+		}
+		return openai.NewClient(openai.NewConfig().
+			WithAPIKey(c.OpenAI.APIKey).
+			WithAPIBase(c.OpenAI.APIBase).
+			WithModel(model).
+			WithRetryPolicy(retryPolicy(c.OpenAI.MaxRetries))), nil
+	case "anthropic", "claude":
+		if model == "" {
+			model = DefaultAnthropicModel
+		}
+		return anthropic.NewClient(anthropic.NewConfig().
+			WithAPIKey(c.Anthropic.APIKey).
+			WithAPIBase(c.Anthropic.APIBase).
+			WithVersion(c.Anthropic.Version).
+			WithModel(model).
+			WithRetryPolicy(retryPolicy(c.Anthropic.MaxRetries))), nil
+	case "ollama":
+		if model == "" {
+			model = DefaultOllamaModel
+		}
+		return ollama.NewClient(ollama.NewConfig().
+			WithAPIBase(c.Ollama.APIBase).
+			WithAPIKey(c.Ollama.APIKey).
+			WithModel(model).
+			WithRetryPolicy(retryPolicy(c.Ollama.MaxRetries))), nil
+	default:
+		return nil, fmt.Errorf("unsupported LLM provider %q", c.Provider)
+	}
+}
+
+// retryPolicy returns the default retry policy with the given number of retries.
+func retryPolicy(maxRetries int) llm.RetryPolicy {
+	policy := llm.DefaultRetryPolicy()
+	policy.MaxRetries = maxRetries
+	return policy
+}
@@ -0,0 +1,225 @@
+// Package retention deletes the sessions the retention policy no longer
+// allows keeping: invitations nobody answered in time, and results kept as
+// long as allowed. Sessions are deleted for good, together with their
+// answers, scores and tokens, and every deletion is recorded in the
+// purge_records audit log.
+package retention
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"openai-api/pkg/config"
+	"openai-api/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+// Triggers of a purge, recorded in the audit log.
+const (
+	TriggerSweeper = "sweeper"
+	TriggerCLI     = "cli"
+)
+
+// batchSize is the number of sessions deleted per transaction.
+const batchSize = 500
+
+// Policy says how long sessions are kept. A zero duration keeps them forever.
+type Policy struct {
+	// InviteTTL is how long a pair session waits for User B, or a group
+	// session for its members, counted from its creation.
+	InviteTTL time.Duration
+
+	// ResultsTTL is how long a session is kept once its analysis finished,
+	// or a participant erased their data.
+	ResultsTTL time.Duration
+}
+
+// NewPolicy returns the policy configured by cfg.
+func NewPolicy(cfg config.Retention) Policy {
+	return Policy{
+		InviteTTL:  time.Duration(cfg.InviteDays) * 24 * time.Hour,
+		ResultsTTL: time.Duration(cfg.ResultsDays) * 24 * time.Hour,
+	}
+}
+
+// Enabled reports whether the policy deletes anything.
+func (p Policy) Enabled() bool {
+	return p.InviteTTL > 0 || p.ResultsTTL > 0
+}
+
+// rule selects the sessions due for deletion for one reason.
+type rule struct {
+	reason string
+	scope  func(db *gorm.DB) *gorm.DB
+}
+
+// rules returns the rules of the policy at now.
+func (p Policy) rules(now time.Time) []rule {
+	var rules []rule
+	if p.InviteTTL > 0 {
+		cutoff := now.Add(-p.InviteTTL)
+		rules = append(rules, rule{models.PurgeReasonInviteExpired, func(db *gorm.DB) *gorm.DB {
+			return db.Where("(status = '' OR status IS NULL) AND created_at < ?", cutoff)
+		}})
+	}
+	if p.ResultsTTL > 0 {
+		cutoff := now.Add(-p.ResultsTTL)
+		rules = append(rules, rule{models.PurgeReasonResultsExpired, func(db *gorm.DB) *gorm.DB {
+			return db.Where("status IN ? AND updated_at < ?", []string{models.SessionStatusDone, models.SessionStatusFailed, models.SessionStatusErased}, cutoff)
+		}})
+	}
+	return rules
+}
+
+// Due returns the sessions the policy would delete at now, as the audit
+// records Purge would write, without deleting them.
+func Due(db *gorm.DB, policy Policy, now time.Time) ([]models.PurgeRecord, error) {
+	var due []models.PurgeRecord
+	for _, rule := range policy.rules(now) {
+		var sessions []models.Session
+		err := rule.scope(db.Unscoped().Model(&models.Session{})).
+			Select("id", "kind", "created_at").Order("id").Find(&sessions).Error
+		if err != nil {
+			return nil, err
+		}
+		for _, session := range sessions {
+			due = append(due, purgeRecord(session, rule.reason, ""))
+		}
+	}
+	return due, nil
+}
+
+// Purge deletes the sessions the policy does not allow keeping at now,
+// including soft-deleted ones, and records each deletion with trigger in the
+// audit log. It returns the audit records of the deleted sessions, also when
+// it fails part way.
+func Purge(db *gorm.DB, policy Policy, trigger string, now time.Time) ([]models.PurgeRecord, error) {
+	var purged []models.PurgeRecord
+	for _, rule := range policy.rules(now) {
+		for {
+			records, found, err := purgeBatch(db, rule, trigger)
+			purged = append(purged, records...)
+			if err != nil {
+				return purged, err
+			}
+			if found < batchSize {
+				break
+			}
+		}
+	}
+	return purged, nil
+}
+
+// purgeBatch deletes up to batchSize sessions due for deletion under rule.
+// It returns the audit records of the deleted sessions and the number of
+// sessions that were due, which is larger if some changed meanwhile, e.g.
+// because User B answered.
+func purgeBatch(db *gorm.DB, rule rule, trigger string) ([]models.PurgeRecord, int, error) {
+	var records []models.PurgeRecord
+	var found int
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var due []uint
+		err := rule.scope(tx.Unscoped().Model(&models.Session{})).Order("id").Limit(batchSize).Pluck("id", &due).Error
+		if err != nil || len(due) == 0 {
+			return err
+		}
+		found = len(due)
+
+		// Soft delete the sessions first, so that no request can use them
+		// any more, then delete them and everything belonging to them
+		err = rule.scope(tx.Unscoped().Model(&models.Session{}).Where("id IN ?", due)).
+			UpdateColumn("deleted_at", gorm.Expr("COALESCE(deleted_at, ?)", time.Now())).Error
+		if err != nil {
+			return err
+		}
+		var sessions []models.Session
+		err = rule.scope(tx.Unscoped().Where("id IN ?", due)).Select("id", "kind", "created_at").Find(&sessions).Error
+		if err != nil || len(sessions) == 0 {
+			return err
+		}
+		ids := make([]uint, len(sessions))
+		for i, session := range sessions {
+			ids[i] = session.ID
+		}
+		for _, model := range []interface{}{&models.Participant{}, &models.QuestionScore{}, &models.PairScore{}, &models.SessionToken{}, &models.IdempotentRequest{}} {
+			if err := tx.Unscoped().Where("session_id IN ?", ids).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Unscoped().Where("id IN ?", ids).Delete(&models.Session{}).Error; err != nil {
+			return err
+		}
+
+		for _, session := range sessions {
+			records = append(records, purgeRecord(session, rule.reason, trigger))
+		}
+		return tx.Create(&records).Error
+	})
+	if err != nil {
+		return nil, found, err
+	}
+	return records, found, nil
+}
+
+// purgeRecord returns the audit record of deleting session.
+func purgeRecord(session models.Session, reason, trigger string) models.PurgeRecord {
+	return models.PurgeRecord{
+		SessionID:        session.ID,
+		SessionKind:      session.Kind,
+		SessionCreatedAt: session.CreatedAt,
+		Reason:           reason,
+		Trigger:          trigger,
+	}
+}
+
+// Sweep purges the sessions the policy does not allow keeping right away and
+// then every interval, until ctx is done. It logs what it deleted to logger.
+// It returns at once if the policy is not enabled.
+func Sweep(ctx context.Context, db *gorm.DB, policy Policy, interval time.Duration, logger *log.Logger) {
+	if !policy.Enabled() {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		purged, err := Purge(db, policy, TriggerSweeper, time.Now())
+		if len(purged) > 0 {
+			logger.Printf("Retention: deleted %s", Summary(purged))
+		}
+		if err != nil {
+			logger.Printf("Retention: failed to delete expired sessions: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Summary describes how many sessions were deleted for each reason, e.g.
+// "3 sessions (2 invite_expired, 1 results_expired)".
+func Summary(records []models.PurgeRecord) string {
+	counts := make(map[string]int)
+	var reasons []string
+	for _, record := range records {
+		if counts[record.Reason] == 0 {
+			reasons = append(reasons, record.Reason)
+		}
+		counts[record.Reason]++
+	}
+	summary := fmt.Sprintf("%d sessions", len(records))
+	if len(reasons) == 0 {
+		return summary
+	}
+	parts := make([]string, len(reasons))
+	for i, reason := range reasons {
+		parts[i] = fmt.Sprintf("%d %s", counts[reason], reason)
+	}
+	return summary + " (" + strings.Join(parts, ", ") + ")"
+}
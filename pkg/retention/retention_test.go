@@ -0,0 +1,232 @@
+package retention
+
+import (
+	"io"
+	"log"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"openai-api/pkg/config"
+	"openai-api/pkg/database"
+	"openai-api/pkg/encryption"
+	"openai-api/pkg/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// The tests purge an in-memory SQLite database holding a session for each
+// case of the policy.
+
+func TestMain(m *testing.M) {
+	log.SetOutput(io.Discard) // Migration progress
+	os.Exit(m.Run())
+}
+
+var (
+	now    = time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	policy = Policy{InviteTTL: 7 * 24 * time.Hour, ResultsTTL: 30 * 24 * time.Hour}
+)
+
+// testSession is a session to create, and whether the policy deletes it.
+type testSession struct {
+	status  string
+	age     time.Duration // Since the session was created and last updated
+	deleted bool          // Whether it is soft-deleted
+	reason  string        // Why the policy deletes it, empty if it keeps it
+}
+
+var testSessions = []testSession{
+	{status: "", age: 8 * 24 * time.Hour, reason: models.PurgeReasonInviteExpired},
+	{status: "", age: 6 * 24 * time.Hour},
+	{status: models.SessionStatusDone, age: 31 * 24 * time.Hour, reason: models.PurgeReasonResultsExpired},
+	{status: models.SessionStatusDone, age: 29 * 24 * time.Hour},
+	{status: models.SessionStatusFailed, age: 31 * 24 * time.Hour, reason: models.PurgeReasonResultsExpired},
+	{status: models.SessionStatusErased, age: 31 * 24 * time.Hour, deleted: true, reason: models.PurgeReasonResultsExpired},
+	{status: models.SessionStatusPending, age: 100 * 24 * time.Hour},
+	{status: models.SessionStatusRunning, age: 100 * 24 * time.Hour},
+}
+
+// newTestDB returns a database holding testSessions, each with a participant
+// and a token, with IDs in the order of testSessions starting at 1.
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := database.Connect("sqlite::memory:", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Every connection would get a database of its own
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+	keyring, err := encryption.NewKeyring(config.Encryption{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db = encryption.Attach(db, keyring)
+	db.Logger = logger.Discard
+
+	for i, test := range testSessions {
+		at := now.Add(-test.age)
+		session := models.Session{Kind: models.SessionKindPair, Status: test.status}
+		session.CreatedAt, session.UpdatedAt = at, at
+		if test.deleted {
+			session.DeletedAt = gorm.DeletedAt{Time: at, Valid: true}
+		}
+		if err := db.Create(&session).Error; err != nil {
+			t.Fatal(err)
+		}
+		if session.ID != uint(i+1) {
+			t.Fatalf("session %d got ID %d", i+1, session.ID)
+		}
+		if err := db.Create(&models.Participant{SessionID: session.ID, Role: models.ParticipantRoleInitiator}).Error; err != nil {
+			t.Fatal(err)
+		}
+		if err := db.Create(&models.SessionToken{SessionID: session.ID, Purpose: models.TokenPurposeResults, TokenHash: string(rune('a' + i))}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	return db
+}
+
+// wantPurged returns the IDs of the sessions policy deletes, and why, in
+// the order Due and Purge return them.
+func wantPurged() (ids []uint, reasons []string) {
+	for _, reason := range []string{models.PurgeReasonInviteExpired, models.PurgeReasonResultsExpired} {
+		for i, test := range testSessions {
+			if test.reason == reason {
+				ids = append(ids, uint(i+1))
+				reasons = append(reasons, reason)
+			}
+		}
+	}
+	return ids, reasons
+}
+
+// recordSessions returns the sessions and reasons of audit records.
+func recordSessions(records []models.PurgeRecord) (ids []uint, reasons []string) {
+	for _, record := range records {
+		ids = append(ids, record.SessionID)
+		reasons = append(reasons, record.Reason)
+	}
+	return ids, reasons
+}
+
+// count returns the number of rows of model, including soft-deleted ones.
+func count(t *testing.T, db *gorm.DB, model interface{}) int64 {
+	t.Helper()
+	var n int64
+	if err := db.Unscoped().Model(model).Count(&n).Error; err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestDueDeletesNothing(t *testing.T) {
+	db := newTestDB(t)
+	due, err := Due(db, policy, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids, reasons := recordSessions(due)
+	wantIDs, wantReasons := wantPurged()
+	if !reflect.DeepEqual(ids, wantIDs) || !reflect.DeepEqual(reasons, wantReasons) {
+		t.Errorf("got %v %v, want %v %v", ids, reasons, wantIDs, wantReasons)
+	}
+
+	for _, model := range []interface{}{&models.Session{}, &models.Participant{}, &models.SessionToken{}} {
+		if n := count(t, db, model); n != int64(len(testSessions)) {
+			t.Errorf("%T: %d rows left, want %d", model, n, len(testSessions))
+		}
+	}
+	if n := count(t, db, &models.PurgeRecord{}); n != 0 {
+		t.Errorf("got %d audit records, want none", n)
+	}
+}
+
+func TestPurge(t *testing.T) {
+	db := newTestDB(t)
+	purged, err := Purge(db, policy, TriggerCLI, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids, reasons := recordSessions(purged)
+	wantIDs, wantReasons := wantPurged()
+	if !reflect.DeepEqual(ids, wantIDs) || !reflect.DeepEqual(reasons, wantReasons) {
+		t.Errorf("got %v %v, want %v %v", ids, reasons, wantIDs, wantReasons)
+	}
+
+	var kept []uint
+	if err := db.Unscoped().Model(&models.Session{}).Order("id").Pluck("id", &kept).Error; err != nil {
+		t.Fatal(err)
+	}
+	var wantKept []uint
+	for i, test := range testSessions {
+		if test.reason == "" {
+			wantKept = append(wantKept, uint(i+1))
+		}
+	}
+	if !reflect.DeepEqual(kept, wantKept) {
+		t.Errorf("kept sessions %v, want %v", kept, wantKept)
+	}
+	for _, model := range []interface{}{&models.Participant{}, &models.SessionToken{}} {
+		var sessions []uint
+		if err := db.Unscoped().Model(model).Order("session_id").Pluck("session_id", &sessions).Error; err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(sessions, wantKept) {
+			t.Errorf("%T: kept rows of sessions %v, want %v", model, sessions, wantKept)
+		}
+	}
+
+	var records []models.PurgeRecord
+	if err := db.Order("id").Find(&records).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != len(wantIDs) {
+		t.Fatalf("got %d audit records, want %d", len(records), len(wantIDs))
+	}
+	for _, record := range records {
+		if record.Trigger != TriggerCLI || record.SessionKind != models.SessionKindPair || record.SessionCreatedAt.IsZero() {
+			t.Errorf("got audit record %+v", record)
+		}
+	}
+
+	// Nothing is due any more
+	if again, err := Purge(db, policy, TriggerCLI, now); err != nil || len(again) != 0 {
+		t.Errorf("purging again deleted %d sessions, %v", len(again), err)
+	}
+}
+
+func TestDisabledPolicy(t *testing.T) {
+	db := newTestDB(t)
+	if (Policy{}).Enabled() {
+		t.Error("zero policy is enabled")
+	}
+	if due, err := Due(db, Policy{}, now); err != nil || len(due) != 0 {
+		t.Errorf("zero policy: %d due, %v", len(due), err)
+	}
+	if n := count(t, db, &models.Session{}); n != int64(len(testSessions)) {
+		t.Errorf("%d sessions left, want %d", n, len(testSessions))
+	}
+}
+
+func TestSummary(t *testing.T) {
+	records := []models.PurgeRecord{
+		{Reason: models.PurgeReasonInviteExpired},
+		{Reason: models.PurgeReasonResultsExpired},
+		{Reason: models.PurgeReasonInviteExpired},
+	}
+	want := "3 sessions (2 invite_expired, 1 results_expired)"
+	if got := Summary(records); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got := Summary(nil); got != "0 sessions" {
+		t.Errorf("got %q for no records", got)
+	}
+}
@@ -0,0 +1,109 @@
+package scoring
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"openai-api/pkg/answers"
+	"openai-api/pkg/models"
+)
+
+// Member is one participant of a group, as passed to ScoreGroup.
+type Member struct {
+	// ID identifies the member in GroupPair.
+	ID uint
+
+	// Name is the display name used in the summary.
+	Name string
+
+	// Answers are the member's answers, keyed like the answers passed to Score.
+	Answers answers.Answers
+}
+
+// GroupResult is the outcome of scoring every pair of members in a group.
+type GroupResult struct {
+	// Compatibility is the average of all pair scores, between 0 and 100.
+	Compatibility int
+
+	// Summary is a templated description of the group.
+	Summary string
+
+	// Pairs holds the score of every pair of members, in member order.
+	Pairs []GroupPair
+}
+
+// GroupPair is the compatibility of two members of a group.
+type GroupPair struct {
+	// A and B are the IDs of the two members, in the order they were passed to ScoreGroup.
+	A, B uint
+
+	// Result is the outcome of scoring the two members' answers.
+	Result Result
+}
+
+// ScoreGroup compares the answers of every pair of members.
+func ScoreGroup(questions []models.Question, members []Member) GroupResult {
+	var result GroupResult
+	var total int
+	for i := range members {
+		for j := i + 1; j < len(members); j++ {
+			pair := GroupPair{
+				A:      members[i].ID,
+				B:      members[j].ID,
+				Result: Score(questions, members[i].Answers, members[j].Answers),
+			}
+			total += pair.Result.Compatibility
+			result.Pairs = append(result.Pairs, pair)
+		}
+	}
+	if len(result.Pairs) > 0 {
+		result.Compatibility = int(math.Round(float64(total) / float64(len(result.Pairs))))
+	}
+	result.Summary = summarizeGroup(result, members)
+	return result
+}
+
+// summarizeGroup renders the templated summary for a group result.
+func summarizeGroup(result GroupResult, members []Member) string {
+	if len(result.Pairs) == 0 {
+		return "成员人数不足，暂时无法判断群体的契合度。（本结果由本地算法生成）"
+	}
+
+	names := make(map[uint]string, len(members))
+	for _, member := range members {
+		names[member.ID] = member.Name
+	}
+
+	var title string
+	switch {
+	case result.Compatibility >= 80:
+		title = "默契十足的同频群体，大家的想法常常不谋而合"
+	case result.Compatibility >= 60:
+		title = "求同存异的协作群体，在共识之上各有所长"
+	case result.Compatibility >= 40:
+		title = "多元并存的探索群体，差异之中藏着彼此学习的机会"
+	default:
+		title = "个性鲜明的互补群体，需要更多耐心去理解彼此"
+	}
+
+	closest, farthest := result.Pairs[0], result.Pairs[0]
+	for _, pair := range result.Pairs {
+		if pair.Result.Compatibility > closest.Result.Compatibility {
+			closest = pair
+		}
+		if pair.Result.Compatibility < farthest.Result.Compatibility {
+			farthest = pair
+		}
+	}
+
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "%s。%d位成员两两之间的平均契合度为%d%%。", title, len(members), result.Compatibility)
+	if closest.Result.Compatibility > farthest.Result.Compatibility {
+		fmt.Fprintf(&builder, "「%s」与「%s」最为默契（%d%%），而「%s」与「%s」的看法差异最大（%d%%）。",
+			names[closest.A], names[closest.B], closest.Result.Compatibility,
+			names[farthest.A], names[farthest.B], farthest.Result.Compatibility)
+	}
+	builder.WriteString("（本结果由本地算法生成）")
+	return builder.String()
+}
@@ -0,0 +1,59 @@
+package scoring
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"openai-api/pkg/answers"
+)
+
+func TestScoreGroup(t *testing.T) {
+	members := []Member{
+		{ID: 7, Name: "小红", Answers: answers.Answers{"颜色": "红", "心情": 1.0}},
+		{ID: 3, Name: "小蓝", Answers: answers.Answers{"颜色": "红", "心情": 2.0}},
+		{ID: 5, Name: "小绿", Answers: answers.Answers{"颜色": "蓝", "心情": 5.0}},
+	}
+	result := ScoreGroup(testQuestions, members)
+
+	// Every pair in member order
+	want := [][2]uint{{7, 3}, {7, 5}, {3, 5}}
+	if len(result.Pairs) != len(want) {
+		t.Fatalf("got %d pairs, want %d", len(result.Pairs), len(want))
+	}
+	total := 0
+	for i, pair := range result.Pairs {
+		if pair.A != want[i][0] || pair.B != want[i][1] {
+			t.Errorf("pair %d: got %d and %d, want %v", i, pair.A, pair.B, want[i])
+		}
+		if score := Score(testQuestions, memberAnswers(members, pair.A), memberAnswers(members, pair.B)); pair.Result.Compatibility != score.Compatibility {
+			t.Errorf("pair %d: got %d, scoring the pair alone %d", i, pair.Result.Compatibility, score.Compatibility)
+		}
+		total += pair.Result.Compatibility
+	}
+	if average := int(math.Round(float64(total) / 3)); result.Compatibility != average {
+		t.Errorf("got compatibility %d, want %d", result.Compatibility, average)
+	}
+	for _, part := range []string{"3位成员", "「小红」与「小蓝」最为默契", "「小红」与「小绿」的看法差异最大"} {
+		if !strings.Contains(result.Summary, part) {
+			t.Errorf("summary %q does not contain %q", result.Summary, part)
+		}
+	}
+}
+
+func TestScoreGroupTooSmall(t *testing.T) {
+	result := ScoreGroup(testQuestions, []Member{{ID: 1, Answers: answers.Answers{"颜色": "红"}}})
+	if len(result.Pairs) != 0 || result.Compatibility != 0 || !strings.Contains(result.Summary, "成员人数不足") {
+		t.Errorf("got %+v", result)
+	}
+}
+
+// memberAnswers returns the answers of the member with the given ID.
+func memberAnswers(members []Member, id uint) answers.Answers {
+	for _, member := range members {
+		if member.ID == id {
+			return member.Answers
+		}
+	}
+	return nil
+}
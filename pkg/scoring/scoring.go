@@ -0,0 +1,399 @@
+// Package scoring implements a deterministic, offline compatibility scorer.
+// It is used when SCORER=local and as the fallback when the LLM analysis fails.
+package scoring
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"openai-api/pkg/answers"
+	"openai-api/pkg/models"
+)
+
+// Engine is the engine name recorded on sessions scored by this package.
+const Engine = "local"
+
+// Question kinds used in QuestionScore.
+const (
+	KindChoice      = "choice"
+	KindMultiSelect = "multi_select"
+	KindScale       = "scale"
+	KindNumber      = "number"
+	KindRanking     = "ranking"
+	KindText        = "text"
+)
+
+// Result is the outcome of scoring two sets of answers.
+type Result struct {
+	// Compatibility is the overall score between 0 and 100.
+	Compatibility int
+
+	// Summary is a templated description of the result.
+	Summary string
+
+	// Questions holds the score of every question both users answered, in question order.
+	Questions []QuestionScore
+}
+
+// QuestionScore is the similarity of both users' answers to one question.
+type QuestionScore struct {
+	// QuestionID is the ID of the question, or zero if the answer key did not match a known question.
+	QuestionID uint
+
+	// Question is the question text, or the answer key for unknown questions.
+	Question string
+
+	// Kind is the kind of comparison used, following the question type.
+	// Answers that do not fit their question type are compared as KindText.
+	Kind string
+
+	// Similarity is between 0 (completely different) and 1 (identical).
+	Similarity float64
+}
+
+// Pair is both users' answers to one question.
+type Pair struct {
+	// Question is the question the answers belong to. Its ID is zero if the
+	// answer key did not match a known question.
+	Question models.Question
+
+	// Key is the answer key, normally the question text.
+	Key string
+
+	// A and B are the answers of User A and User B.
+	A, B interface{}
+}
+
+// PairAnswers matches the answers of two users to the given questions.
+// Answers may be keyed by question text, as the frontend sends them, or by question ID.
+// Only questions both users answered are returned, known questions first in question order.
+func PairAnswers(questions []models.Question, answersA, answersB answers.Answers) []Pair {
+	lookup := make(map[string]models.Question, len(questions)*2)
+	order := make(map[string]int, len(questions)*2)
+	for i, question := range questions {
+		lookup[question.QuestionText] = question
+		lookup[strconv.FormatUint(uint64(question.ID), 10)] = question
+		order[question.QuestionText] = i
+		order[strconv.FormatUint(uint64(question.ID), 10)] = i
+	}
+
+	var pairs []Pair
+	for key, answerA := range answersA {
+		answerB, ok := answersB[key]
+		if !ok || answers.IsEmpty(answerA) || answers.IsEmpty(answerB) {
+			continue
+		}
+		question, known := lookup[key]
+		if !known {
+			question = models.Question{QuestionText: key}
+		}
+		pairs = append(pairs, Pair{Question: question, Key: key, A: answerA, B: answerB})
+	}
+
+	// Keep the question order stable so results are deterministic
+	sort.Slice(pairs, func(i, j int) bool {
+		oi, iKnown := order[pairs[i].Key]
+		oj, jKnown := order[pairs[j].Key]
+		if iKnown != jKnown {
+			return iKnown
+		}
+		if oi != oj {
+			return oi < oj
+		}
+		return pairs[i].Key < pairs[j].Key
+	})
+	return pairs
+}
+
+// Score compares the answers of two users to the given questions.
+// Single choice answers are compared exactly, multi-select answers by the
+// options they share, scale and number answers by their distance, rankings
+// by how far the options moved and free-text answers by text similarity.
+func Score(questions []models.Question, answersA, answersB answers.Answers) Result {
+	return ScorePairs(PairAnswers(questions, answersA, answersB))
+}
+
+// ScorePairs scores answers already matched to their questions by PairAnswers.
+func ScorePairs(pairs []Pair) Result {
+	var scores []QuestionScore
+	for _, pair := range pairs {
+		score := QuestionScore{QuestionID: pair.Question.ID, Question: pair.Question.QuestionText}
+		score.Kind, score.Similarity = similarity(pair)
+		scores = append(scores, score)
+	}
+
+	result := Result{Questions: scores}
+	result.Compatibility = compatibility(scores)
+	result.Summary = summarize(result)
+	return result
+}
+
+// similarity compares the answers of a pair according to the question type,
+// falling back to text similarity for answers that do not fit it.
+func similarity(pair Pair) (string, float64) {
+	switch answers.Type(pair.Question) {
+	case models.QuestionTypeSingleChoice:
+		if normalize(answers.Text(pair.A)) == normalize(answers.Text(pair.B)) {
+			return KindChoice, 1
+		}
+		return KindChoice, 0
+
+	case models.QuestionTypeMultiSelect:
+		return KindMultiSelect, jaccard(answers.List(pair.A), answers.List(pair.B))
+
+	case models.QuestionTypeScale:
+		a, okA := answers.Number(pair.A)
+		b, okB := answers.Number(pair.B)
+		if okA && okB {
+			low, high := answers.ScaleRange(pair.Question)
+			return KindScale, distanceSimilarity(a, b, high-low)
+		}
+
+	case models.QuestionTypeNumber:
+		a, okA := answers.Number(pair.A)
+		b, okB := answers.Number(pair.B)
+		if okA && okB {
+			// Without a range, the distance is relative to the larger answer
+			span := math.Max(math.Abs(a), math.Abs(b))
+			if pair.Question.Min != nil && pair.Question.Max != nil {
+				span = *pair.Question.Max - *pair.Question.Min
+			}
+			return KindNumber, distanceSimilarity(a, b, span)
+		}
+
+	case models.QuestionTypeRanking:
+		a, okA := pair.A.([]string)
+		b, okB := pair.B.([]string)
+		if okA && okB {
+			return KindRanking, rankingSimilarity(a, b)
+		}
+	}
+	return KindText, TextSimilarity(answers.Text(pair.A), answers.Text(pair.B))
+}
+
+// jaccard returns the Jaccard index of two sets of options.
+func jaccard(a, b []string) float64 {
+	setA := make(map[string]bool, len(a))
+	for _, item := range a {
+		setA[normalize(item)] = true
+	}
+	setB := make(map[string]bool, len(b))
+	for _, item := range b {
+		setB[normalize(item)] = true
+	}
+	shared := 0
+	for item := range setB {
+		if setA[item] {
+			shared++
+		}
+	}
+	union := len(setA) + len(setB) - shared
+	if union == 0 {
+		return 1
+	}
+	return float64(shared) / float64(union)
+}
+
+// distanceSimilarity returns 1 for equal numbers, falling linearly to 0 as
+// their distance reaches span.
+func distanceSimilarity(a, b, span float64) float64 {
+	if a == b {
+		return 1
+	}
+	if span <= 0 {
+		return 0
+	}
+	return math.Max(0, 1-math.Abs(a-b)/span)
+}
+
+// rankingSimilarity compares two rankings of the same options using
+// Spearman's footrule, the total distance every option moved, relative to
+// the largest possible distance. Options missing from either ranking are ignored.
+func rankingSimilarity(a, b []string) float64 {
+	positions := make(map[string]int, len(a))
+	for i, item := range a {
+		positions[normalize(item)] = i
+	}
+	var common, distance int
+	for i, item := range b {
+		if j, ok := positions[normalize(item)]; ok {
+			common++
+			if i > j {
+				distance += i - j
+			} else {
+				distance += j - i
+			}
+		}
+	}
+	maxDistance := common * common / 2
+	if maxDistance == 0 {
+		return 1
+	}
+	return 1 - float64(distance)/float64(maxDistance)
+}
+
+// TextSimilarity returns a similarity between 0 and 1 for two free-text answers.
+// It averages the Dice coefficient of character bigrams, which rewards shared
+// phrases, with the cosine similarity of character frequencies, which rewards
+// shared vocabulary. Both work on Chinese text without word segmentation.
+func TextSimilarity(a, b string) float64 {
+	runesA, runesB := tokenize(a), tokenize(b)
+	if len(runesA) == 0 || len(runesB) == 0 {
+		return 0
+	}
+	if string(runesA) == string(runesB) {
+		return 1
+	}
+	return (diceBigrams(runesA, runesB) + cosineRunes(runesA, runesB)) / 2
+}
+
+// compatibility turns per-question similarities into a 0-100 score.
+// Free-text similarity is rarely high even for like-minded people, so it is
+// compressed with a square root before averaging.
+func compatibility(scores []QuestionScore) int {
+	if len(scores) == 0 {
+		return 0
+	}
+	var total float64
+	for _, score := range scores {
+		if score.Kind == KindText {
+			total += math.Sqrt(score.Similarity)
+		} else {
+			total += score.Similarity
+		}
+	}
+	value := int(math.Round(total / float64(len(scores)) * 100))
+	if value < 0 {
+		return 0
+	}
+	if value > 100 {
+		return 100
+	}
+	return value
+}
+
+// summarize renders the templated summary for a result.
+func summarize(result Result) string {
+	if len(result.Questions) == 0 {
+		return "你们没有共同回答的问题，暂时无法判断契合度。（本结果由本地算法生成）"
+	}
+
+	var title string
+	switch {
+	case result.Compatibility >= 80:
+		title = "心有灵犀的同频者，许多想法不谋而合"
+	case result.Compatibility >= 60:
+		title = "和而不同的同行者，在共识之上各有风景"
+	case result.Compatibility >= 40:
+		title = "各有千秋的探索者，差异之中藏着彼此学习的机会"
+	default:
+		title = "个性鲜明的互补者，需要更多耐心去理解对方"
+	}
+
+	var choiceTotal, choiceSame, textTotal, otherTotal int
+	var textSimilarity, otherSimilarity float64
+	closest, farthest := result.Questions[0], result.Questions[0]
+	for _, score := range result.Questions {
+		switch score.Kind {
+		case KindChoice:
+			choiceTotal++
+			if score.Similarity == 1 {
+				choiceSame++
+			}
+		case KindText:
+			textTotal++
+			textSimilarity += score.Similarity
+		default:
+			otherTotal++
+			otherSimilarity += score.Similarity
+		}
+		if score.Similarity > closest.Similarity {
+			closest = score
+		}
+		if score.Similarity < farthest.Similarity {
+			farthest = score
+		}
+	}
+
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "%s。", title)
+	if choiceTotal > 0 {
+		fmt.Fprintf(&builder, "在%d道选择题中，你们有%d道的选择完全一致。", choiceTotal, choiceSame)
+	}
+	if otherTotal > 0 {
+		fmt.Fprintf(&builder, "%d道多选、量表、数值或排序题的回答接近程度平均约为%d%%。", otherTotal, int(math.Round(otherSimilarity/float64(otherTotal)*100)))
+	}
+	if textTotal > 0 {
+		fmt.Fprintf(&builder, "%d道开放题的表达相似度平均约为%d%%。", textTotal, int(math.Round(textSimilarity/float64(textTotal)*100)))
+	}
+	if closest.Similarity > farthest.Similarity {
+		fmt.Fprintf(&builder, "「%s」最能体现你们的默契，而在「%s」上你们的看法差异最大。", closest.Question, farthest.Question)
+	}
+	builder.WriteString("（本结果由本地算法生成）")
+	return builder.String()
+}
+
+// normalize prepares a choice answer for exact comparison.
+func normalize(answer string) string {
+	return strings.ToLower(strings.TrimSpace(answer))
+}
+
+// tokenize lower-cases text and drops whitespace and punctuation.
+func tokenize(text string) []rune {
+	var runes []rune
+	for _, r := range strings.ToLower(text) {
+		if unicode.IsLetter(r) || unicode.IsNumber(r) {
+			runes = append(runes, r)
+		}
+	}
+	return runes
+}
+
+// diceBigrams returns the Sørensen-Dice coefficient of the character bigrams of a and b.
+func diceBigrams(a, b []rune) float64 {
+	if len(a) < 2 || len(b) < 2 {
+		if string(a) == string(b) {
+			return 1
+		}
+		return 0
+	}
+	bigramsA := make(map[[2]rune]int, len(a))
+	for i := 0; i+1 < len(a); i++ {
+		bigramsA[[2]rune{a[i], a[i+1]}]++
+	}
+	shared := 0
+	for i := 0; i+1 < len(b); i++ {
+		bigram := [2]rune{b[i], b[i+1]}
+		if bigramsA[bigram] > 0 {
+			bigramsA[bigram]--
+			shared++
+		}
+	}
+	return 2 * float64(shared) / float64(len(a)-1+len(b)-1)
+}
+
+// cosineRunes returns the cosine similarity of the character frequency vectors of a and b.
+func cosineRunes(a, b []rune) float64 {
+	freqA := map[rune]float64{}
+	for _, r := range a {
+		freqA[r]++
+	}
+	freqB := map[rune]float64{}
+	for _, r := range b {
+		freqB[r]++
+	}
+
+	var dot, normA, normB float64
+	for r, count := range freqA {
+		dot += count * freqB[r]
+		normA += count * count
+	}
+	for _, count := range freqB {
+		normB += count * count
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
@@ -0,0 +1,156 @@
+package scoring
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"openai-api/pkg/answers"
+	"openai-api/pkg/models"
+)
+
+func number(n float64) *float64 { return &n }
+
+// testQuestions covers every question type.
+var testQuestions = []models.Question{
+	{ID: 1, QuestionText: "颜色", Type: models.QuestionTypeSingleChoice, Options: `["红","蓝"]`},
+	{ID: 2, QuestionText: "爱好", Type: models.QuestionTypeMultiSelect, Options: `["读书","跑步","音乐"]`},
+	{ID: 3, QuestionText: "心情", Type: models.QuestionTypeScale, Min: number(1), Max: number(5)},
+	{ID: 4, QuestionText: "年龄", Type: models.QuestionTypeNumber, Min: number(0), Max: number(100)},
+	{ID: 5, QuestionText: "排序", Type: models.QuestionTypeRanking, Options: `["甲","乙","丙"]`},
+	{ID: 6, QuestionText: "留言", Type: models.QuestionTypeText},
+}
+
+// near reports whether a and b are equal up to rounding errors.
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestPairAnswers(t *testing.T) {
+	a := answers.Answers{"留言": "你好", "颜色": "红", "2": []string{"读书"}, "z": "x", "y": "x", "心情": 3.0, "年龄": ""}
+	b := answers.Answers{"留言": "你好", "颜色": "蓝", "2": []string{"跑步"}, "z": "x", "y": "x", "年龄": 30.0}
+	var keys []string
+	for _, pair := range PairAnswers(testQuestions, a, b) {
+		keys = append(keys, pair.Key)
+	}
+	// Known questions in question order, then unknown keys sorted; questions
+	// only one of them answered are left out
+	want := []string{"颜色", "2", "留言", "y", "z"}
+	if !reflect.DeepEqual(keys, want) {
+		t.Errorf("got keys %v, want %v", keys, want)
+	}
+}
+
+func TestSimilarity(t *testing.T) {
+	tests := []struct {
+		name       string
+		question   models.Question
+		a, b       interface{}
+		kind       string
+		similarity float64
+	}{
+		{"same choice", testQuestions[0], "红", " 红 ", KindChoice, 1},
+		{"different choice", testQuestions[0], "红", "蓝", KindChoice, 0},
+		{"multi-select overlap", testQuestions[1], []string{"读书", "跑步"}, []string{"跑步", "音乐"}, KindMultiSelect, 1.0 / 3},
+		{"multi-select disjoint", testQuestions[1], []string{"读书"}, []string{"音乐"}, KindMultiSelect, 0},
+		{"multi-select legacy string", testQuestions[1], "读书", []string{"读书"}, KindMultiSelect, 1},
+		{"scale equal", testQuestions[2], 3.0, "3", KindScale, 1},
+		{"scale near", testQuestions[2], 2.0, 3.0, KindScale, 0.75},
+		{"scale ends", testQuestions[2], 1.0, 5.0, KindScale, 0},
+		{"number in range", testQuestions[3], 30.0, 40.0, KindNumber, 0.9},
+		{"number without range", models.Question{Type: models.QuestionTypeNumber}, 50.0, 100.0, KindNumber, 0.5},
+		{"number zero span", models.Question{Type: models.QuestionTypeNumber, Min: number(1), Max: number(1)}, 1.0, 2.0, KindNumber, 0},
+		{"ranking same", testQuestions[4], []string{"甲", "乙", "丙"}, []string{"甲", "乙", "丙"}, KindRanking, 1},
+		{"ranking swapped", testQuestions[4], []string{"甲", "乙", "丙"}, []string{"乙", "甲", "丙"}, KindRanking, 0.5},
+		{"ranking reversed", testQuestions[4], []string{"甲", "乙", "丙"}, []string{"丙", "乙", "甲"}, KindRanking, 0},
+		{"scale not numeric falls back to text", testQuestions[2], "很好", "很好", KindText, 1},
+		{"text", testQuestions[5], "你好！", "你好", KindText, 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			kind, similarity := similarity(Pair{Question: test.question, A: test.a, B: test.b})
+			if kind != test.kind || !near(similarity, test.similarity) {
+				t.Errorf("got %s %v, want %s %v", kind, similarity, test.kind, test.similarity)
+			}
+		})
+	}
+}
+
+func TestTextSimilarity(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want float64
+	}{
+		{"我喜欢猫", "我喜欢猫", 1},
+		{"Hello, World", "hello world", 1},
+		{"", "你好", 0},
+		{"！？", "你好", 0},
+		{"我喜欢猫", "我喜欢狗", (2.0/3 + 3.0/4) / 2},
+		{"猫", "狗", 0},
+	}
+	for _, test := range tests {
+		if got := TextSimilarity(test.a, test.b); !near(got, test.want) {
+			t.Errorf("TextSimilarity(%q, %q) = %v, want %v", test.a, test.b, got, test.want)
+		}
+		if got := TextSimilarity(test.b, test.a); !near(got, test.want) {
+			t.Errorf("TextSimilarity(%q, %q) = %v, want %v", test.b, test.a, got, test.want)
+		}
+	}
+}
+
+func TestScore(t *testing.T) {
+	a := answers.Answers{"颜色": "红", "留言": "我喜欢猫", "心情": 2.0}
+	b := answers.Answers{"颜色": "红", "留言": "我喜欢狗", "心情": 3.0}
+	result := Score(testQuestions, a, b)
+
+	var ids []uint
+	for _, score := range result.Questions {
+		ids = append(ids, score.QuestionID)
+	}
+	if !reflect.DeepEqual(ids, []uint{1, 3, 6}) {
+		t.Errorf("got questions %v", ids)
+	}
+	// Text similarity is compressed with a square root
+	text := (2.0/3 + 3.0/4) / 2
+	want := int(math.Round((1 + 0.75 + math.Sqrt(text)) / 3 * 100))
+	if result.Compatibility != want {
+		t.Errorf("got compatibility %d, want %d", result.Compatibility, want)
+	}
+	for _, part := range []string{"心有灵犀", "在1道选择题中，你们有1道的选择完全一致", "「颜色」最能体现你们的默契", "「留言」上你们的看法差异最大"} {
+		if !strings.Contains(result.Summary, part) {
+			t.Errorf("summary %q does not contain %q", result.Summary, part)
+		}
+	}
+
+	if again := Score(testQuestions, a, b); !reflect.DeepEqual(again, result) {
+		t.Errorf("scoring again got %+v, want %+v", again, result)
+	}
+}
+
+func TestScoreNothingShared(t *testing.T) {
+	result := Score(testQuestions, answers.Answers{"颜色": "红"}, answers.Answers{"心情": 3.0})
+	if result.Compatibility != 0 || len(result.Questions) != 0 || !strings.Contains(result.Summary, "没有共同回答的问题") {
+		t.Errorf("got %+v", result)
+	}
+}
+
+func TestCompatibilityTitles(t *testing.T) {
+	tests := []struct {
+		similarity float64
+		title      string
+	}{
+		{1, "心有灵犀"},
+		{0.6, "和而不同"},
+		{0.4, "各有千秋"},
+		{0.39, "个性鲜明"},
+	}
+	for _, test := range tests {
+		result := ScorePairs(nil)
+		result.Questions = []QuestionScore{{Kind: KindScale, Similarity: test.similarity}}
+		result.Compatibility = compatibility(result.Questions)
+		if summary := summarize(result); !strings.HasPrefix(summary, test.title) {
+			t.Errorf("similarity %v: got summary %q, want %s", test.similarity, summary, test.title)
+		}
+	}
+}
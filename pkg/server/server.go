@@ -2,40 +2,237 @@
 package server
 
 import (
+	"context"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
 
+	"openai-api/pkg/apierror"
+	"openai-api/pkg/config"
 	"openai-api/pkg/database"
+	"openai-api/pkg/encryption"
 	"openai-api/pkg/handlers"
+	"openai-api/pkg/openapi"
+	"openai-api/pkg/providers"
+	"openai-api/pkg/retention"
 
 	"github.com/gorilla/mux"
 )
 
-// Start initializes and starts the HTTP server.
-func Start() {
+// Start builds the App from cfg and serves it until the process receives
+// SIGINT or SIGTERM, then shuts down gracefully. A second signal kills the
+// process at once.
+func Start(cfg *config.Config) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	if err := Run(ctx, cfg); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// Run builds the App from cfg and serves it until ctx is done. It then stops
+// accepting connections and, for up to cfg.Server.ShutdownTimeoutSeconds,
+// waits for in-flight requests and running analyses to finish. Analyses
+// still running after that are cancelled and left pending for the next
+// process. Finally it waits for the retention sweeper and closes the
+// database.
+func Run(ctx context.Context, cfg *config.Config) error {
+	log.Println("Effective configuration:")
+	if err := cfg.Print(log.Writer()); err != nil {
+		return err
+	}
+
+	app, err := NewApp(cfg)
+	if err != nil {
+		return err
+	}
+	distPath := cfg.Server.DistPath
+	srv := NewServer(cfg.Server, Handler(app, distPath))
+
+	// Start background analysis workers
+	app.Start()
+
+	// Delete expired sessions in the background
+	sweepCtx, stopSweep := context.WithCancel(context.Background())
+	defer stopSweep()
+	swept := make(chan struct{})
+	go func() {
+		defer close(swept)
+		interval := time.Duration(cfg.Retention.SweepIntervalMinutes) * time.Minute
+		retention.Sweep(sweepCtx, app.DB, retention.NewPolicy(cfg.Retention), interval, log.Default())
+	}()
+
+	// Start server
+	port := strconv.Itoa(cfg.Server.Port)
+	log.Printf("Server starting on port %s", port)
+	log.Printf("API endpoints available at http://localhost:%s/api/", port)
+	if _, err := os.Stat(distPath); err == nil {
+		log.Printf("Frontend available at http://localhost:%s/", port)
+	}
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err = <-serveErr:
+		err = fmt.Errorf("server failed: %w", err)
+	case <-ctx.Done():
+		log.Printf("Shutting down, waiting up to %ds for requests and analyses to finish", cfg.Server.ShutdownTimeoutSeconds)
+	}
+
+	// Drain the server and the analysis workers together, so that event
+	// streams end when their analyses do. The server gets a little longer,
+	// for the streams of cancelled analyses to send their final result.
+	timeout := time.Duration(cfg.Server.ShutdownTimeoutSeconds) * time.Second
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	drainCtx, cancelDrain := context.WithTimeout(context.Background(), timeout+streamGracePeriod)
+	defer cancelDrain()
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if err := srv.Shutdown(drainCtx); err != nil {
+			log.Printf("Failed to drain HTTP requests, closing connections: %v", err)
+			srv.Close()
+		}
+	}()
+	if err := app.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Cancelled unfinished analyses: %v", err)
+	}
+	wg.Wait()
+	stopSweep()
+	<-swept
+
+	if err := database.Close(app.DB); err != nil {
+		log.Printf("Failed to close database: %v", err)
+	}
+	if err == nil {
+		log.Println("Server stopped")
+	}
+	return err
+}
+
+// streamGracePeriod is how long event streams may take to finish after the
+// analysis workers have stopped.
+const streamGracePeriod = 2 * time.Second
+
+// NewServer returns an http.Server serving handler on the port and with the
+// timeouts of cfg.
+func NewServer(cfg config.Server, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              ":" + strconv.Itoa(cfg.Port),
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       time.Duration(cfg.ReadTimeoutSeconds) * time.Second,
+		WriteTimeout:      time.Duration(cfg.WriteTimeoutSeconds) * time.Second,
+		IdleTimeout:       time.Duration(cfg.IdleTimeoutSeconds) * time.Second,
+	}
+}
+
+// NewApp builds the App from cfg: it reads the system prompts, creates the
+// LLM provider, loads the encryption keys and connects to the database,
+// applying pending migrations if cfg.Database.AutoMigrate is set.
+func NewApp(cfg *config.Config) (*handlers.App, error) {
+	settings := handlers.DefaultConfig()
+	var err error
+	if settings.SystemPrompt, settings.GroupSystemPrompt, err = cfg.Analysis.Prompts(); err != nil {
+		return nil, err
+	}
+	settings.Scorer = cfg.Analysis.Scorer
+	settings.ScorerFallback = cfg.Analysis.ScorerFallback != "none"
+	settings.ResponseFormat = cfg.LLM.ResponseFormat
+	settings.MaxRepairs = cfg.LLM.MaxRepairs
+	settings.DefaultQuestionnaire = cfg.Questions.DefaultQuestionnaire
+	settings.MaxRequestBytes = cfg.Server.MaxRequestBytes
+	settings.MaxAnswerLength = cfg.Questions.MaxAnswerLength
+	settings.MaxAnswerRevisions = cfg.Questions.MaxAnswerRevisions
+	settings.AnalysisWorkers = cfg.Analysis.Workers
+	settings.AnalysisTimeout = time.Duration(cfg.Analysis.TimeoutSeconds) * time.Second
+	settings.AdminSessionDuration = time.Duration(cfg.Admin.SessionHours) * time.Hour
+	settings.InviteTokenDuration = time.Duration(cfg.Tokens.InviteHours) * time.Hour
+	settings.ResultsTokenDuration = time.Duration(cfg.Tokens.ResultsHours) * time.Hour
+	settings.LegacyTokenDuration = time.Duration(cfg.Tokens.LegacyDays) * 24 * time.Hour
+
+	provider, err := providers.New(cfg.LLM)
+	if err != nil {
+		return nil, err
+	}
+	keyring, err := encryption.NewKeyring(cfg.Encryption)
+	if err != nil {
+		return nil, err
+	}
+	if keyring.Enabled() {
+		log.Printf("Encrypting answers and summaries with key %q", keyring.ActiveKey())
+	} else {
+		log.Println("Encryption at rest disabled: no encryption keys configured")
+	}
+	db, err := database.Connect(cfg.Database.DSN, cfg.Database.AutoMigrate)
+	if err != nil {
+		return nil, err
+	}
+	return handlers.New(encryption.Attach(db, keyring), provider, settings, nil), nil
+}
+
+// Handler returns the HTTP handler serving the API of app under /api, and
+// the frontend built into distPath if it exists.
+func Handler(app *handlers.App, distPath string) http.Handler {
 	// Create router
 	r := mux.NewRouter()
 
-	// API routes
+	// API routes. Every route must be described in pkg/openapi/openapi.json.
+	// Endpoints wrapped in app.Idempotent accept an Idempotency-Key header.
 	api := r.PathPrefix("/api").Subrouter()
-	api.HandleFunc("/submit-user-a", handlers.SubmitUserA).Methods("POST")
-	api.HandleFunc("/submit-user-b", handlers.SubmitUserB).Methods("POST")
-	api.HandleFunc("/results/{token}", handlers.GetResults).Methods("GET")
-	api.HandleFunc("/questions/upload", handlers.UploadQuestions).Methods("POST")
-	api.HandleFunc("/questions", handlers.GetQuestions).Methods("GET")
-
-	// Get the path to the dist directory from environment variable or use default
-	distPath := os.Getenv("DIST_PATH")
-	if distPath == "" {
-		// Default to frontend/cyberqa/dist assuming the frontend has been built
-		distPath = "frontend/cyberqa/dist"
-	}
+	api.NotFoundHandler = http.HandlerFunc(apierror.NotFound)
+	idempotent := app.Idempotent
+	api.HandleFunc("/submit-user-a", idempotent(app.SubmitUserA)).Methods("POST")
+	api.HandleFunc("/submit-user-b", idempotent(app.SubmitUserB)).Methods("POST")
+	api.HandleFunc("/results/{token}", app.GetResults).Methods("GET")
+	api.HandleFunc("/results/{token}/stream", app.StreamResults).Methods("GET")
+	api.HandleFunc("/groups", app.CreateGroup).Methods("POST")
+	api.HandleFunc("/groups/{token}", app.GetGroupResults).Methods("GET")
+	api.HandleFunc("/groups/{token}/join", app.JoinGroup).Methods("POST")
+	api.HandleFunc("/groups/{token}/analyze", app.AnalyzeGroup).Methods("POST")
+	api.HandleFunc("/groups/{token}/stream", app.StreamGroupResults).Methods("GET")
+	api.HandleFunc("/tokens/{token}", app.RevokeToken).Methods("DELETE")
+	api.HandleFunc("/my-data/{token}", app.ExportData).Methods("GET")
+	api.HandleFunc("/my-data/{token}", app.EraseData).Methods("DELETE")
+	api.HandleFunc("/questions", app.GetQuestions).Methods("GET")
+	api.HandleFunc("/questionnaires", app.ListQuestionnaires).Methods("GET")
+	api.HandleFunc("/openapi.json", openapi.GetSpec).Methods("GET")
+
+	// Admin routes. Management endpoints must be wrapped in app.Auth.RequireAdmin.
+	requireAdmin := app.Auth.RequireAdmin
+	api.HandleFunc("/admin/login", app.AdminLogin).Methods("POST")
+	api.HandleFunc("/admin/logout", app.AdminLogout).Methods("POST")
+	api.HandleFunc("/admin/me", requireAdmin(app.AdminMe)).Methods("GET")
+	api.HandleFunc("/questions/upload", requireAdmin(app.UploadQuestions)).Methods("POST")
+	api.HandleFunc("/admin/questionnaires", requireAdmin(app.ListAdminQuestionnaires)).Methods("GET")
+	api.HandleFunc("/admin/questionnaires", requireAdmin(app.CreateQuestionnaire)).Methods("POST")
+	api.HandleFunc("/admin/questionnaires/{slug}", requireAdmin(app.UpdateQuestionnaire)).Methods("PUT")
+	api.HandleFunc("/admin/questions", requireAdmin(app.ListBankQuestions)).Methods("GET")
+	api.HandleFunc("/admin/questions", requireAdmin(app.CreateQuestion)).Methods("POST")
+	api.HandleFunc("/admin/questions/order", requireAdmin(app.ReorderQuestions)).Methods("PUT")
+	api.HandleFunc("/admin/questions/{id:[0-9]+}", requireAdmin(app.UpdateQuestion)).Methods("PUT")
+	api.HandleFunc("/admin/questions/{id:[0-9]+}", requireAdmin(app.DeleteQuestion)).Methods("DELETE")
+	api.HandleFunc("/admin/question-sets", requireAdmin(app.ListQuestionSets)).Methods("GET")
+	api.HandleFunc("/admin/question-sets", requireAdmin(app.PublishQuestionSet)).Methods("POST")
+	api.HandleFunc("/admin/question-sets/{version:[0-9]+}", requireAdmin(app.GetQuestionSet)).Methods("GET")
 
 	// Check if dist directory exists
 	if _, err := os.Stat(distPath); os.IsNotExist(err) {
-		log.Printf("Warning: Dist directory '%s' does not exist. Frontend files will not be served.", distPath)
+		app.Logger.Printf("Warning: Dist directory '%s' does not exist. Frontend files will not be served.", distPath)
 	} else {
 		// Serve static files
 		fs := http.FileServer(http.Dir(distPath))
@@ -51,21 +248,5 @@ func Start() {
 			}
 		})
 	}
-
-	// Get port from environment variable or use default
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8088"
-	}
-
-	// Connect to database
-	database.Connect()
-
-	// Start server
-	log.Printf("Server starting on port %s", port)
-	log.Printf("API endpoints available at http://localhost:%s/api/", port)
-	if _, err := os.Stat(distPath); err == nil {
-		log.Printf("Frontend available at http://localhost:%s/", port)
-	}
-	log.Fatal(http.ListenAndServe(":"+port, r))
+	return apierror.RequestID(r)
 }